@@ -0,0 +1,304 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/adammck/hexapod/components/legs"
+	"github.com/adammck/hexapod/math3d"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const usage = `commands:
+  leg N JOINT DEG  move a joint (coxa, femur, tibia, tarsus) of leg N to DEG
+  leg N goal X Y Z move the foot of leg N to X,Y,Z (relative to the hexapod)
+  calibrate N      calibrate leg N, by holding it in the reference pose
+  disable N        take leg N out of the gait, e.g. because it's broken
+  enable N         put leg N back into the gait
+  mirror SRC DST   copy the calibration of leg SRC to leg DST (by name)
+  symmetry         check that mirror-image legs are calibrated symmetrically
+  save PATH        write the calibration offsets of every leg to PATH
+  relax            disable torque on every servo
+  voltage          print the current voltage
+  view             draw the feet from above (planted 0-5, lifted a-f)
+  help             print this message
+  quit             exit
+
+legs (N) can be given by index (0-5) or by name (e.g. FL, MR)`
+
+// The difference (in degrees) between mirror-image joints which the symmetry
+// command tolerates. The headings of the middle legs are a degree apart.
+const symmetryTolerance = 1.5
+
+// CLI runs simple commands against the legs of a connected hexapod. It's meant
+// for jogging individual joints while building and debugging, not for walking.
+type CLI struct {
+	legs *legs.Legs
+	out  io.Writer
+
+	// Where to read lines from, when a command needs to wait for the operator.
+	// This is nil unless the CLI was started by Run.
+	in *bufio.Scanner
+}
+
+func New(l *legs.Legs, out io.Writer) *CLI {
+	return &CLI{
+		legs: l,
+		out:  out,
+	}
+}
+
+// Run reads commands (one per line) from the given reader and executes them
+// until it reaches EOF or the quit command. Errors are printed, not returned,
+// so a typo doesn't end the session.
+func Run(l *legs.Legs, in io.Reader, out io.Writer) error {
+	c := New(l, out)
+	s := bufio.NewScanner(in)
+	c.in = s
+
+	for {
+		fmt.Fprint(out, "> ")
+		if !s.Scan() {
+			return s.Err()
+		}
+
+		line := strings.TrimSpace(s.Text())
+		if line == "quit" || line == "exit" {
+			return nil
+		}
+
+		err := c.Exec(line)
+		if err != nil {
+			fmt.Fprintf(out, "error: %s\n", err)
+		}
+	}
+}
+
+// Exec parses and executes a single command.
+func (c *CLI) Exec(line string) error {
+	f := strings.Fields(line)
+	if len(f) == 0 {
+		return nil
+	}
+
+	switch f[0] {
+	case "help":
+		fmt.Fprintln(c.out, usage)
+		return nil
+
+	case "leg":
+		return c.leg(f[1:])
+
+	case "calibrate":
+		return c.calibrate(f[1:])
+
+	case "disable", "enable":
+		if len(f) != 2 {
+			return fmt.Errorf("usage: %s N", f[0])
+		}
+
+		i, err := c.legIndex(f[1])
+		if err != nil {
+			return err
+		}
+
+		if f[0] == "disable" {
+			return c.legs.DisableLeg(i)
+		}
+
+		return c.legs.EnableLeg(i)
+
+	case "mirror":
+		if len(f) != 3 {
+			return fmt.Errorf("usage: mirror SRC DST")
+		}
+
+		return c.legs.MirrorCalibration(f[1], f[2])
+
+	case "symmetry":
+		err := c.legs.CheckSymmetry(symmetryTolerance)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintln(c.out, "ok")
+		return nil
+
+	case "save":
+		if len(f) != 2 {
+			return fmt.Errorf("usage: save PATH")
+		}
+
+		w, err := os.Create(f[1])
+		if err != nil {
+			return err
+		}
+
+		defer w.Close()
+		return c.legs.SaveCalibration(w)
+
+	case "relax":
+		for _, leg := range c.legs.Legs {
+			for _, servo := range leg.Servos() {
+				servo.SetTorqueEnable(false)
+			}
+
+			leg.Initialized = false
+			leg.ForgetGoals()
+		}
+
+		return nil
+
+	case "view":
+		fmt.Fprint(c.out, c.legs.TopView())
+		return nil
+
+	case "voltage":
+		v, err := c.legs.Legs[0].Coxa.Voltage()
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(c.out, "%.2fv\n", v)
+		return nil
+
+	default:
+		return fmt.Errorf("unknown command: %s (try help)", f[0])
+	}
+}
+
+// leg handles the leg command. The first argument is the index of the leg.
+func (c *CLI) leg(args []string) error {
+	if len(args) < 2 {
+		return fmt.Errorf("usage: leg N JOINT DEG or leg N goal X Y Z")
+	}
+
+	i, err := c.legIndex(args[0])
+	if err != nil {
+		return err
+	}
+
+	leg := c.legs.Legs[i]
+	n, err := floats(args[2:])
+	if err != nil {
+		return err
+	}
+
+	if args[1] == "goal" {
+		if len(n) != 3 {
+			return fmt.Errorf("usage: leg N goal X Y Z")
+		}
+
+		c.enable(leg)
+		return leg.SetGoal(math3d.Vector3{n[0], n[1], n[2]})
+	}
+
+	servo, err := joint(leg, args[1])
+	if err != nil {
+		return err
+	}
+
+	if len(n) != 1 {
+		return fmt.Errorf("usage: leg N JOINT DEG")
+	}
+
+	// The servo is moved directly, so the leg's idea of its goal is stale.
+	c.enable(leg)
+	leg.ForgetGoals()
+	return servo.MoveTo(n[0])
+}
+
+// calibrate handles the calibrate command. The operator is prompted to move the
+// leg into the reference pose, and press enter.
+func (c *CLI) calibrate(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: calibrate N")
+	}
+
+	i, err := c.legIndex(args[0])
+	if err != nil {
+		return err
+	}
+
+	if c.in == nil {
+		return fmt.Errorf("can't calibrate without input")
+	}
+
+	err = c.legs.CalibrateLeg(i, func() error {
+		fmt.Fprintf(c.out, "move leg %d to %+v, then press enter\n", i, c.legs.ReferencePose)
+		if !c.in.Scan() {
+			return fmt.Errorf("calibration aborted")
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(c.out, "offsets: %+v\n", c.legs.Legs[i].CalibrationOffsets)
+	return nil
+}
+
+// legIndex returns the index of the leg given by an argument, which can be its
+// index or its name.
+func (c *CLI) legIndex(arg string) (int, error) {
+	if i, ok := c.legs.LegIndex(arg); ok {
+		return i, nil
+	}
+
+	i, err := strconv.Atoi(arg)
+	if err != nil || i < 0 || i >= len(c.legs.Legs) {
+		return 0, fmt.Errorf("invalid leg: %s", arg)
+	}
+
+	return i, nil
+}
+
+// enable turns on the torque of each servo in the given leg, so it can be moved.
+func (c *CLI) enable(leg *legs.Leg) {
+	if leg.Initialized {
+		return
+	}
+
+	for _, servo := range leg.Servos() {
+		servo.SetTorqueEnable(true)
+	}
+
+	leg.Initialized = true
+}
+
+// joint returns the servo of the given leg with the given name.
+func joint(leg *legs.Leg, name string) (legs.Servo, error) {
+	switch name {
+	case "coxa":
+		return leg.Coxa, nil
+	case "femur":
+		return leg.Femur, nil
+	case "tibia":
+		return leg.Tibia, nil
+	case "tarsus":
+		return leg.Tarsus, nil
+	default:
+		return nil, fmt.Errorf("invalid joint: %s", name)
+	}
+}
+
+// floats parses each of the given strings as a float64.
+func floats(args []string) ([]float64, error) {
+	n := make([]float64, len(args))
+
+	for i, a := range args {
+		f, err := strconv.ParseFloat(a, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number: %s", a)
+		}
+
+		n[i] = f
+	}
+
+	return n, nil
+}
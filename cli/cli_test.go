@@ -0,0 +1,69 @@
+package cli
+
+import (
+	"bytes"
+	"github.com/adammck/hexapod"
+	"github.com/adammck/hexapod/components/legs"
+	"strings"
+	"testing"
+)
+
+func TestExecInvalid(t *testing.T) {
+	c := New(legs.New(hexapod.NewHexapod(nil), nil), &bytes.Buffer{})
+
+	// None of these should get as far as touching a servo.
+	data := []string{
+		"dance",
+		"leg",
+		"leg 2",
+		"leg 6 coxa 30",
+		"leg -1 coxa 30",
+		"leg x coxa 30",
+		"leg 2 elbow 30",
+		"leg 2 coxa",
+		"leg 2 coxa thirty",
+		"leg 2 coxa 30 40",
+		"leg 2 goal 100 -80",
+		"calibrate",
+		"calibrate 9",
+		"calibrate 2",
+		"save",
+		"mirror FL",
+		"mirror FL XX",
+		"leg XX coxa 30",
+		"calibrate BR",
+		"disable",
+		"disable 6",
+		"enable XX",
+	}
+
+	for _, line := range data {
+		if err := c.Exec(line); err == nil {
+			t.Errorf("%q: expected error", line)
+		}
+	}
+}
+
+func TestRunQuit(t *testing.T) {
+	out := &bytes.Buffer{}
+	in := strings.NewReader("\nhelp\nwat\nquit\nrelax\n")
+
+	err := Run(legs.New(hexapod.NewHexapod(nil), nil), in, out)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	if !strings.Contains(out.String(), "unknown command: wat") {
+		t.Errorf("expected error for unknown command, got: %q", out.String())
+	}
+}
+
+func TestLegIndex(t *testing.T) {
+	c := New(legs.New(hexapod.NewHexapod(nil), nil), &bytes.Buffer{})
+
+	for arg, exp := range map[string]int{"0": 0, "5": 5, "FL": 0, "BR": 3, "ml": 5} {
+		if i, err := c.legIndex(arg); err != nil || i != exp {
+			t.Errorf("%q: got %d (err=%v), expected %d", arg, i, err, exp)
+		}
+	}
+}
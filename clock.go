@@ -0,0 +1,33 @@
+package hexapod
+
+import (
+	"time"
+)
+
+// Clock is the source of the current time. Everything which needs the time
+// (other than the ticks of the main loop) should ask the clock, so tests can
+// control it with a FakeClock.
+type Clock interface {
+	Now() time.Time
+}
+
+// RealClock is a Clock which returns the actual time.
+type RealClock struct{}
+
+func (c RealClock) Now() time.Time {
+	return time.Now()
+}
+
+// FakeClock is a Clock which only moves when it's told to. It's for tests.
+type FakeClock struct {
+	T time.Time
+}
+
+func (c *FakeClock) Now() time.Time {
+	return c.T
+}
+
+// Advance moves the clock forwards by the given duration.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.T = c.T.Add(d)
+}
@@ -0,0 +1,22 @@
+package hexapod
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFakeClock(t *testing.T) {
+	start := time.Unix(100, 0)
+	c := &FakeClock{T: start}
+	h := NewHexapod(nil)
+	h.Clock = c
+
+	if !h.Now().Equal(start) {
+		t.Errorf("got %s, expected %s", h.Now(), start)
+	}
+
+	c.Advance(time.Second)
+	if exp := start.Add(time.Second); !h.Now().Equal(exp) {
+		t.Errorf("got %s, expected %s", h.Now(), exp)
+	}
+}
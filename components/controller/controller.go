@@ -1,10 +1,13 @@
 package controller
 
 import (
+	"fmt"
 	"github.com/adammck/hexapod"
 	"github.com/adammck/hexapod/math3d"
+	"github.com/adammck/hexapod/utils"
 	"github.com/adammck/sixaxis"
 	"io"
+	"math"
 	"time"
 )
 
@@ -17,17 +20,181 @@ const (
 	// The maximum speed to rotate (i.e. when the right stick is fully pressed)
 	// in degrees per loop.
 	rotationSpeed = 0.8
+
+	// The default maximum change in rotation speed, in degrees per loop per loop.
+	// At this rate, it takes eight loops to reach full speed.
+	defaultMaxAngularAccel = 0.1
+
+	// Smoothed inputs smaller than this are rounded down to zero, so the body
+	// doesn't creep forever after the sticks are released.
+	minSmoothed = 0.001
+
+	// The fraction of the acceleration pitch bias which remains after each loop.
+	// Lower values level the body out more quickly after a change in speed.
+	accelPitchDecay = 0.9
+
+	// The default maximum pitch (in degrees) which will be added to the body to
+	// compensate for acceleration.
+	defaultMaxAccelPitch = 5.0
+
+	// The default maximum roll (in degrees) which will be added to the body to
+	// bank into turns.
+	defaultMaxBankRoll = 5.0
+
+	// The time between reads of the servo load. See LoadSource.
+	loadInterval = 250 * time.Millisecond
+
+	// The default load (as a fraction of the maximum torque) above which the
+	// speed is reduced, the factor to reduce it by each time that the load is
+	// read, and the lowest that it'll go.
+	defaultMaxLoad      = 0.8
+	defaultLoadSlowdown = 0.8
+	minLoadSpeed        = 0.2
+
+	// The default exponent of the response curve of each stick axis, which is
+	// linear. See StrafeCurve.
+	defaultStickCurve = 1.0
 )
 
+// StanceSetter is implemented by components (i.e. the legs) which have named
+// stances to switch between.
+type StanceSetter interface {
+	SetStance(name string) error
+}
+
+// Marcher is implemented by components (i.e. the legs) which can step on the
+// spot, without going anywhere.
+type Marcher interface {
+	SetMarching(marching bool)
+}
+
+// HeadingSource is something (e.g. a magnetometer) which can measure the heading
+// of the body, in degrees, in the same direction as the rotation.
+type HeadingSource interface {
+	Heading() (float64, error)
+}
+
+// LoadSource is implemented by components (i.e. the legs) which can measure how
+// hard the servos are working, as a fraction of their maximum torque.
+type LoadSource interface {
+	Load() (float64, error)
+}
+
 type Controller struct {
 	hex *hexapod.Hexapod
 	sa  *sixaxis.SA
+
+	// Where to send stance changes from the dpad: up for tall, down for low,
+	// and right for normal. The dpad does nothing when this is nil.
+	Stances StanceSetter
+	stance  string
+
+	// What to toggle marching on the spot with the triangle button, which is
+	// handy for demos and for warming up the servos. The button does nothing
+	// when this is nil.
+	Marcher     Marcher
+	marching    bool
+	marchButton bool
+
+	// The exponent of the response curve of each stick axis: strafing (left
+	// stick X), walking forwards (left stick Y), and turning (right stick X).
+	// One is linear. Higher values make small deflections finer, while still
+	// reaching full speed at full deflection. See stickCurve.
+	StrafeCurve  float64
+	ForwardCurve float64
+	TurnCurve    float64
+
+	// The maximum change in rotation speed (in degrees per loop) each loop, so
+	// turns start and stop gracefully rather than snapping the feet. Zero means
+	// no limit.
+	MaxAngularAccel float64
+
+	// The current rotation speed, in degrees per loop.
+	angularVelocity float64
+
+	// The time constant of the low-pass filter applied to the sticks, to smooth
+	// out shaky hands and noise. Longer is smoother, but less responsive. Zero
+	// disables it.
+	Smoothing time.Duration
+
+	// The filtered movement and rotation from the sticks, and the time that they
+	// were last updated.
+	smoothMove math3d.Vector3
+	smoothTurn float64
+	smoothTime time.Time
+
+	// How far (in degrees) to pitch the body per unit of forward acceleration,
+	// to counteract the lurch when starting and stopping, and the most which it
+	// will pitch by either way. Zero gain disables it.
+	AccelPitchGain float64
+	MaxAccelPitch  float64
+
+	// How far (in degrees) to roll the body into turns, per unit of forward
+	// speed times angular velocity, like a motorcycle banking. The most which
+	// it will roll by either way is MaxBankRoll. Zero gain disables it.
+	BankGain    float64
+	MaxBankRoll float64
+
+	// The source of the measured heading of the body, and the gains of the PID
+	// loop which holds it steady while walking with the right stick centered,
+	// to make up for the feet slipping. The output is in degrees per loop, like
+	// the right stick. Nothing is corrected when the source is nil.
+	Heading  HeadingSource
+	HeadingP float64
+	HeadingI float64
+	HeadingD float64
+
+	// The measured heading which is being held (or nil if it isn't), and the
+	// state of the PID loop.
+	heldHeading     *float64
+	headingIntegral float64
+	headingError    float64
+
+	// The maximum speed (in mm per second) which the body will be moved at, no
+	// matter how far the stick is pushed. Zero means no limit.
+	MaxSpeed float64
+
+	// The time of the previous loop, to work out how far the body may move.
+	lastTick time.Time
+
+	// Where to read the load of the servos from, the load above which to slow
+	// down (to avoid stalling), and the factor to slow down by each time that
+	// the load is read while it's too high. The speed recovers by the same factor
+	// once the load drops. Nothing is read while Loads is nil.
+	Loads        LoadSource
+	MaxLoad      float64
+	LoadSlowdown float64
+
+	// The fraction of full speed which the sticks are currently limited to, and
+	// the last time that the load was read.
+	loadSpeed float64
+	loadTime  time.Time
+
+	// The fraction of full speed which the sticks are limited to while the
+	// battery is running low. See Limp.
+	limpSpeed float64
+
+	// The movement vector from the previous loop, and the pitch which has been
+	// added to the body to compensate for the change.
+	lastMove  math3d.Vector3
+	pitchBias float64
+	rollBias  float64
 }
 
 func New(hex *hexapod.Hexapod, r io.Reader) *Controller {
 	return &Controller{
-		hex: hex,
-		sa:  sixaxis.New(r),
+		hex:             hex,
+		sa:              sixaxis.New(r),
+		MaxAngularAccel: defaultMaxAngularAccel,
+		MaxAccelPitch:   defaultMaxAccelPitch,
+		MaxBankRoll:     defaultMaxBankRoll,
+		MaxLoad:         defaultMaxLoad,
+		LoadSlowdown:    defaultLoadSlowdown,
+		loadSpeed:       1,
+		limpSpeed:       1,
+		StrafeCurve:     defaultStickCurve,
+		ForwardCurve:    defaultStickCurve,
+		TurnCurve:       defaultStickCurve,
 	}
 }
 
@@ -37,39 +204,75 @@ func (c *Controller) Boot() error {
 	return nil
 }
 
+// Input returns the present state of the sixaxis, for MainLoop to pass to Step.
+// The controller acts upon whatever input is passed, so tests can script it.
+func (c *Controller) Input() hexapod.InputState {
+	return hexapod.InputState{
+		LeftStick:  hexapod.Stick{int(c.sa.LeftStick.X), int(c.sa.LeftStick.Y)},
+		RightStick: hexapod.Stick{int(c.sa.RightStick.X), int(c.sa.RightStick.Y)},
+		Up:         int(c.sa.Up),
+		Down:       int(c.sa.Down),
+		Left:       int(c.sa.Left),
+		Right:      int(c.sa.Right),
+		Triangle:   int(c.sa.Triangle),
+		Start:      c.sa.Start,
+	}
+}
+
 // TODO: Update the state of the hexapod based on the state of the controller.
 func (c *Controller) Tick(now time.Time) error {
 
-	// Rotate with the right stick
-	if c.sa.RightStick.X != 0 {
-		c.hex.Rotation += (float64(c.sa.RightStick.X) / 127.0) * rotationSpeed
-	}
-
 	// How much the origin should move this frame. Default is zero, but this
 	// it mutated (below) by the various buttons.
 	vecMove := math3d.MakeVector3(0, 0, 0)
+	in := c.hex.Input
 
-	if c.sa.LeftStick.X != 0 {
-		vecMove.X = (float64(c.sa.LeftStick.X) / 127.0) * moveSpeed
+	if in.LeftStick.X != 0 {
+		vecMove.X = stickCurve(float64(in.LeftStick.X), c.StrafeCurve) * moveSpeed
 	}
 
-	if c.sa.LeftStick.Y != 0 {
-		vecMove.Z = (float64(-c.sa.LeftStick.Y) / 127.0) * moveSpeed
+	if in.LeftStick.Y != 0 {
+		vecMove.Z = stickCurve(float64(-in.LeftStick.Y), c.ForwardCurve) * moveSpeed
 	}
 
-	// Move the origin up (away from the ground) with the dpad. This alters
-	// the gait my keeping the body up in the air. It looks weird but works.
-	if c.sa.Up > 0 {
-		//c.hex.baseClearance += 2
+	turn := stickCurve(float64(in.RightStick.X), c.TurnCurve) * rotationSpeed
+
+	// Slow down if the servos are working too hard, or the battery is low.
+	c.updateLoadSpeed(now)
+	*vecMove = vecMove.Scale(c.loadSpeed * c.limpSpeed)
+	turn *= c.loadSpeed * c.limpSpeed
+
+	*vecMove, turn = c.smooth(now, *vecMove, turn)
+	turn += c.holdHeading(*vecMove, turn)
+
+	// Rotate with the right stick. This overrides any target rotation, since
+	// the operator clearly has other ideas. Once the stick is released, keep
+	// turning until we've slowed down.
+	c.updateAngularVelocity(turn)
+	if in.RightStick.X != 0 {
+		c.hex.TargetRotation = nil
 	}
 
-	if c.sa.Down > 0 {
-		//c.hex.baseClearance -= 2
+	if c.angularVelocity != 0 {
+		c.hex.Turn(c.angularVelocity)
+
+	} else if c.hex.TargetRotation != nil {
+		c.turnTowardsTarget()
 	}
 
-	// Update the position, if it's changed.
+	// Switch between stances with the dpad. The tall stance keeps the body up
+	// in the air. It looks weird but works.
+	c.selectStance()
+	c.toggleMarch()
+
+	*vecMove = c.limitSpeed(now, *vecMove)
+	c.updatePitchBias(*vecMove)
+	c.updateRollBias(*vecMove, c.angularVelocity)
+
+	// Update the position, if it's changed. If any of the components object to
+	// the new position (e.g. because the legs can't reach), just stay put.
 	if !vecMove.Zero() {
-		c.hex.Position = vecMove.MultiplyByMatrix44(c.hex.World())
+		c.hex.SetPose(vecMove.MultiplyByMatrix44(c.hex.World()), c.hex.Rotation)
 	}
 
 	//dontMove = (c.sa.Square > 0)
@@ -78,9 +281,241 @@ func (c *Controller) Tick(now time.Time) error {
 	//c.hex.Position.Y = c.hex.Clearance()
 
 	// At any time, pressing start shuts down the hex.
-	if c.sa.Start {
+	if in.Start {
 		c.hex.Shutdown = true
 	}
 
 	return nil
 }
+
+// stickCurve maps the raw value of a stick axis (-127 to 127) onto -1 to 1 by
+// the given exponent, keeping the sign. Values beyond full deflection (the
+// sticks go to -128) are clamped. A centered stick is always zero. Exponents of
+// zero or less make no sense (they'd map the center to full deflection, or
+// infinity), so are treated as linear.
+func stickCurve(raw float64, exp float64) float64 {
+	if raw == 0 {
+		return 0
+	}
+
+	if exp <= 0 {
+		exp = 1
+	}
+
+	f := math.Max(-1, math.Min(1, raw/127.0))
+	if f < 0 {
+		return -math.Pow(-f, exp)
+	}
+
+	return math.Pow(f, exp)
+}
+
+// turnTowardsTarget rotates the hexapod (at full rotation speed, around its
+// pivot) by the shortest way towards its target rotation, and clears the target
+// once it's reached.
+func (c *Controller) turnTowardsTarget() {
+	diff := utils.NormalizeDeg(*c.hex.TargetRotation - c.hex.Rotation)
+
+	if math.Abs(diff) <= rotationSpeed {
+		if c.hex.Turn(diff) == nil {
+			c.hex.TargetRotation = nil
+		}
+
+		return
+	}
+
+	if diff > 0 {
+		c.hex.Turn(rotationSpeed)
+	} else {
+		c.hex.Turn(-rotationSpeed)
+	}
+}
+
+// selectStance sets the stance which the dpad is pointing at, if it's changed.
+func (c *Controller) selectStance() {
+	if c.Stances == nil {
+		return
+	}
+
+	name := ""
+	switch {
+	case c.hex.Input.Up > 0:
+		name = "tall"
+	case c.hex.Input.Down > 0:
+		name = "low"
+	case c.hex.Input.Right > 0:
+		name = "normal"
+	}
+
+	if name == "" || name == c.stance {
+		return
+	}
+
+	err := c.Stances.SetStance(name)
+	if err != nil {
+		fmt.Printf("error setting stance: %s\n", err)
+		return
+	}
+
+	c.stance = name
+}
+
+// toggleMarch starts or stops marching each time that triangle is pressed.
+func (c *Controller) toggleMarch() {
+	pressed := c.hex.Input.Triangle > 0
+	if c.Marcher == nil || pressed == c.marchButton {
+		c.marchButton = pressed
+		return
+	}
+
+	c.marchButton = pressed
+	if pressed {
+		c.marching = !c.marching
+		c.Marcher.SetMarching(c.marching)
+	}
+}
+
+// smooth passes the movement and rotation from the sticks through a low-pass
+// filter (see Smoothing), and returns the filtered values.
+func (c *Controller) smooth(now time.Time, move math3d.Vector3, turn float64) (math3d.Vector3, float64) {
+	a := 1.0
+	if c.Smoothing > 0 && !c.smoothTime.IsZero() {
+		dt := now.Sub(c.smoothTime).Seconds()
+		a = dt / (c.Smoothing.Seconds() + dt)
+	}
+
+	c.smoothTime = now
+	c.smoothMove = *c.smoothMove.Add(move.Subtract(c.smoothMove).Scale(a))
+	c.smoothTurn += (turn - c.smoothTurn) * a
+
+	if c.smoothMove.Length() < minSmoothed {
+		c.smoothMove = math3d.ZeroVector3
+	}
+
+	if math.Abs(c.smoothTurn) < minSmoothed {
+		c.smoothTurn = 0
+	}
+
+	return c.smoothMove, c.smoothTurn
+}
+
+// holdHeading returns the rotation (in degrees per loop) needed to get back to
+// the heading which the body was measured at when it started walking without
+// turning. Turning (by any means) or stopping releases the heading. Like the
+// right stick, the correction is applied by Turn, so it's around the pivot.
+func (c *Controller) holdHeading(move math3d.Vector3, turn float64) float64 {
+	if c.Heading == nil || move.Zero() || turn != 0 || c.hex.TargetRotation != nil {
+		c.heldHeading = nil
+		return 0
+	}
+
+	h, err := c.Heading.Heading()
+	if err != nil {
+		fmt.Printf("error reading heading: %s\n", err)
+		return 0
+	}
+
+	if c.heldHeading == nil {
+		c.heldHeading = &h
+		c.headingIntegral = 0
+		c.headingError = 0
+		return 0
+	}
+
+	e := utils.NormalizeDeg(*c.heldHeading - h)
+	c.headingIntegral += e
+	d := e - c.headingError
+	c.headingError = e
+
+	out := (c.HeadingP * e) + (c.HeadingI * c.headingIntegral) + (c.HeadingD * d)
+	return math.Max(-rotationSpeed, math.Min(rotationSpeed, out))
+}
+
+// limitSpeed returns the given movement vector (in the hexapod space), shortened
+// if necessary so the body doesn't move faster than MaxSpeed since the previous
+// loop. On the first loop, there's no way to tell, so it doesn't move at all.
+func (c *Controller) limitSpeed(now time.Time, move math3d.Vector3) math3d.Vector3 {
+	last := c.lastTick
+	c.lastTick = now
+
+	if c.MaxSpeed <= 0 {
+		return move
+	}
+
+	if last.IsZero() {
+		return math3d.ZeroVector3
+	}
+
+	max := c.MaxSpeed * now.Sub(last).Seconds()
+	if l := move.Length(); l > max {
+		return move.Scale(max / l)
+	}
+
+	return move
+}
+
+// Limp limits the speed of the sticks to the given fraction of full speed, e.g.
+// to keep walking on a low battery. One is full speed again.
+func (c *Controller) Limp(f float64) {
+	c.limpSpeed = f
+}
+
+// updateLoadSpeed reads the load of the servos (if it's been long enough since
+// the last time), and reduces the speed limit if it's above MaxLoad, or raises
+// it back towards full speed if not.
+func (c *Controller) updateLoadSpeed(now time.Time) {
+	if c.Loads == nil || now.Sub(c.loadTime) < loadInterval {
+		return
+	}
+
+	c.loadTime = now
+	load, err := c.Loads.Load()
+	if err != nil {
+		fmt.Printf("error reading load: %s\n", err)
+		return
+	}
+
+	if load > c.MaxLoad {
+		c.loadSpeed = math.Max(minLoadSpeed, c.loadSpeed*c.LoadSlowdown)
+	} else {
+		c.loadSpeed = math.Min(1, c.loadSpeed/c.LoadSlowdown)
+	}
+}
+
+// updateAngularVelocity moves the rotation speed towards the given speed (in
+// degrees per loop), by no more than MaxAngularAccel.
+func (c *Controller) updateAngularVelocity(target float64) {
+	d := target - c.angularVelocity
+	if c.MaxAngularAccel <= 0 || math.Abs(d) <= c.MaxAngularAccel {
+		c.angularVelocity = target
+		return
+	}
+
+	c.angularVelocity += math.Copysign(c.MaxAngularAccel, d)
+}
+
+// updatePitchBias pitches the body in proportion to the change in forward speed
+// since the last loop, to counteract the inertia of the body. Accelerating
+// forwards lowers the front. The bias decays back to zero when the speed stops
+// changing, and is clamped to MaxAccelPitch. Only the change in bias is
+// applied, so other components are free to pitch the body too.
+func (c *Controller) updatePitchBias(move math3d.Vector3) {
+	accel := move.Z - c.lastMove.Z
+	c.lastMove = move
+
+	bias := (c.pitchBias * accelPitchDecay) + (accel * c.AccelPitchGain)
+	bias = math.Max(-c.MaxAccelPitch, math.Min(c.MaxAccelPitch, bias))
+	c.hex.Pitch += bias - c.pitchBias
+	c.pitchBias = bias
+}
+
+// updateRollBias rolls the body into the turn in proportion to the product of
+// the forward speed and the angular velocity, clamped to MaxBankRoll. Turning
+// right (positive) while walking forwards lowers the right side. Like the pitch
+// bias, only the change in bias is applied.
+func (c *Controller) updateRollBias(move math3d.Vector3, turn float64) {
+	bias := -move.Z * turn * c.BankGain
+	bias = math.Max(-c.MaxBankRoll, math.Min(c.MaxBankRoll, bias))
+	c.hex.Roll += bias - c.rollBias
+	c.rollBias = bias
+}
@@ -0,0 +1,551 @@
+package controller
+
+import (
+	"bytes"
+	"github.com/adammck/hexapod"
+	"github.com/adammck/hexapod/math3d"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestPitchBiasDisabled(t *testing.T) {
+	h := hexapod.NewHexapod(nil)
+	c := New(h, &bytes.Buffer{})
+
+	c.updatePitchBias(math3d.Vector3{0, 0, moveSpeed})
+	if h.Pitch != 0 {
+		t.Errorf("pitched to %0.4f with no gain", h.Pitch)
+	}
+}
+
+func TestPitchBias(t *testing.T) {
+	h := hexapod.NewHexapod(nil)
+	h.Pitch = 3
+	c := New(h, &bytes.Buffer{})
+	c.AccelPitchGain = 2
+
+	// Accelerating forwards should lower the front.
+	c.updatePitchBias(math3d.Vector3{0, 0, moveSpeed})
+	if h.Pitch <= 3 {
+		t.Errorf("accelerating: expected pitch > 3, got %0.4f", h.Pitch)
+	}
+
+	// Holding a constant speed should level out again.
+	for i := 0; i < 200; i++ {
+		c.updatePitchBias(math3d.Vector3{0, 0, moveSpeed})
+	}
+
+	if h.Pitch-3 > 0.0001 {
+		t.Errorf("constant speed: expected pitch to return to 3, got %0.4f", h.Pitch)
+	}
+
+	// Stopping should raise the front.
+	c.updatePitchBias(math3d.Vector3{0, 0, 0})
+	if h.Pitch >= 3 {
+		t.Errorf("stopping: expected pitch < 3, got %0.4f", h.Pitch)
+	}
+}
+
+type stances struct {
+	set []string
+}
+
+func (s *stances) SetStance(name string) error {
+	s.set = append(s.set, name)
+	return nil
+}
+
+func TestSelectStance(t *testing.T) {
+	h := hexapod.NewHexapod(nil)
+	c := New(h, &bytes.Buffer{})
+	s := &stances{}
+	c.Stances = s
+
+	// Nothing pressed.
+	c.selectStance()
+
+	// Holding up should only set the stance once.
+	h.Input.Up = 100
+	c.selectStance()
+	c.selectStance()
+	h.Input.Up = 0
+
+	h.Input.Down = 100
+	c.selectStance()
+	h.Input.Down = 0
+
+	h.Input.Right = 100
+	c.selectStance()
+
+	exp := []string{"tall", "low", "normal"}
+	if len(s.set) != len(exp) {
+		t.Fatalf("got stances %v, expected %v", s.set, exp)
+	}
+
+	for i := range exp {
+		if s.set[i] != exp[i] {
+			t.Errorf("got stances %v, expected %v", s.set, exp)
+		}
+	}
+}
+
+type marcher struct {
+	set []bool
+}
+
+func (m *marcher) SetMarching(marching bool) {
+	m.set = append(m.set, marching)
+}
+
+func TestToggleMarch(t *testing.T) {
+	h := hexapod.NewHexapod(nil)
+	c := New(h, &bytes.Buffer{})
+	m := &marcher{}
+	c.Marcher = m
+
+	// Each press (however long) toggles it once.
+	for _, p := range []int{0, 100, 100, 0, 0, 50, 0, 100} {
+		h.Input.Triangle = p
+		c.toggleMarch()
+	}
+
+	exp := []bool{true, false, true}
+	if len(m.set) != len(exp) {
+		t.Fatalf("got %v, expected %v", m.set, exp)
+	}
+
+	for i := range exp {
+		if m.set[i] != exp[i] {
+			t.Errorf("got %v, expected %v", m.set, exp)
+		}
+	}
+}
+
+func TestAngularVelocity(t *testing.T) {
+	h := hexapod.NewHexapod(nil)
+	c := New(h, &bytes.Buffer{})
+	c.MaxAngularAccel = 0.1
+
+	// Full right stick should ramp up to full speed.
+	h.Input.RightStick.X = 127
+	for i := 0; i < 20; i++ {
+		c.Tick(time.Time{})
+	}
+
+	if c.angularVelocity != rotationSpeed {
+		t.Fatalf("expected full speed after 20 loops, got %0.4f", c.angularVelocity)
+	}
+
+	// Releasing the stick should slow down over several loops, rather than
+	// stopping immediately.
+	h.Input.RightStick.X = 0
+	loops := 0
+	for ; loops < 100 && c.angularVelocity != 0; loops++ {
+		c.Tick(time.Time{})
+	}
+
+	if loops < 5 {
+		t.Errorf("stopped rotating after %d loops, expected a gradual stop", loops)
+	}
+
+	if loops == 100 {
+		t.Errorf("still rotating at %0.4f", c.angularVelocity)
+	}
+}
+
+func TestAngularVelocityUnlimited(t *testing.T) {
+	h := hexapod.NewHexapod(nil)
+	c := New(h, &bytes.Buffer{})
+	c.MaxAngularAccel = 0
+
+	h.Input.RightStick.X = 127
+	c.Tick(time.Time{})
+	h.Input.RightStick.X = 0
+	c.Tick(time.Time{})
+
+	if h.Rotation != rotationSpeed {
+		t.Errorf("expected to rotate %0.2f then stop, got %0.4f", rotationSpeed, h.Rotation)
+	}
+}
+
+func TestSmoothing(t *testing.T) {
+	h := hexapod.NewHexapod(nil)
+	c := New(h, &bytes.Buffer{})
+	c.Smoothing = 100 * time.Millisecond
+
+	now := time.Unix(0, 0)
+	c.smooth(now, math3d.Vector3{}, 0)
+
+	// A sudden full push should be let through gradually.
+	full := math3d.Vector3{0, 0, moveSpeed}
+	last := 0.0
+	for i := 0; i < 5; i++ {
+		now = now.Add(time.Second / 60)
+		m, turn := c.smooth(now, full, rotationSpeed)
+		if m.Z <= last || m.Z >= moveSpeed || turn >= rotationSpeed {
+			t.Errorf("loop %d: got move=%0.4f turn=%0.4f", i, m.Z, turn)
+		}
+
+		last = m.Z
+	}
+
+	// Eventually it should settle, and stop entirely when released.
+	for i := 0; i < 200; i++ {
+		now = now.Add(time.Second / 60)
+		c.smooth(now, full, rotationSpeed)
+	}
+
+	if m, _ := c.smooth(now.Add(time.Second/60), full, rotationSpeed); full.Distance(m) > 0.001 {
+		t.Errorf("didn't settle at full speed: %s", m)
+	}
+
+	for i := 0; i < 200; i++ {
+		now = now.Add(time.Second / 60)
+		c.smooth(now, math3d.Vector3{}, 0)
+	}
+
+	if m, turn := c.smooth(now.Add(time.Second/60), math3d.Vector3{}, 0); !m.Zero() || turn != 0 {
+		t.Errorf("still moving after release: move=%s turn=%0.6f", m, turn)
+	}
+}
+
+func TestSmoothingDisabled(t *testing.T) {
+	c := New(hexapod.NewHexapod(nil), &bytes.Buffer{})
+	now := time.Unix(0, 0)
+	c.smooth(now, math3d.Vector3{}, 0)
+
+	m, turn := c.smooth(now.Add(time.Second/60), math3d.Vector3{1, 0, 1}, 0.5)
+	if m != (math3d.Vector3{1, 0, 1}) || turn != 0.5 {
+		t.Errorf("input was smoothed while disabled: move=%s turn=%0.4f", m, turn)
+	}
+}
+
+func TestPitchBiasProportional(t *testing.T) {
+	pitch := func(accel float64) float64 {
+		h := hexapod.NewHexapod(nil)
+		c := New(h, &bytes.Buffer{})
+		c.AccelPitchGain = 2
+		c.updatePitchBias(math3d.Vector3{0, 0, accel})
+		return h.Pitch
+	}
+
+	// Harder acceleration should pitch further forward, up to the limit.
+	last := 0.0
+	for _, a := range []float64{0.25, 0.5, 1, 1.5} {
+		if p := pitch(a); p <= last {
+			t.Errorf("accel %0.2f: pitch %0.4f isn't more than %0.4f", a, p, last)
+		} else {
+			last = p
+		}
+	}
+
+	if p := pitch(100); p != defaultMaxAccelPitch {
+		t.Errorf("expected pitch to be clamped to %0.1f, got %0.4f", defaultMaxAccelPitch, p)
+	}
+
+	if p := pitch(-100); p != -defaultMaxAccelPitch {
+		t.Errorf("expected pitch to be clamped to %0.1f, got %0.4f", -defaultMaxAccelPitch, p)
+	}
+}
+
+// compass is a HeadingSource which follows the rotation of the hexapod, plus a
+// drift which is added every time that it's read.
+type compass struct {
+	h     *hexapod.Hexapod
+	drift float64
+	total float64
+}
+
+func (c *compass) Heading() (float64, error) {
+	c.total += c.drift
+	return c.h.Rotation + c.total, nil
+}
+
+func TestHoldHeading(t *testing.T) {
+	h := hexapod.NewHexapod(nil)
+	c := New(h, &bytes.Buffer{})
+	c.Heading = &compass{h: h, drift: 0.2}
+	c.HeadingP = 0.5
+	c.HeadingI = 0.05
+	fwd := math3d.Vector3{0, 0, moveSpeed}
+
+	// Walk forwards while slipping to the right, and apply the correction.
+	for i := 0; i < 300; i++ {
+		h.Rotation += c.holdHeading(fwd, 0)
+	}
+
+	if e := c.headingError; e > 0.01 || e < -0.01 {
+		t.Errorf("heading is off by %0.4f deg", e)
+	}
+
+	// Turning releases the heading.
+	if r := c.holdHeading(fwd, 0.5); r != 0 || c.heldHeading != nil {
+		t.Errorf("expected no correction while turning, got %0.4f", r)
+	}
+
+	// As does stopping.
+	c.holdHeading(fwd, 0)
+	if r := c.holdHeading(math3d.Vector3{}, 0); r != 0 || c.heldHeading != nil {
+		t.Errorf("expected no correction while stopped, got %0.4f", r)
+	}
+}
+
+func TestHoldHeadingDisabled(t *testing.T) {
+	h := hexapod.NewHexapod(nil)
+	c := New(h, &bytes.Buffer{})
+	if r := c.holdHeading(math3d.Vector3{0, 0, moveSpeed}, 0); r != 0 {
+		t.Errorf("expected no correction without a heading source, got %0.4f", r)
+	}
+}
+
+func TestLimitSpeed(t *testing.T) {
+	h := hexapod.NewHexapod(nil)
+	c := New(h, &bytes.Buffer{})
+	fast := math3d.Vector3{3, 0, 4}
+	now := time.Unix(100, 0)
+
+	// No limit by default.
+	if m := c.limitSpeed(now, fast); m != fast {
+		t.Errorf("got %s, expected %s", m, fast)
+	}
+
+	// 60 mm/s is 1 mm per loop at 60Hz.
+	c.MaxSpeed = 60
+	now = now.Add(time.Second / 60)
+	m := c.limitSpeed(now, fast)
+	if l := m.Length(); l < 0.9999 || l > 1.0001 {
+		t.Errorf("moved %0.4f mm, expected 1", l)
+	}
+
+	if m.Unit().Distance(fast.Unit()) > 0.0001 {
+		t.Errorf("direction changed from %s to %s", fast, m)
+	}
+
+	// Slow moves aren't changed.
+	slow := math3d.Vector3{0.3, 0, 0.4}
+	now = now.Add(time.Second / 60)
+	if m := c.limitSpeed(now, slow); m != slow {
+		t.Errorf("got %s, expected %s", m, slow)
+	}
+}
+
+// loads is a LoadSource which returns a fixed load.
+type loads float64
+
+func (l *loads) Load() (float64, error) {
+	return float64(*l), nil
+}
+
+func TestLoadSpeed(t *testing.T) {
+	h := hexapod.NewHexapod(nil)
+	c := New(h, &bytes.Buffer{})
+	load := loads(0.9)
+	c.Loads = &load
+	now := time.Unix(100, 0)
+
+	// The load is too high, so slow down, but not all the way.
+	for i := 0; i < 20; i++ {
+		now = now.Add(loadInterval)
+		c.updateLoadSpeed(now)
+	}
+
+	if c.loadSpeed != minLoadSpeed {
+		t.Errorf("expected speed to bottom out at %0.2f, got %0.2f", minLoadSpeed, c.loadSpeed)
+	}
+
+	// Not read again too soon.
+	load = 0.1
+	c.updateLoadSpeed(now.Add(loadInterval / 2))
+	if c.loadSpeed != minLoadSpeed {
+		t.Errorf("expected speed not to change before the interval, got %0.2f", c.loadSpeed)
+	}
+
+	// Recover once the load drops.
+	c.updateLoadSpeed(now.Add(loadInterval))
+	if c.loadSpeed <= minLoadSpeed || c.loadSpeed >= 1 {
+		t.Errorf("expected speed to start recovering, got %0.2f", c.loadSpeed)
+	}
+
+	for i := 0; i < 20; i++ {
+		now = now.Add(loadInterval)
+		c.updateLoadSpeed(now)
+	}
+
+	if c.loadSpeed != 1 {
+		t.Errorf("expected full speed again, got %0.2f", c.loadSpeed)
+	}
+}
+
+func TestLimp(t *testing.T) {
+	h := hexapod.NewHexapod(nil)
+	c := New(h, &bytes.Buffer{})
+	c.MaxAngularAccel = 0
+	c.Limp(0.5)
+
+	h.Input.LeftStick.Y = -127
+	h.Input.RightStick.X = 127
+	c.Tick(time.Time{})
+
+	if math.Abs(h.Position.Length()-(moveSpeed/2)) > 0.0001 || h.Rotation != rotationSpeed/2 {
+		t.Errorf("moved %s and turned %0.4f deg, expected half speed", h.Position, h.Rotation)
+	}
+}
+
+func TestStickCurve(t *testing.T) {
+	type example struct {
+		raw float64
+		exp float64
+		out float64
+	}
+
+	data := []example{
+
+		// Linear, like it used to be.
+		example{0, 1, 0},
+		example{63.5, 1, 0.5},
+		example{127, 1, 1},
+		example{-127, 1, -1},
+
+		// The ends stay put, but the middle is finer.
+		example{0, 3, 0},
+		example{63.5, 3, 0.125},
+		example{127, 3, 1},
+		example{-63.5, 3, -0.125},
+		example{-127, 3, -1},
+
+		// Past full deflection is clamped.
+		example{-128, 2, -1},
+
+		// Silly exponents are linear, and centered is always zero.
+		example{0, 0, 0},
+		example{63.5, 0, 0.5},
+		example{0, -1, 0},
+		example{-127, -1, -1},
+		example{63.5, -2, 0.5},
+	}
+
+	for i, eg := range data {
+		if out := stickCurve(eg.raw, eg.exp); math.Abs(out-eg.out) > 0.000001 {
+			t.Errorf("Example #%d: got %0.4f, expected %0.4f", i+1, out, eg.out)
+		}
+	}
+}
+
+func TestTurnCurveZero(t *testing.T) {
+	h := hexapod.NewHexapod(nil)
+	c := New(h, &bytes.Buffer{})
+	c.TurnCurve = 0
+	c.MaxAngularAccel = 0
+
+	// A centered right stick mustn't turn, whatever the curve.
+	c.Tick(time.Time{})
+	if h.Rotation != 0 {
+		t.Errorf("turned to %0.2f with the stick centered", h.Rotation)
+	}
+}
+
+func TestForwardCurve(t *testing.T) {
+	h := hexapod.NewHexapod(nil)
+	c := New(h, &bytes.Buffer{})
+	c.ForwardCurve = 2
+
+	// Half forward gives a quarter speed, but only on the forward axis.
+	h.Input.LeftStick.X = 0
+	h.Input.LeftStick.Y = -63
+	c.Tick(time.Time{})
+
+	exp := math.Pow(63.0/127.0, 2) * moveSpeed
+	if math.Abs(h.Position.Z-exp) > 0.000001 || h.Position.X != 0 {
+		t.Errorf("moved to %s, expected Z=%0.4f", h.Position, exp)
+	}
+}
+
+func TestBankDisabled(t *testing.T) {
+	h := hexapod.NewHexapod(nil)
+	c := New(h, &bytes.Buffer{})
+	h.Input.LeftStick.Y = -127
+	h.Input.RightStick.X = 127
+
+	for i := 0; i < 10; i++ {
+		c.Tick(time.Time{})
+	}
+
+	if h.Roll != 0 {
+		t.Errorf("rolled to %0.4f with no gain", h.Roll)
+	}
+}
+
+func TestBank(t *testing.T) {
+	roll := func(forward int, turn int) float64 {
+		h := hexapod.NewHexapod(nil)
+		h.Roll = 1
+		c := New(h, &bytes.Buffer{})
+		c.BankGain = 2
+		h.Input.LeftStick.Y = -forward
+		h.Input.RightStick.X = turn
+
+		for i := 0; i < 10; i++ {
+			c.Tick(time.Time{})
+		}
+
+		return h.Roll - 1
+	}
+
+	// Turning right while walking forwards should lower the right side, and
+	// turning left should lower the left.
+	if r := roll(127, 127); r >= 0 {
+		t.Errorf("forward and right: expected negative roll, got %0.4f", r)
+	}
+
+	if r := roll(127, -127); r <= 0 {
+		t.Errorf("forward and left: expected positive roll, got %0.4f", r)
+	}
+
+	// Either on its own shouldn't roll at all.
+	if r := roll(127, 0); r != 0 {
+		t.Errorf("forward only: expected no roll, got %0.4f", r)
+	}
+
+	if r := roll(0, 127); r != 0 {
+		t.Errorf("turn only: expected no roll, got %0.4f", r)
+	}
+}
+
+func TestBankClamped(t *testing.T) {
+	h := hexapod.NewHexapod(nil)
+	c := New(h, &bytes.Buffer{})
+	c.BankGain = 1000
+
+	c.updateRollBias(math3d.Vector3{0, 0, moveSpeed}, rotationSpeed)
+	if h.Roll != -defaultMaxBankRoll {
+		t.Errorf("expected roll to be clamped to %0.1f, got %0.4f", -defaultMaxBankRoll, h.Roll)
+	}
+
+	// Straightening out should level the body again.
+	c.updateRollBias(math3d.Vector3{0, 0, moveSpeed}, 0)
+	if h.Roll != 0 {
+		t.Errorf("expected roll to return to zero, got %0.4f", h.Roll)
+	}
+}
+
+func TestStepInput(t *testing.T) {
+	h := hexapod.NewHexapod(nil)
+	c := New(h, &bytes.Buffer{})
+	h.Add(c)
+
+	// Push forwards for a few frames, then let go.
+	for i := 0; i < 3; i++ {
+		h.Step(hexapod.InputState{LeftStick: hexapod.Stick{0, -127}})
+	}
+
+	h.Step(hexapod.InputState{})
+	if exp := 3 * moveSpeed; math.Abs(h.Position.Z-exp) > 0.000001 {
+		t.Errorf("moved to %s, expected Z=%0.2f", h.Position, exp)
+	}
+
+	// The sixaxis is centered, since nothing is plugged in.
+	if in := c.Input(); in != (hexapod.InputState{}) {
+		t.Errorf("got %+v from an idle sixaxis", in)
+	}
+}
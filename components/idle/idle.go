@@ -0,0 +1,196 @@
+package idle
+
+import (
+	"github.com/adammck/hexapod"
+	"github.com/adammck/hexapod/math3d"
+	"math"
+	"time"
+)
+
+const (
+
+	// The number of seconds which the hexapod must be standing still before the
+	// animation starts.
+	delay = 5
+
+	// The number of seconds between picking each new pose.
+	interval = 2
+
+	// The maximum distance (in mm) to shift the body on the X/Z axis. This must
+	// be well under the minimum step distance, or the legs will start stepping
+	// to keep up, which isn't very idle.
+	maxShift = 8.0
+
+	// The maximum distance (in mm) to bob the body up and down.
+	maxBob = 6.0
+
+	// The maximum pitch and roll (in degrees) to lean the body.
+	maxLean = 2.0
+
+	// The fraction of the remaining distance to the next pose to move each tick.
+	// Lower is gentler.
+	easing = 0.05
+
+	// Changes to the body smaller than this are assumed to be rounding errors,
+	// rather than input.
+	epsilon = 0.000001
+)
+
+type CanStand interface {
+	Standing() bool
+}
+
+// Croucher is implemented by components (i.e. the legs) which can lower the body
+// to a resting height, and raise it again.
+type Croucher interface {
+	SetCrouched(crouched bool)
+}
+
+// pose is an offset from wherever the body would be, were it not idling.
+type pose struct {
+	offset math3d.Vector3
+	pitch  float64
+	roll   float64
+}
+
+type Idle struct {
+	hex *hexapod.Hexapod
+	CanStand
+
+	// Whether to animate at all. When this is false, the component does nothing
+	// except keep track of input (and crouch).
+	Enabled bool
+
+	// What to crouch, and how long after the last input to do so, to rest the
+	// servos when nobody is driving. The body is raised again as soon as there's
+	// any input. Crouching is disabled if either is zero.
+	Crouch      Croucher
+	CrouchDelay time.Duration
+	crouched    bool
+
+	// The offset currently applied to the body, and the one we're easing to.
+	applied    pose
+	target     pose
+	targetTime time.Time
+
+	// The pose of the body (minus the applied offset) as of the last tick, to
+	// spot changes made by anything else.
+	basePos   math3d.Vector3
+	baseRot   float64
+	basePitch float64
+	baseRoll  float64
+
+	// The last time that anything else moved the body.
+	lastInput time.Time
+}
+
+// New creates an idle animation for the given hexapod. The poses are picked
+// with the randomness of the hexapod, so seeding it (see hexapod.Seed) will
+// always produce the same animation.
+func New(hex *hexapod.Hexapod, s CanStand) *Idle {
+	return &Idle{
+		hex:      hex,
+		CanStand: s,
+		Enabled:  true,
+	}
+}
+
+func (i *Idle) Boot() error {
+	return nil
+}
+
+func (i *Idle) Tick(now time.Time) error {
+	base := *i.hex.Position.Subtract(i.applied.offset)
+	basePitch := i.hex.Pitch - i.applied.pitch
+	baseRoll := i.hex.Roll - i.applied.roll
+
+	// If anything else has moved the body since the last tick, it's probably
+	// because of input. Get out of the way immediately. The first tick counts as
+	// input, to start the timer.
+	input := i.lastInput.IsZero() || i.moved(base, basePitch, baseRoll) || !i.Standing()
+	if input {
+		i.lastInput = now
+	}
+
+	i.crouch(now)
+
+	if input || !i.Enabled {
+		i.apply(base, basePitch, baseRoll, pose{})
+		i.target = pose{}
+		i.targetTime = time.Time{}
+		return nil
+	}
+
+	if now.Sub(i.lastInput) < (delay * time.Second) {
+		return nil
+	}
+
+	if now.After(i.targetTime) {
+		i.target = i.randomPose()
+		i.targetTime = now.Add(interval * time.Second)
+	}
+
+	i.apply(base, basePitch, baseRoll, pose{
+		offset: *i.applied.offset.Add(i.target.offset.Subtract(i.applied.offset).Scale(easing)),
+		pitch:  i.applied.pitch + ((i.target.pitch - i.applied.pitch) * easing),
+		roll:   i.applied.roll + ((i.target.roll - i.applied.roll) * easing),
+	})
+
+	return nil
+}
+
+// crouch tells the Croucher to crouch once there's been no input for a while,
+// and to stand back up once there is.
+func (i *Idle) crouch(now time.Time) {
+	if i.Crouch == nil || i.CrouchDelay <= 0 {
+		return
+	}
+
+	c := now.Sub(i.lastInput) >= i.CrouchDelay
+	if c != i.crouched {
+		i.Crouch.SetCrouched(c)
+		i.crouched = c
+	}
+}
+
+// apply moves the body to the given base pose plus the given offset, and keeps
+// track of both so we can spot changes on the next tick.
+func (i *Idle) apply(base math3d.Vector3, basePitch float64, baseRoll float64, p pose) {
+	i.hex.Position = *base.Add(p.offset)
+	i.hex.Pitch = basePitch + p.pitch
+	i.hex.Roll = baseRoll + p.roll
+	i.applied = p
+
+	i.basePos = base
+	i.baseRot = i.hex.Rotation
+	i.basePitch = basePitch
+	i.baseRoll = baseRoll
+}
+
+// moved returns true if the given base pose differs from the one we left the
+// body in at the end of the last tick. Removing the offset doesn't always get
+// back exactly the same floats, so allow a little slack.
+func (i *Idle) moved(base math3d.Vector3, basePitch float64, baseRoll float64) bool {
+	return base.Distance(i.basePos) > epsilon ||
+		math.Abs(i.hex.Rotation-i.baseRot) > epsilon ||
+		math.Abs(basePitch-i.basePitch) > epsilon ||
+		math.Abs(baseRoll-i.baseRoll) > epsilon
+}
+
+// randomPose returns a random pose within the limits.
+func (i *Idle) randomPose() pose {
+	return pose{
+		offset: math3d.Vector3{
+			i.between(maxShift),
+			i.between(maxBob),
+			i.between(maxShift),
+		},
+		pitch: i.between(maxLean),
+		roll:  i.between(maxLean),
+	}
+}
+
+// between returns a random float between -n and n.
+func (i *Idle) between(n float64) float64 {
+	return ((i.hex.Rand.Float64() * 2) - 1) * n
+}
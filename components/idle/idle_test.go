@@ -0,0 +1,131 @@
+package idle
+
+import (
+	"github.com/adammck/hexapod"
+	"github.com/adammck/hexapod/math3d"
+	"testing"
+	"time"
+)
+
+type standing bool
+
+func (s standing) Standing() bool {
+	return bool(s)
+}
+
+// run ticks the component once per frame for the given duration, starting at
+// the given time, and returns the time afterwards.
+func run(i *Idle, start time.Time, d time.Duration) time.Time {
+	now := start
+	for end := start.Add(d); now.Before(end); now = now.Add(time.Second / 60) {
+		i.Tick(now)
+	}
+
+	return now
+}
+
+func TestIdleAnimates(t *testing.T) {
+	h := hexapod.NewHexapod(nil)
+	h.Seed(1)
+	i := New(h, standing(true))
+
+	now := run(i, time.Unix(0, 0), (delay-1)*time.Second)
+	if !h.Position.Zero() || h.Pitch != 0 || h.Roll != 0 {
+		t.Errorf("moved before delay: %s", h.Position)
+	}
+
+	run(i, now, 2*time.Second)
+	if h.Position.Zero() {
+		t.Errorf("didn't move after delay")
+	}
+
+	if !i.lastInput.Equal(time.Unix(0, 0)) {
+		t.Errorf("own movement was mistaken for input at %s", i.lastInput)
+	}
+}
+
+func TestIdleYieldsToInput(t *testing.T) {
+	h := hexapod.NewHexapod(nil)
+	h.Seed(1)
+	i := New(h, standing(true))
+	now := run(i, time.Unix(0, 0), (delay+2)*time.Second)
+
+	// Move the body, like the controller would.
+	h.Position = *h.Position.Add(math3d.Vector3{1.5, 0, 0})
+	i.Tick(now)
+
+	// The animation offset should have been removed immediately, leaving only
+	// the input.
+	exp := math3d.Vector3{1.5, 0, 0}
+	if h.Position.Distance(exp) > 0.000001 || h.Pitch != 0 || h.Roll != 0 {
+		t.Errorf("got %s (p=%0.2f, r=%0.2f), expected %s", h.Position, h.Pitch, h.Roll, exp)
+	}
+
+	// And it shouldn't start again until the delay has passed.
+	now = run(i, now.Add(time.Second/60), (delay-1)*time.Second)
+	if h.Position.Distance(exp) > 0.000001 {
+		t.Errorf("moved again before delay: %s", h.Position)
+	}
+}
+
+func TestIdleDisabled(t *testing.T) {
+	h := hexapod.NewHexapod(nil)
+	h.Seed(1)
+	i := New(h, standing(true))
+	i.Enabled = false
+
+	run(i, time.Unix(0, 0), (delay+2)*time.Second)
+	if !h.Position.Zero() {
+		t.Errorf("moved while disabled: %s", h.Position)
+	}
+}
+
+func TestIdleReproducible(t *testing.T) {
+	a := hexapod.NewHexapod(nil)
+	b := hexapod.NewHexapod(nil)
+	a.Seed(42)
+	b.Seed(42)
+	run(New(a, standing(true)), time.Unix(0, 0), 10*time.Second)
+	run(New(b, standing(true)), time.Unix(0, 0), 10*time.Second)
+
+	if a.Position != b.Position || a.Pitch != b.Pitch || a.Roll != b.Roll {
+		t.Errorf("same seed produced different poses: %s, %s", a.Position, b.Position)
+	}
+}
+
+type croucher struct {
+	crouched bool
+	calls    int
+}
+
+func (c *croucher) SetCrouched(crouched bool) {
+	c.crouched = crouched
+	c.calls += 1
+}
+
+func TestIdleCrouch(t *testing.T) {
+	h := hexapod.NewHexapod(nil)
+	c := &croucher{}
+	h.Seed(1)
+	i := New(h, standing(true))
+	i.Enabled = false
+	i.Crouch = c
+	i.CrouchDelay = 10 * time.Second
+
+	now := run(i, time.Unix(0, 0), 9*time.Second)
+	if c.crouched {
+		t.Errorf("crouched before the delay")
+	}
+
+	now = run(i, now, 2*time.Second)
+	if !c.crouched || c.calls != 1 {
+		t.Errorf("expected to crouch once after the delay, got %d calls", c.calls)
+	}
+
+	// Any input should stand it straight back up.
+	h.Position = *h.Position.Add(math3d.Vector3{0, 0, 1})
+	i.Tick(now)
+	if c.crouched || c.calls != 2 {
+		t.Errorf("expected to stand up after input, got %d calls", c.calls)
+	}
+}
@@ -0,0 +1,78 @@
+package legs
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"time"
+)
+
+// AngleLog writes the goal of every foot (in the hexapod space) and the angle
+// last sent to every servo as CSV, one row per tick, for analysing gaits.
+type AngleLog struct {
+	w      *csv.Writer
+	header bool
+}
+
+func NewAngleLog(w io.Writer) *AngleLog {
+	return &AngleLog{
+		w: csv.NewWriter(w),
+	}
+}
+
+// write appends a row for the given legs at the given time, preceded by the
+// header row if this is the first.
+func (a *AngleLog) write(l *Legs, now time.Time) error {
+	if !a.header {
+		row := []string{"time"}
+		for _, leg := range l.Legs {
+			for _, axis := range []string{"x", "y", "z"} {
+				row = append(row, fmt.Sprintf("%s_%s", leg.Name, axis))
+			}
+
+			for _, joint := range jointNames {
+				row = append(row, fmt.Sprintf("%s_%s", leg.Name, joint))
+			}
+		}
+
+		a.w.Write(row)
+		a.header = true
+	}
+
+	row := []string{fmt.Sprintf("%.3f", float64(now.UnixNano())/1e9)}
+	for i, leg := range l.Legs {
+		g := l.footGoal(i)
+		row = append(row, ftoa(g.X), ftoa(g.Y), ftoa(g.Z))
+
+		// Servos which haven't been sent a goal are left blank.
+		for j := range leg.goals {
+			if leg.goalKnown[j] {
+				row = append(row, ftoa(leg.goals[j]))
+			} else {
+				row = append(row, "")
+			}
+		}
+	}
+
+	a.w.Write(row)
+	a.w.Flush()
+	return a.w.Error()
+}
+
+func ftoa(f float64) string {
+	return fmt.Sprintf("%.2f", f)
+}
+
+// logAngles writes a row to the AngleLog, if there is one. If that fails, it's
+// turned off, rather than spamming the same error every tick.
+func (l *Legs) logAngles(now time.Time) {
+	if l.AngleLog == nil {
+		return
+	}
+
+	err := l.AngleLog.write(l, now)
+	if err != nil {
+		fmt.Printf("error logging angles (turning it off): %s\n", err)
+		l.AngleLog = nil
+	}
+}
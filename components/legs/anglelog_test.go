@@ -0,0 +1,49 @@
+package legs
+
+import (
+	"bytes"
+	"encoding/csv"
+	"github.com/adammck/hexapod"
+	"testing"
+	"time"
+)
+
+func TestAngleLog(t *testing.T) {
+	h := hexapod.NewHexapod(nil)
+	l, _, m := mockLegs(h)
+	runInit(l, func(int) float64 { return 12 }, m)
+	l.SetState(StateStand)
+
+	buf := &bytes.Buffer{}
+	l.AngleLog = NewAngleLog(buf)
+	l.Tick(time.Unix(100, 0))
+	l.Tick(time.Unix(101, 0))
+
+	rows, err := csv.NewReader(buf).ReadAll()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if len(rows) != 3 {
+		t.Fatalf("expected header and two rows, got %d rows", len(rows))
+	}
+
+	// Time, then three coordinates and four angles per leg.
+	if n := 1 + (6 * 7); len(rows[0]) != n || len(rows[1]) != n {
+		t.Errorf("expected %d columns, got %d and %d", n, len(rows[0]), len(rows[1]))
+	}
+
+	if rows[0][1] != "FL_x" || rows[0][4] != "FL_coxa" {
+		t.Errorf("unexpected header: %v", rows[0])
+	}
+
+	if rows[2][0] != "101.000" {
+		t.Errorf("expected time 101.000, got %s", rows[2][0])
+	}
+
+	// The last sent angle of the first servo.
+	exp := ftoa(m[0][0].moves[len(m[0][0].moves)-1])
+	if rows[2][4] != exp {
+		t.Errorf("expected FL coxa %s, got %s", exp, rows[2][4])
+	}
+}
@@ -0,0 +1,35 @@
+package legs
+
+import (
+	"time"
+)
+
+// ankleCompliance returns the extra compliance (in degrees per N*m) which the
+// tarsus of the given leg should have right now. It's AnkleCompliance while the
+// foot is being put down, and for AnkleCushion after it lands, so the ankle
+// gives to soften the impact. Otherwise it's zero, so the tarsus is as stiff as
+// usual to bear the weight of the body.
+func (l *Legs) ankleCompliance(i int, now time.Time) float64 {
+	if l.AnkleCompliance == 0 {
+		return 0
+	}
+
+	if l.State == StateStepDown && l.stateCounter < stepDownCount && l.swinging(i) {
+		return l.AnkleCompliance
+	}
+
+	if t := l.footLanded[i]; !t.IsZero() && now.Sub(t) < l.AnkleCushion {
+		return l.AnkleCompliance
+	}
+
+	return 0
+}
+
+// land records that the feet of the current leg set touched down at the given
+// time. See ankleCompliance.
+func (l *Legs) land(now time.Time) {
+	l.landedTime = now
+	for _, ii := range l.legSet()[l.sLegsIndex] {
+		l.footLanded[ii] = now
+	}
+}
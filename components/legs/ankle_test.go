@@ -0,0 +1,68 @@
+package legs
+
+import (
+	"github.com/adammck/hexapod"
+	"github.com/adammck/hexapod/math3d"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestAnkleCompliance(t *testing.T) {
+	c := &hexapod.FakeClock{T: time.Unix(100, 0)}
+	h := hexapod.NewHexapod(nil)
+	h.Clock = c
+	l := New(h, nil)
+	l.SetGait(TripodGait)
+	l.SetState(StateStepDown)
+
+	set := l.legSet()[0]
+	other := l.legSet()[1][0]
+
+	// Disabled by default.
+	l.stateCounter = 1
+	if a := l.ankleCompliance(set[0], c.Now()); a != 0 {
+		t.Errorf("disabled: got %0.2f, expected 0", a)
+	}
+
+	l.AnkleCompliance = 5
+	l.AnkleCushion = 100 * time.Millisecond
+
+	// Soft while the foot is being put down, but only for the legs which are
+	// stepping.
+	if a := l.ankleCompliance(set[0], c.Now()); a != 5 {
+		t.Errorf("stepping down: got %0.2f, expected 5", a)
+	}
+
+	if a := l.ankleCompliance(other, c.Now()); a != 0 {
+		t.Errorf("planted leg: got %0.2f, expected 0", a)
+	}
+
+	// Still soft for a while after landing.
+	l.stateCounter = stepDownCount
+	l.land(c.Now())
+	c.Advance(50 * time.Millisecond)
+	if a := l.ankleCompliance(set[0], c.Now()); a != 5 {
+		t.Errorf("just landed: got %0.2f, expected 5", a)
+	}
+
+	// Then stiff again, to bear the weight.
+	c.Advance(50 * time.Millisecond)
+	if a := l.ankleCompliance(set[0], c.Now()); a != 0 {
+		t.Errorf("after cushion: got %0.2f, expected 0", a)
+	}
+}
+
+func TestComplyAnkle(t *testing.T) {
+	leg := &Leg{Origin: &math3d.Vector3{0, 0, 0}, Angle: 0}
+	m := mockLeg(leg)
+	m[3].load = encodeLoad(750)
+	a := JointAngles{10, 20, 30, 40}
+
+	// The extra compliance is on top of the leg's own.
+	leg.Compliance = JointAngles{Tarsus: 2}
+	leg.ankleCompliance = 2
+	if act := leg.comply(a); math.Abs(act.Tarsus-37) > 0.01 {
+		t.Errorf("got tarsus %0.2f, expected 37", act.Tarsus)
+	}
+}
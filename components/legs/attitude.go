@@ -0,0 +1,54 @@
+package legs
+
+import (
+	"fmt"
+	"github.com/adammck/hexapod/utils"
+	"math"
+)
+
+// AttitudeSource is something (e.g. an IMU) which can measure the pitch and roll
+// of the body relative to the ground, in degrees.
+type AttitudeSource interface {
+	Attitude() (pitch float64, roll float64, err error)
+}
+
+// Tilt returns the angle (in degrees) between the measured up vector of the body
+// and the real up vector, i.e. how far it's leaning in any direction.
+func Tilt(pitch float64, roll float64) float64 {
+	return utils.Deg(math.Acos(math.Cos(utils.Rad(pitch)) * math.Cos(utils.Rad(roll))))
+}
+
+// canStep returns false if lifting the next leg set would leave too few feet on
+// the ground (see minSupport), or if the measured attitude of the body is beyond
+// the tilt limit (or can't be measured), in which case stepping is unsafe. The
+// operator is warned the first time that this happens.
+func (l *Legs) canStep() bool {
+	if !l.supported() {
+		return false
+	}
+
+	if l.Attitude == nil || l.MaxTilt == 0 {
+		return true
+	}
+
+	pitch, roll, err := l.Attitude.Attitude()
+	if err != nil {
+		l.warnTilt(fmt.Sprintf("error reading attitude: %s", err))
+		return false
+	}
+
+	if t := Tilt(pitch, roll); t > l.MaxTilt {
+		l.warnTilt(fmt.Sprintf("tilted %0.1f deg (max %0.1f); refusing to step", t, l.MaxTilt))
+		return false
+	}
+
+	l.tiltWarned = false
+	return true
+}
+
+func (l *Legs) warnTilt(msg string) {
+	if !l.tiltWarned {
+		fmt.Printf("WARNING: %s\n", msg)
+		l.tiltWarned = true
+	}
+}
@@ -0,0 +1,70 @@
+package legs
+
+import (
+	"github.com/adammck/hexapod"
+	"math"
+	"testing"
+)
+
+type fixedAttitude struct {
+	pitch float64
+	roll  float64
+}
+
+func (a fixedAttitude) Attitude() (float64, float64, error) {
+	return a.pitch, a.roll, nil
+}
+
+func TestTilt(t *testing.T) {
+	type example struct {
+		pitch float64
+		roll  float64
+		exp   float64
+	}
+
+	data := []example{
+		example{0, 0, 0},
+		example{10, 0, 10},
+		example{0, -25, 25},
+		example{30, 30, 41.4096},
+	}
+
+	for i, eg := range data {
+		if actual := Tilt(eg.pitch, eg.roll); math.Abs(actual-eg.exp) > 0.0001 {
+			t.Errorf("Example #%d: got %0.4f, expected %0.4f", i+1, actual, eg.exp)
+		}
+	}
+}
+
+func TestMaxTilt(t *testing.T) {
+	type example struct {
+		att fixedAttitude
+		exp State
+	}
+
+	data := []example{
+		example{fixedAttitude{0, 0}, StateStepUp},
+		example{fixedAttitude{10, -10}, StateStepUp},
+		example{fixedAttitude{25, 0}, StateStand},
+		example{fixedAttitude{0, -30}, StateStand},
+	}
+
+	for i, eg := range data {
+		h := hexapod.NewHexapod(nil)
+		l := New(h, nil)
+		l.Attitude = eg.att
+		l.MaxTilt = 20
+		l.SetState(StateStand)
+
+		// Move the body, so the feet need to catch up.
+		h.Position.Z += 100
+
+		if err := l.tickState(); err != nil {
+			t.Errorf("Example #%d: unexpected error: %s", i+1, err)
+		}
+
+		if l.State != eg.exp {
+			t.Errorf("Example #%d: state is %s, expected %s", i+1, l.State, eg.exp)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package legs
+
+import (
+	"fmt"
+	"github.com/adammck/hexapod/math3d"
+	"time"
+)
+
+const (
+
+	// The time between balance adjustments. Each reads the load of every planted
+	// leg, so this can't be too frequent.
+	balanceInterval = 500 * time.Millisecond
+
+	// The maximum distance (in mm) to move the body each adjustment, to keep it
+	// gentle.
+	maxBalanceStep = 1.0
+)
+
+// balance shifts the body a little towards the center of the planted feet, if
+// it's standing and the load is unevenly spread between them (see
+// CenterOfPressure). Moving the center of mass towards the lightly loaded feet
+// puts more weight on them. Does nothing unless BalanceGain is set.
+func (l *Legs) balance(now time.Time) {
+	if l.BalanceGain <= 0 || !l.Standing() || now.Sub(l.balanceTime) < balanceInterval {
+		return
+	}
+
+	l.balanceTime = now
+
+	cop, err := l.CenterOfPressure()
+	if err != nil {
+		fmt.Printf("error balancing: %s\n", err)
+		return
+	}
+
+	feet := l.plantedFeet(noManipulator)
+	if len(feet) == 0 {
+		return
+	}
+
+	center := math3d.Vector3{}
+	for _, f := range feet {
+		center = *center.Add(f)
+	}
+
+	center = center.Scale(1 / float64(len(feet)))
+	d := center.Subtract(cop).Scale(l.BalanceGain)
+	d.Y = 0
+	if n := d.Length(); n > maxBalanceStep {
+		d = d.Scale(maxBalanceStep / n)
+	}
+
+	l.hexapod.SetPose(*l.hexapod.Position.Add(d), l.hexapod.Rotation)
+}
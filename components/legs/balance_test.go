@@ -0,0 +1,50 @@
+package legs
+
+import (
+	"github.com/adammck/hexapod"
+	"github.com/adammck/hexapod/math3d"
+	"testing"
+	"time"
+)
+
+func TestBalance(t *testing.T) {
+	h := hexapod.NewHexapod(nil)
+	l, _, m := mockLegs(h)
+	h.Add(l)
+	l.SetState(StateStand)
+	l.BalanceGain = 0.5
+
+	// Most of the weight is on the right legs.
+	loadFeet(l, m, [6]float64{2, 10, 10, 10, 2, 2})
+
+	now := time.Unix(0, 0)
+	l.balance(now)
+
+	// Should have moved left (negative X), but only a little.
+	if h.Position.X >= 0 {
+		t.Errorf("expected body to move left, got %s", h.Position)
+	}
+
+	if d := h.Position.Distance(math3d.Vector3{}); d > maxBalanceStep+0.000001 {
+		t.Errorf("moved %0.2f mm, more than %0.2f", d, maxBalanceStep)
+	}
+
+	// Not again until the interval has passed.
+	p := h.Position
+	l.balance(now.Add(balanceInterval / 2))
+	if h.Position != p {
+		t.Errorf("balanced again too soon")
+	}
+}
+
+func TestBalanceDisabled(t *testing.T) {
+	h := hexapod.NewHexapod(nil)
+	l, _, m := mockLegs(h)
+	l.SetState(StateStand)
+	loadFeet(l, m, [6]float64{2, 10, 10, 10, 2, 2})
+
+	l.balance(time.Unix(0, 0))
+	if !h.Position.Zero() {
+		t.Errorf("body moved with balance disabled")
+	}
+}
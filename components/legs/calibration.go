@@ -0,0 +1,123 @@
+package legs
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+var (
+
+	// The default reference pose for calibration: coxa pointing straight out,
+	// femur horizontal, tibia pointing straight down, and tarsus in line with
+	// the tibia. This is pretty easy to eyeball with a square.
+	defaultReferencePose = JointAngles{0, 0, 90, 0}
+)
+
+// CalibrateLeg calculates the calibration offsets of the given leg. It relaxes
+// the leg, and calls ready, which should block until the operator has moved the
+// leg into the reference pose by hand. The angle of each servo is then read,
+// and the offsets are set such that the pose reads as the reference pose.
+func (l *Legs) CalibrateLeg(i int, ready func() error) error {
+	if i < 0 || i >= len(l.Legs) {
+		return fmt.Errorf("invalid leg: %d", i)
+	}
+
+	leg := l.Legs[i]
+	for _, servo := range leg.Servos() {
+		err := servo.SetTorqueEnable(false)
+		if err != nil {
+			return err
+		}
+	}
+
+	leg.Initialized = false
+	leg.ForgetGoals()
+
+	err := ready()
+	if err != nil {
+		return err
+	}
+
+	servos := leg.Servos()
+	read := [4]float64{}
+	for j, servo := range servos {
+		read[j], err = leg.readAngle(servo)
+		if err != nil {
+			return err
+		}
+	}
+
+	// The reference pose is in the terms of the IK, so flip any reversed joints
+	// to find what the servos should have read if they were attached straight.
+	leg.CalibrationOffsets = calibrationOffsets(leg.Reversed.apply(l.ReferencePose), JointAngles{read[0], read[1], read[2], read[3]})
+	return nil
+}
+
+// calibrationOffsets returns the offsets which must be added to the reference
+// pose to get the angles which were read.
+func calibrationOffsets(ref JointAngles, read JointAngles) JointAngles {
+	return JointAngles{
+		Coxa:   read.Coxa - ref.Coxa,
+		Femur:  read.Femur - ref.Femur,
+		Tibia:  read.Tibia - ref.Tibia,
+		Tarsus: read.Tarsus - ref.Tarsus,
+	}
+}
+
+// MirrorCalibration copies the calibration offsets of one leg (by name) to the
+// leg on the opposite side, as a starting point for calibrating it. The coxa
+// of the mirror image turns the other way, so its offset is negated. The other
+// joints turn in the same plane either way, so their offsets are copied.
+func (l *Legs) MirrorCalibration(srcName string, dstName string) error {
+	src, ok := l.LegByName(srcName)
+	if !ok {
+		return fmt.Errorf("unknown leg: %s", srcName)
+	}
+
+	dst, ok := l.LegByName(dstName)
+	if !ok {
+		return fmt.Errorf("unknown leg: %s", dstName)
+	}
+
+	off := src.CalibrationOffsets
+	off.Coxa = -off.Coxa
+	dst.CalibrationOffsets = off
+	return nil
+}
+
+// SaveCalibration writes the calibration offsets of every leg (keyed by name)
+// to the given writer as JSON.
+func (l *Legs) SaveCalibration(w io.Writer) error {
+	c := map[string]JointAngles{}
+	for _, leg := range l.Legs {
+		c[leg.Name] = leg.CalibrationOffsets
+	}
+
+	b, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(b)
+	return err
+}
+
+// LoadCalibration reads calibration offsets (as written by SaveCalibration) from
+// the given reader, and applies them to the legs. Legs which are missing from
+// the input are left alone.
+func (l *Legs) LoadCalibration(r io.Reader) error {
+	c := map[string]JointAngles{}
+	err := json.NewDecoder(r).Decode(&c)
+	if err != nil {
+		return err
+	}
+
+	for _, leg := range l.Legs {
+		if off, ok := c[leg.Name]; ok {
+			leg.CalibrationOffsets = off
+		}
+	}
+
+	return nil
+}
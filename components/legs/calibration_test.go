@@ -0,0 +1,174 @@
+package legs
+
+import (
+	"bytes"
+	"github.com/adammck/hexapod"
+	"github.com/adammck/hexapod/math3d"
+	"testing"
+)
+
+func TestCalibrateLeg(t *testing.T) {
+	l := New(hexapod.NewHexapod(nil), nil)
+	l.ReferencePose = JointAngles{0, 0, 90, 0}
+	m := mockLeg(l.Legs[2])
+	for _, s := range m {
+		s.torque = true
+	}
+
+	err := l.CalibrateLeg(2, func() error {
+		for i, s := range m {
+			if s.torque {
+				t.Errorf("servo %d still has torque enabled while waiting", i)
+			}
+		}
+
+		// Pretend that the operator has moved the leg into the reference pose,
+		// but the servo horns are a bit crooked.
+		m[0].angle = 3
+		m[1].angle = -2
+		m[2].angle = 91.5
+		m[3].angle = 0
+		return nil
+	})
+
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	exp := JointAngles{3, -2, 1.5, 0}
+	if off := l.Legs[2].CalibrationOffsets; off != exp {
+		t.Errorf("got offsets %+v, expected %+v", off, exp)
+	}
+
+	// Other legs should be left alone.
+	if off := l.Legs[1].CalibrationOffsets; off != (JointAngles{}) {
+		t.Errorf("leg 1 has offsets %+v, expected none", off)
+	}
+}
+
+func TestCalibrationOffsetsApplied(t *testing.T) {
+	leg := &Leg{
+		Origin:      &math3d.Vector3{0, 0, 0},
+		Name:        "whatever",
+		Initialized: true,
+	}
+
+	m := mockLeg(leg)
+	target := math3d.Vector3{200, -80, 0}
+	coxa, femur, tibia, tarsus, _ := leg.SolveIK(target)
+
+	leg.CalibrationOffsets = JointAngles{1, 2, 3, 4}
+	if err := leg.SetGoal(target); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	exp := [4]float64{coxa + 1, femur + 2, tibia + 3, tarsus + 4}
+	for i, s := range m {
+		if s.angle != exp[i] {
+			t.Errorf("servo %d moved to %0.4f, expected %0.4f", i, s.angle, exp[i])
+		}
+	}
+}
+
+func TestSaveLoadCalibration(t *testing.T) {
+	a := New(hexapod.NewHexapod(nil), nil)
+	a.Legs[0].CalibrationOffsets = JointAngles{1, 2, 3, 4}
+	a.Legs[5].CalibrationOffsets = JointAngles{-1, 0, 0.5, 0}
+
+	buf := &bytes.Buffer{}
+	if err := a.SaveCalibration(buf); err != nil {
+		t.Fatalf("unexpected error saving: %s", err)
+	}
+
+	b := New(hexapod.NewHexapod(nil), nil)
+	if err := b.LoadCalibration(buf); err != nil {
+		t.Fatalf("unexpected error loading: %s", err)
+	}
+
+	for i := range a.Legs {
+		if a.Legs[i].CalibrationOffsets != b.Legs[i].CalibrationOffsets {
+			t.Errorf("leg %d: got %+v, expected %+v", i, b.Legs[i].CalibrationOffsets, a.Legs[i].CalibrationOffsets)
+		}
+	}
+}
+
+func TestReversedJoints(t *testing.T) {
+	leg := &Leg{
+		Origin:      &math3d.Vector3{0, 0, 0},
+		Name:        "whatever",
+		Initialized: true,
+	}
+
+	m := mockLeg(leg)
+	target := math3d.Vector3{200, -80, 30}
+	coxa, femur, tibia, tarsus, _ := leg.SolveIK(target)
+
+	leg.Reversed = JointFlags{Coxa: true, Tibia: true}
+	leg.CalibrationOffsets = JointAngles{1, 2, 3, 4}
+	if err := leg.SetGoal(target); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	exp := [4]float64{-coxa + 1, femur + 2, -tibia + 3, tarsus + 4}
+	for i, s := range m {
+		if s.angle != exp[i] {
+			t.Errorf("servo %d moved to %0.4f, expected %0.4f", i, s.angle, exp[i])
+		}
+	}
+
+	// Reading the servos back should give the target.
+	p, err := leg.FootPosition()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if p.Distance(target) > 0.0001 {
+		t.Errorf("foot is at %s, expected %s", p, target)
+	}
+}
+
+func TestCalibrateReversedLeg(t *testing.T) {
+	l := New(hexapod.NewHexapod(nil), nil)
+	l.ReferencePose = JointAngles{0, 0, 90, 0}
+	l.Legs[0].Reversed = JointFlags{Tibia: true}
+	m := mockLeg(l.Legs[0])
+
+	err := l.CalibrateLeg(0, func() error {
+		m[2].angle = -88
+		return nil
+	})
+
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	exp := JointAngles{0, 0, 2, 0}
+	if off := l.Legs[0].CalibrationOffsets; off != exp {
+		t.Errorf("got offsets %+v, expected %+v", off, exp)
+	}
+}
+
+func TestMirrorCalibration(t *testing.T) {
+	l := New(hexapod.NewHexapod(nil), nil)
+	l.Legs[0].CalibrationOffsets = JointAngles{3, -2, 1.5, 0.5}
+
+	if err := l.MirrorCalibration("FL", "FR"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	exp := JointAngles{-3, -2, 1.5, 0.5}
+	if off := l.Legs[1].CalibrationOffsets; off != exp {
+		t.Errorf("got offsets %+v, expected %+v", off, exp)
+	}
+
+	// Mirroring back should give the original.
+	l.Legs[0].CalibrationOffsets = JointAngles{}
+	l.MirrorCalibration("FR", "FL")
+	if off := l.Legs[0].CalibrationOffsets; off != (JointAngles{3, -2, 1.5, 0.5}) {
+		t.Errorf("round trip gave %+v", off)
+	}
+
+	if err := l.MirrorCalibration("FL", "XX"); err == nil {
+		t.Errorf("expected error for unknown leg")
+	}
+}
@@ -0,0 +1,154 @@
+package legs
+
+import (
+	"fmt"
+	"github.com/adammck/hexapod/math3d"
+	"github.com/adammck/hexapod/utils"
+	"io"
+	"math"
+)
+
+const (
+
+	// The no-load speed of an AX-12 at 12v, in rad/s (114rpm). This is only used
+	// to fill in the joint limits of the URDF, which require it.
+	noLoadSpeed = 114 * (2 * math.Pi) / 60
+)
+
+// The axis (in its own coordinate space) which each joint rotates around, in
+// the same order as Servos. The coxa turns around the heading (Y) axis, and the
+// rest around the bank (Z) axis, so they all move in the same vertical plane.
+var jointAxes = [4]math3d.Vector3{
+	math3d.Vector3{0, 1, 0},
+	math3d.Vector3{0, 0, 1},
+	math3d.Vector3{0, 0, 1},
+	math3d.Vector3{0, 0, 1},
+}
+
+// ChainDescription describes the kinematic chain of a leg, for tools which know
+// nothing about hexapods. Distances are in mm, and angles in radians, in the
+// coordinate space of the hexapod (i.e. Y is up).
+type ChainDescription struct {
+	Name string
+
+	// The position and rotation (around the Y axis) of the base of the leg,
+	// relative to the center of the hexapod.
+	Origin  math3d.Vector3
+	Heading float64
+
+	// The joints, from the coxa to the tarsus.
+	Joints []JointDescription
+
+	// The position of the foot in the coordinate space of the last joint.
+	Foot math3d.Vector3
+}
+
+// JointDescription describes a single joint of a leg. See ChainDescription.
+type JointDescription struct {
+	Name   string
+	Parent string
+
+	// The position and rotation of the joint relative to its parent, when every
+	// joint is at zero.
+	Offset   math3d.Vector3
+	Rotation math3d.EulerAngles
+
+	// The axis which the joint rotates around, in its own coordinate space.
+	Axis math3d.Vector3
+
+	// The range of the joint, in the terms of the IK. Unless Limited is set, the
+	// joint can turn as far as the servo can.
+	Limited bool
+	Min     float64
+	Max     float64
+}
+
+// ExportChain returns a description of the segments of the leg, with the coxa
+// at zero, and the joint limits (if any). The twist of the femur servo (see
+// Geometry) isn't included, since each joint is only rotated around its axis.
+func (leg *Leg) ExportChain() ChainDescription {
+	coxa, femur, tibia, tarsus := leg.segments(0)
+	c := ChainDescription{
+		Name:    leg.Name,
+		Origin:  *leg.Origin,
+		Heading: utils.Rad(leg.Angle),
+		Foot:    tarsus.vec,
+	}
+
+	var min, max [4]float64
+	if lim := leg.Limits; lim != nil {
+		min = [4]float64{lim.Min.Coxa, lim.Min.Femur, lim.Min.Tibia, lim.Min.Tarsus}
+		max = [4]float64{lim.Max.Coxa, lim.Max.Femur, lim.Max.Tibia, lim.Max.Tarsus}
+	}
+
+	parent := "base"
+	for i, s := range []*Segment{coxa, femur, tibia, tarsus} {
+		c.Joints = append(c.Joints, JointDescription{
+			Name:     s.Name,
+			Parent:   parent,
+			Offset:   s.parent.vec,
+			Rotation: s.Angles,
+			Axis:     jointAxes[i],
+			Limited:  leg.Limits != nil,
+			Min:      utils.Rad(min[i]),
+			Max:      utils.Rad(max[i]),
+		})
+
+		parent = s.Name
+	}
+
+	return c
+}
+
+// WriteURDF writes a minimal URDF robot, with a link for the body and each
+// segment of the leg, and a joint for each servo. Distances are converted to
+// metres, but the axes are left as they are, so Y is still up.
+func (c ChainDescription) WriteURDF(w io.Writer) error {
+	link := func(name string) string {
+		return c.Name + "_" + name
+	}
+
+	fmt.Fprintf(w, "<robot name=%q>\n", c.Name)
+	fmt.Fprintf(w, "  <link name=\"body\"/>\n")
+	fmt.Fprintf(w, "  <link name=%q/>\n", link("base"))
+	for _, j := range c.Joints {
+		fmt.Fprintf(w, "  <link name=%q/>\n", link(j.Name))
+	}
+
+	fmt.Fprintf(w, "  <link name=%q/>\n", link("foot"))
+	writeURDFJoint(w, link("base"), "fixed", "body", link("base"), c.Origin, math3d.EulerAngles{Heading: c.Heading}, nil)
+
+	for _, j := range c.Joints {
+		j := j
+		typ := "continuous"
+		if j.Limited {
+			typ = "revolute"
+		}
+
+		writeURDFJoint(w, link(j.Name), typ, link(j.Parent), link(j.Name), j.Offset, j.Rotation, &j)
+	}
+
+	last := c.Joints[len(c.Joints)-1].Name
+	writeURDFJoint(w, link("foot"), "fixed", link(last), link("foot"), c.Foot, math3d.EulerAngles{}, nil)
+
+	_, err := fmt.Fprintf(w, "</robot>\n")
+	return err
+}
+
+// writeURDFJoint writes a single URDF joint. The axis and limits are only
+// written for movable joints, i.e. when j is given.
+func writeURDFJoint(w io.Writer, name string, typ string, parent string, child string, xyz math3d.Vector3, rpy math3d.EulerAngles, j *JointDescription) {
+	fmt.Fprintf(w, "  <joint name=%q type=%q>\n", name, typ)
+	fmt.Fprintf(w, "    <parent link=%q/>\n", parent)
+	fmt.Fprintf(w, "    <child link=%q/>\n", child)
+	fmt.Fprintf(w, "    <origin xyz=\"%g %g %g\" rpy=\"%g %g %g\"/>\n", xyz.X/1000, xyz.Y/1000, xyz.Z/1000, rpy.Pitch, rpy.Heading, rpy.Bank)
+
+	if j != nil {
+		fmt.Fprintf(w, "    <axis xyz=\"%g %g %g\"/>\n", j.Axis.X, j.Axis.Y, j.Axis.Z)
+		if j.Limited {
+			fmt.Fprintf(w, "    <limit lower=\"%g\" upper=\"%g\" effort=\"%g\" velocity=\"%g\"/>\n", j.Min, j.Max, stallTorque/1000, noLoadSpeed)
+		}
+	}
+
+	fmt.Fprintf(w, "  </joint>\n")
+}
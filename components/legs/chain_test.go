@@ -0,0 +1,60 @@
+package legs
+
+import (
+	"bytes"
+	"github.com/adammck/hexapod/math3d"
+	"strings"
+	"testing"
+)
+
+func TestExportChain(t *testing.T) {
+	leg := NewLeg(nil, 10, "FL", math3d.MakeVector3(-61.167, 24, 98), -120)
+	c := leg.ExportChain()
+
+	if c.Name != "FL" || c.Origin != *leg.Origin {
+		t.Errorf("got name %s at %s, expected FL at %s", c.Name, c.Origin, leg.Origin)
+	}
+
+	coxa, femur, tibia, tarsus := leg.segments(0)
+	segs := []*Segment{coxa, femur, tibia, tarsus}
+	if len(c.Joints) != len(segs) {
+		t.Fatalf("got %d joints, expected %d", len(c.Joints), len(segs))
+	}
+
+	for i, s := range segs {
+		j := c.Joints[i]
+		if j.Name != s.Name || j.Offset != s.parent.vec || j.Rotation != s.Angles {
+			t.Errorf("joint %d: got %+v, expected to match segment %s", i, j, s)
+		}
+
+		// Each segment is only rotated around the axis of its joint.
+		r := s.Angles
+		off := math3d.Vector3{r.Pitch * (1 - j.Axis.X), r.Heading * (1 - j.Axis.Y), r.Bank * (1 - j.Axis.Z)}
+		if off.Length() > 0.000001 {
+			t.Errorf("joint %s: rotated %+v, but axis is %s", j.Name, r, j.Axis)
+		}
+	}
+
+	if c.Foot != tarsus.vec {
+		t.Errorf("got foot %s, expected %s", c.Foot, tarsus.vec)
+	}
+
+	// No limits, so every joint can turn freely.
+	buf := &bytes.Buffer{}
+	if err := c.WriteURDF(buf); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	u := buf.String()
+	if n := strings.Count(u, `type="continuous"`); n != 4 {
+		t.Errorf("got %d continuous joints, expected 4:\n%s", n, u)
+	}
+
+	// With limits, they're revolute, and the limits are in radians.
+	leg.Limits = &JointLimits{Min: JointAngles{Coxa: -90}, Max: JointAngles{Coxa: 90}}
+	buf.Reset()
+	leg.ExportChain().WriteURDF(buf)
+	if u := buf.String(); !strings.Contains(u, `<limit lower="-1.5707963267948966" upper="1.5707963267948966"`) {
+		t.Errorf("expected coxa limits:\n%s", u)
+	}
+}
@@ -0,0 +1,61 @@
+package legs
+
+import (
+	"fmt"
+	"github.com/adammck/hexapod/math3d"
+)
+
+// The default FootClearance. The feet are about 20mm across, so this leaves a
+// bit of room for the legs around them.
+const defaultFootClearance = 50.0
+
+// separateFootfalls moves the planned footfall of each leg in the given set
+// away from the feet of the legs on either side of it (where they're planned to
+// be, if they're in the set too), until they're at least FootClearance apart.
+// The legs are indexed in order around the body, so the neighbours of each are
+// the ones before and after it. If a footfall can't be moved without becoming
+// unreachable, it's left where it is.
+func (l *Legs) separateFootfalls(set []int) {
+	if l.FootClearance <= 0 {
+		return
+	}
+
+	inSet := map[int]bool{}
+	for _, i := range set {
+		inSet[i] = true
+	}
+
+	n := len(l.Legs)
+	m := l.hexapod.Local()
+
+	for _, i := range set {
+		for _, j := range [2]int{(i + 1) % n, (i + n - 1) % n} {
+			p := l.nextFeet[i]
+			q := l.feet[j]
+			if inSet[j] {
+				q = l.nextFeet[j]
+			}
+
+			d := math3d.Vector3{p.X - q.X, 0, p.Z - q.Z}
+			dist := d.Length()
+			if dist >= l.FootClearance {
+				continue
+			}
+
+			// If they're exactly on top of each other, push outwards along the
+			// heading of the leg.
+			dir := d.Unit()
+			if dist == 0 {
+				dir = math3d.Vector3{1, 0, 0}.RotateY(l.hexapod.Rotation + l.Legs[i].Angle)
+			}
+
+			pp := p.Add(dir.Scale(l.FootClearance - dist))
+			if !l.Legs[i].CanReach(pp.MultiplyByMatrix44(m)) {
+				fmt.Printf("leg %s: footfall is %0.1f mm from leg %s, but can't be moved\n", l.Legs[i].Name, dist, l.Legs[j].Name)
+				continue
+			}
+
+			l.nextFeet[i] = pp
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package legs
+
+import (
+	"github.com/adammck/hexapod"
+	"github.com/adammck/hexapod/math3d"
+	"testing"
+)
+
+// hDist returns the distance between two points on the X/Z axis.
+func hDist(a, b math3d.Vector3) float64 {
+	return math3d.Vector3{a.X - b.X, 0, a.Z - b.Z}.Length()
+}
+
+func TestSeparateFootfalls(t *testing.T) {
+	l := New(hexapod.NewHexapod(nil), nil)
+
+	// Plan for FL and FR to land almost on top of each other, in front.
+	l.nextFeet[0] = &math3d.Vector3{-10, 0, 200}
+	l.nextFeet[1] = &math3d.Vector3{10, 0, 200}
+	l.separateFootfalls([]int{0, 1})
+
+	if d := hDist(*l.nextFeet[0], *l.nextFeet[1]); d < l.FootClearance-0.000001 {
+		t.Errorf("footfalls are %0.2f mm apart, expected at least %0.2f", d, l.FootClearance)
+	}
+
+	// Only the swinging leg can move. ML is planted, so FL moves away from it.
+	ml := *l.feet[5]
+	l.nextFeet[0] = &math3d.Vector3{ml.X, 0, ml.Z + 10}
+	l.separateFootfalls([]int{0})
+
+	if d := hDist(*l.nextFeet[0], ml); d < l.FootClearance-0.000001 {
+		t.Errorf("footfall is %0.2f mm from planted foot, expected at least %0.2f", d, l.FootClearance)
+	}
+
+	if *l.feet[5] != ml {
+		t.Errorf("planted foot moved from %s to %s", ml, l.feet[5])
+	}
+
+	// Disabled.
+	l.FootClearance = 0
+	p := math3d.Vector3{-10, 0, 200}
+	l.nextFeet[0] = &p
+	l.separateFootfalls([]int{0, 1})
+	if *l.nextFeet[0] != p {
+		t.Errorf("footfall moved while disabled")
+	}
+}
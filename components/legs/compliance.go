@@ -0,0 +1,46 @@
+package legs
+
+import (
+	"fmt"
+)
+
+// comply returns the given angles, with each joint which has a compliance set
+// moved (by that many degrees per N*m) in the direction that its servo is being
+// pushed. This is a crude per-joint virtual spring, so the leg gives when it's
+// pushed, rather than fighting it. If the loads can't be read, the angles are
+// returned unchanged. The tarsus is softer while the foot is landing, if the
+// legs have AnkleCompliance set.
+func (leg *Leg) comply(a JointAngles) JointAngles {
+	c := leg.Compliance
+	c.Tarsus += leg.ankleCompliance
+	if c == (JointAngles{}) {
+		return a
+	}
+
+	k := [4]float64{c.Coxa, c.Femur, c.Tibia, c.Tarsus}
+	torque := [4]float64{}
+	for i, servo := range leg.Servos() {
+		if k[i] == 0 {
+			continue
+		}
+
+		raw, err := servo.Load()
+		if err != nil {
+			fmt.Printf("leg %s: error reading load: %s\n", leg.Name, err)
+			return a
+		}
+
+		// Convert to N*m, since N*mm would make for tiny compliances.
+		torque[i] = loadFraction(raw) * stallTorque / 1000
+	}
+
+	// The servos push back against the load, so give the other way. Undo any
+	// reversed joints, to get the torques in terms of the IK.
+	t := leg.Reversed.apply(JointAngles{torque[0], torque[1], torque[2], torque[3]})
+	return JointAngles{
+		Coxa:   a.Coxa - (t.Coxa * c.Coxa),
+		Femur:  a.Femur - (t.Femur * c.Femur),
+		Tibia:  a.Tibia - (t.Tibia * c.Tibia),
+		Tarsus: a.Tarsus - (t.Tarsus * c.Tarsus),
+	}
+}
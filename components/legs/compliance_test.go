@@ -0,0 +1,34 @@
+package legs
+
+import (
+	"github.com/adammck/hexapod/math3d"
+	"math"
+	"testing"
+)
+
+func TestComply(t *testing.T) {
+	leg := &Leg{Origin: &math3d.Vector3{0, 0, 0}, Angle: 0}
+	m := mockLeg(leg)
+	a := JointAngles{10, 20, 30, 40}
+
+	// Rigid by default, so the loads aren't even read.
+	m[1].load = encodeLoad(750)
+	if act := leg.comply(a); act != a {
+		t.Errorf("rigid leg gave: got %+v, expected %+v", act, a)
+	}
+
+	// Half of the stall torque on the femur (i.e. 0.75 N*m) should move it back
+	// by 0.75 * 4 degrees. The coxa isn't loaded, so doesn't move.
+	leg.Compliance = JointAngles{Coxa: 4, Femur: 4}
+	act := leg.comply(a)
+	exp := JointAngles{10, 17, 30, 40}
+	if math.Abs(act.Femur-exp.Femur) > 0.01 || act.Coxa != exp.Coxa {
+		t.Errorf("got %+v, expected %+v", act, exp)
+	}
+
+	// A reversed servo pushes the other way.
+	leg.Reversed.Femur = true
+	if act := leg.comply(a); math.Abs(act.Femur-23) > 0.01 {
+		t.Errorf("reversed: got femur %0.2f, expected 23", act.Femur)
+	}
+}
@@ -0,0 +1,122 @@
+package legs
+
+import (
+	"fmt"
+	"github.com/adammck/hexapod/math3d"
+)
+
+// DisableLeg takes the given leg out of the gait permanently (until EnableLeg),
+// e.g. because it's broken or has been removed. It's never stepped or moved,
+// and isn't pinged or initialized at startup. Returns an error if the legs are
+// in the middle of a step cycle, or if the body wouldn't be stable while each
+// leg set of the current gait is lifted without it. Slower gaits lift fewer
+// legs at once, so are more likely to work. The feet are shifted towards the
+// missing leg to keep the body over the rest, but the margin is still a bit
+// less than usual, so MinStabilityMargin might need lowering.
+func (l *Legs) DisableLeg(i int) error {
+	if i < 0 || i >= len(l.Legs) {
+		return fmt.Errorf("invalid leg: %d", i)
+	}
+
+	if l.stepping() || l.sLegsIndex != 0 {
+		return fmt.Errorf("can't disable a leg while stepping")
+	}
+
+	l.disabled[i] = true
+
+	if set, m := l.weakestLegSet(); m < l.MinStabilityMargin {
+		l.disabled[i] = false
+		return fmt.Errorf("can't disable leg %s: lifting %v would leave a margin of %0.1f mm", l.Legs[i].Name, set, m)
+	}
+
+	return nil
+}
+
+// EnableLeg puts the given leg back into the gait, after DisableLeg. If it was
+// never initialized, it's initialized now. Its foot is stepped home with the
+// rest of the legs.
+func (l *Legs) EnableLeg(i int) error {
+	if i < 0 || i >= len(l.Legs) {
+		return fmt.Errorf("invalid leg: %d", i)
+	}
+
+	if l.stepping() || l.sLegsIndex != 0 {
+		return fmt.Errorf("can't enable a leg while stepping")
+	}
+
+	l.disabled[i] = false
+
+	// Only initialize it if the rest of the legs have been, so it doesn't start
+	// moving before everything else.
+	leg := l.Legs[i]
+	if !leg.Initialized && l.initCounter >= len(l.Legs) {
+		l.initLeg(leg)
+	}
+
+	return nil
+}
+
+// LegEnabled returns false if the given leg has been disabled.
+func (l *Legs) LegEnabled(i int) bool {
+	return !l.disabled[i]
+}
+
+// inGait returns true if the given leg takes part in the gait, i.e. it isn't
+// being used as a manipulator, and hasn't been disabled.
+func (l *Legs) inGait(i int) bool {
+	return i != l.manipulator && !l.disabled[i]
+}
+
+// weakestLegSet returns the leg set of the current gait which leaves the lowest
+// stability margin when it's lifted, with the rest of the legs in the gait at
+// their home positions, and that margin.
+func (l *Legs) weakestLegSet() ([]int, float64) {
+	var weakest []int
+	min := 0.0
+
+	for _, set := range l.legSet() {
+		lifted := map[int]bool{}
+		for _, i := range set {
+			lifted[i] = true
+		}
+
+		feet := []math3d.Vector3{}
+		for i, leg := range l.Legs {
+			if l.inGait(i) && !lifted[i] {
+				feet = append(feet, *l.homeFootPosition(leg))
+			}
+		}
+
+		m := supportMargin(l.hexapod.Position, feet)
+		if weakest == nil || m < min {
+			weakest = set
+			min = m
+		}
+	}
+
+	return weakest, min
+}
+
+// disabledOffset returns the offset (in the hexapod space) from the origin to
+// the center of the feet of the legs which haven't been disabled, when they're
+// r mm from the origin. This is zero unless any legs are disabled.
+func (l *Legs) disabledOffset(r float64) math3d.Vector3 {
+	if l.disabled == [6]bool{} {
+		return math3d.ZeroVector3
+	}
+
+	c := math3d.Vector3{}
+	n := 0
+	for i, leg := range l.Legs {
+		if !l.disabled[i] {
+			c = *c.Add(math3d.Vector3{r, 0, 0}.RotateY(leg.Angle))
+			n += 1
+		}
+	}
+
+	if n == 0 {
+		return math3d.ZeroVector3
+	}
+
+	return c.Scale(1 / float64(n))
+}
@@ -0,0 +1,113 @@
+package legs
+
+import (
+	"github.com/adammck/hexapod"
+	"testing"
+)
+
+func TestDisableLeg(t *testing.T) {
+	h := hexapod.NewHexapod(nil)
+	l, _, m := mockLegs(h)
+
+	// Lifting two legs of a tripod with one missing would leave the body on the
+	// remaining two.
+	l.SetGait(TripodGait)
+	if err := l.DisableLeg(0); err == nil {
+		t.Errorf("expected error disabling a leg with the tripod gait")
+	}
+
+	if !l.LegEnabled(0) {
+		t.Errorf("leg was disabled despite the error")
+	}
+
+	// The wave gait is fine, though. The feet are moved in to make up for the
+	// missing leg, but the margin is still a bit less than the default.
+	l.SetGait(WaveGait)
+	l.MinStabilityMargin = 30
+	if err := l.DisableLeg(0); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if _, margin := l.weakestLegSet(); margin < l.MinStabilityMargin {
+		t.Errorf("margin of %0.1f mm with leg disabled", margin)
+	}
+
+	// The missing servos shouldn't stop the others booting.
+	for _, s := range m[0] {
+		s.absent = true
+	}
+
+	if _, err := l.Ping(); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	runInit(l, func(int) float64 { return 12 }, m)
+	if l.Legs[0].Initialized {
+		t.Errorf("disabled leg was initialized")
+	}
+
+	// Walk forwards for a while. The disabled leg should never be stepped, or
+	// moved at all.
+	foot := *l.feet[0]
+	other := *l.feet[1]
+	l.SetState(StateStand)
+	for i := 0; i < 500; i++ {
+		h.Position.Z += 0.5
+		l.Tick(h.Now())
+
+		for _, set := range l.legSet() {
+			for _, ii := range set {
+				if ii == 0 {
+					t.Fatalf("disabled leg is in leg set %v", set)
+				}
+			}
+		}
+	}
+
+	if *l.feet[0] != foot || len(m[0][0].moves) != 0 {
+		t.Errorf("disabled leg moved")
+	}
+
+	if *l.feet[1] == other {
+		t.Errorf("expected the other legs to walk")
+	}
+}
+
+func TestSetGaitWithLegDisabled(t *testing.T) {
+	l, _, _ := mockLegs(hexapod.NewHexapod(nil))
+	l.SetGait(WaveGait)
+	l.MinStabilityMargin = 30
+	if err := l.DisableLeg(0); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// The tripod is refused, like it would have been when disabling the leg.
+	if err := l.SetGait(TripodGait); err == nil {
+		t.Errorf("expected error")
+	}
+
+	if l.Gait() != WaveGait || l.nextGait != nil {
+		t.Errorf("gait is %s, expected wave", l.Gait())
+	}
+
+	// Other gaits which are stable enough are fine.
+	if err := l.SetGait(WaveGait); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+}
+
+func TestEnableLeg(t *testing.T) {
+	l, _, m := mockLegs(hexapod.NewHexapod(nil))
+	l.SetGait(WaveGait)
+	l.MinStabilityMargin = 30
+	l.DisableLeg(2)
+	runInit(l, func(int) float64 { return 12 }, m)
+
+	if err := l.EnableLeg(2); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if !l.LegEnabled(2) || !l.Legs[2].Initialized {
+		t.Errorf("expected leg to be enabled and initialized")
+	}
+}
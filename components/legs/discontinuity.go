@@ -0,0 +1,50 @@
+package legs
+
+import (
+	"fmt"
+	"github.com/adammck/hexapod/math3d"
+	"math"
+)
+
+const (
+
+	// The default DiscontinuityThreshold. Smooth walking moves each joint a few
+	// degrees per tick at most.
+	defaultDiscontinuityThreshold = 30.0
+
+	// The furthest (in mm) which the target of a foot can move between goals
+	// and still be considered to be moving smoothly. Bigger jumps are expected
+	// to move the joints a lot, so aren't checked for discontinuities.
+	smoothTargetDistance = 10.0
+)
+
+// checkContinuity compares the given IK solution for the target p with the
+// previous one, and calls OnDiscontinuity for each joint which changed by more
+// than DiscontinuityThreshold, if the target only moved a little. That usually
+// means the IK has flipped to another solution, e.g. the coxa has wrapped all
+// the way around, or the knee has bent the other way.
+func (leg *Leg) checkContinuity(p math3d.Vector3, a JointAngles) {
+	prev, prevTarget, known := leg.solution, leg.solutionTarget, leg.solutionKnown
+	leg.solution, leg.solutionTarget, leg.solutionKnown = a, p, true
+
+	if !known || leg.DiscontinuityThreshold <= 0 || leg.OnDiscontinuity == nil {
+		return
+	}
+
+	if p.Distance(prevTarget) > smoothTargetDistance {
+		return
+	}
+
+	from := [4]float64{prev.Coxa, prev.Femur, prev.Tibia, prev.Tarsus}
+	to := [4]float64{a.Coxa, a.Femur, a.Tibia, a.Tarsus}
+	for i := range from {
+		if math.Abs(to[i]-from[i]) > leg.DiscontinuityThreshold {
+			leg.OnDiscontinuity(leg, jointNames[i], from[i], to[i])
+		}
+	}
+}
+
+// logDiscontinuity is the default OnDiscontinuity of each leg.
+func logDiscontinuity(leg *Leg, joint string, from float64, to float64) {
+	fmt.Printf("leg %s: %s jumped from %0.1f to %0.1f\n", leg.Name, joint, from, to)
+}
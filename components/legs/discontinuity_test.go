@@ -0,0 +1,53 @@
+package legs
+
+import (
+	"github.com/adammck/hexapod/math3d"
+	"testing"
+)
+
+func TestDiscontinuity(t *testing.T) {
+	leg := &Leg{
+		Origin:                 &math3d.Vector3{0, 0, 0},
+		Name:                   "whatever",
+		Initialized:            true,
+		DiscontinuityThreshold: 30,
+	}
+
+	mockLeg(leg)
+	jumps := map[string]float64{}
+	leg.OnDiscontinuity = func(l *Leg, joint string, from float64, to float64) {
+		jumps[joint] = to - from
+	}
+
+	// Sweeping the foot smoothly around in front of the leg is fine.
+	for z := -30.0; z <= 30; z += 5 {
+		if err := leg.SetGoal(math3d.Vector3{200, -80, z}); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+
+	if len(jumps) != 0 {
+		t.Errorf("unexpected discontinuities: %v", jumps)
+	}
+
+	// Jumping to the other side is expected to move the joints a lot.
+	if err := leg.SetGoal(math3d.Vector3{-200, -80, 2}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if len(jumps) != 0 {
+		t.Errorf("unexpected discontinuities after jump: %v", jumps)
+	}
+
+	// But crossing directly behind the leg wraps the coxa all the way around,
+	// though the foot barely moves.
+	for _, z := range []float64{1, -1, -2} {
+		if err := leg.SetGoal(math3d.Vector3{-200, -80, z}); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+
+	if len(jumps) != 1 || jumps["coxa"] < 300 {
+		t.Errorf("got %v, expected only the coxa to jump around", jumps)
+	}
+}
@@ -0,0 +1,58 @@
+package legs
+
+import (
+	"fmt"
+	"math"
+)
+
+const (
+
+	// The number of ticks which each leg set spends in the air, per step.
+	swingCount = stepUpCount + stepOverCount + stepDownCount
+
+	// The highest duty factor which can be set. Any higher, and the legs would
+	// hardly ever step.
+	maxDutyFactor = 0.95
+)
+
+// MinDutyFactor returns the lowest duty factor which the current gait can step
+// with, i.e. with no pause between leg sets. With fewer leg sets, each leg must
+// spend a larger fraction of the cycle in the air.
+func (l *Legs) MinDutyFactor() float64 {
+	return 1 - (1 / float64(len(l.legSet())))
+}
+
+// SetDutyFactor sets the fraction of each step cycle which each leg should spend
+// planted on the ground. Higher is slower but more stable. The feet are stepped
+// at the same speed regardless; a pause (with every foot planted) is added
+// after each leg set to stretch the cycle.
+func (l *Legs) SetDutyFactor(d float64) error {
+	if min := l.MinDutyFactor(); d < min || d > maxDutyFactor {
+		return fmt.Errorf("duty factor %0.2f out of range (%0.2f to %0.2f)", d, min, maxDutyFactor)
+	}
+
+	l.DutyFactor = d
+	return nil
+}
+
+// stancePause returns the number of ticks to wait (with every foot planted)
+// after stepping each leg set, to achieve the duty factor. If the gait has been
+// changed since the duty factor was set, it might not be possible any more, in
+// which case there's no pause.
+func (l *Legs) stancePause() int {
+	n := float64(len(l.legSet()))
+	d := l.DutyFactor
+	if d <= l.MinDutyFactor() {
+		return 0
+	}
+
+	// Each leg swings for swingCount ticks out of a cycle of n * (swingCount +
+	// pause) ticks, so solve for the pause.
+	return int(math.Ceil((swingCount / (n * (1 - d))) - swingCount - 0.000001))
+}
+
+// settled returns true if the last leg set to step landed at least SettleDelay
+// ago.
+func (l *Legs) settled() bool {
+	return l.hexapod.Now().Sub(l.landedTime) >= l.SettleDelay
+}
@@ -0,0 +1,130 @@
+package legs
+
+import (
+	"github.com/adammck/hexapod"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestSetDutyFactor(t *testing.T) {
+	l := New(hexapod.NewHexapod(nil), nil)
+
+	for _, d := range []float64{0, 0.5, 0.6, 0.96, 1} {
+		if err := l.SetDutyFactor(d); err == nil {
+			t.Errorf("expected error for duty factor %0.2f with ripple gait", d)
+		}
+	}
+
+	if err := l.SetDutyFactor(0.8); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	l.SetGait(TripodGait)
+	if err := l.SetDutyFactor(0.5); err != nil {
+		t.Errorf("unexpected error for tripod: %s", err)
+	}
+}
+
+func TestStancePause(t *testing.T) {
+	data := []struct {
+		gait  Gait
+		duty  float64
+		pause int
+	}{
+		{RippleGait, 0, 0},
+		{RippleGait, 2.0 / 3.0, 0},
+		{RippleGait, 0.8, 8},
+		{TripodGait, 0.5, 0},
+		{TripodGait, 0.75, 12},
+		{WaveGait, 0.9, 8},
+	}
+
+	for i, d := range data {
+		l := New(hexapod.NewHexapod(nil), nil)
+		l.SetGait(d.gait)
+		l.DutyFactor = d.duty
+
+		p := l.stancePause()
+		if p != d.pause {
+			t.Errorf("example %d: got pause %d, expected %d", i+1, p, d.pause)
+		}
+
+		// Check that it really does give the duty factor, by counting ticks.
+		if d.duty > 0 {
+			n := float64(len(d.gait.LegSets()))
+			duty := 1 - (swingCount / (n * float64(swingCount+p)))
+			if math.Abs(duty-d.duty) > 0.01 {
+				t.Errorf("example %d: pause gives duty factor %0.3f, expected %0.3f", i+1, duty, d.duty)
+			}
+		}
+	}
+}
+
+func TestStancePauseTiming(t *testing.T) {
+	h := hexapod.NewHexapod(nil)
+	l := New(h, nil)
+	l.SetGait(TripodGait)
+	l.SetDutyFactor(0.75)
+	l.SetState(StateStand)
+
+	// Walk forwards for a while, and count how many ticks each leg is lifted.
+	lifted := [6]int{}
+	ticks := 0
+	for i := 0; i < 2000; i++ {
+		h.Position.Z += 0.5
+		l.stateCounter += 1
+		l.tickState()
+
+		if l.State == StateStand {
+			continue
+		}
+
+		ticks += 1
+		for ii := range l.Legs {
+			if l.swinging(ii) {
+				lifted[ii] += 1
+			}
+		}
+	}
+
+	for ii, n := range lifted {
+		duty := 1 - (float64(n) / float64(ticks))
+		if math.Abs(duty-0.75) > 0.05 {
+			t.Errorf("leg %d: duty factor %0.3f, expected 0.75", ii, duty)
+		}
+	}
+}
+
+func TestSettleDelay(t *testing.T) {
+	c := &hexapod.FakeClock{T: time.Unix(100, 0)}
+	h := hexapod.NewHexapod(nil)
+	h.Clock = c
+	l := New(h, nil)
+	l.SettleDelay = 30 * time.Millisecond
+	l.SetState(StateStepDown)
+
+	// Land the first leg set.
+	for i := 0; i < stepDownCount; i++ {
+		l.stateCounter += 1
+		l.tickState()
+	}
+
+	if l.State != StateStepDown || l.sLegsIndex != 0 {
+		t.Fatalf("expected to wait for the body to settle, but moved on to %s", l.State)
+	}
+
+	c.Advance(20 * time.Millisecond)
+	l.stateCounter += 1
+	l.tickState()
+	if l.State != StateStepDown {
+		t.Fatalf("expected to still be waiting after 20ms")
+	}
+
+	c.Advance(10 * time.Millisecond)
+	l.stateCounter += 1
+	l.tickState()
+	if l.sLegsIndex != 1 {
+		t.Errorf("expected to move on to the next leg set after 30ms")
+	}
+}
@@ -0,0 +1,47 @@
+package legs
+
+// Easing maps the fraction (0 to 1) of the time spent on a movement to the
+// fraction of the distance which should have been covered by then.
+type Easing func(t float64) float64
+
+var (
+
+	// Linear moves at a constant speed.
+	Linear Easing = func(t float64) float64 {
+		return t
+	}
+
+	// EaseIn starts slowly and finishes quickly.
+	EaseIn Easing = func(t float64) float64 {
+		return t * t
+	}
+
+	// EaseOut starts quickly and finishes slowly. As a lift, this gets the foot
+	// up high quickly; as a lower, it sets it down gently.
+	EaseOut Easing = func(t float64) float64 {
+		return 1 - ((1 - t) * (1 - t))
+	}
+
+	// EaseInOut starts and finishes slowly.
+	EaseInOut Easing = func(t float64) float64 {
+		return t * t * (3 - (2 * t))
+	}
+)
+
+// swingHeight returns the height (on the Y axis) which the feet being stepped
+// should be at, in a state which lasts for n ticks and moves them from one
+// height to another. With no easing, they move all the way on the first tick,
+// and the servos get there as fast as they can.
+func (l *Legs) swingHeight(e Easing, n int, from float64, to float64) float64 {
+	return ease(e, l.stateCounter, n, from, to)
+}
+
+// ease returns the height after c of n ticks of a movement from one height to
+// another. See swingHeight.
+func ease(e Easing, c int, n int, from float64, to float64) float64 {
+	if e == nil || c >= n {
+		return to
+	}
+
+	return from + ((to - from) * e(float64(c)/float64(n)))
+}
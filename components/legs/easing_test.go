@@ -0,0 +1,61 @@
+package legs
+
+import (
+	"github.com/adammck/hexapod"
+	"math"
+	"testing"
+)
+
+func TestEasings(t *testing.T) {
+	for name, e := range map[string]Easing{"linear": Linear, "in": EaseIn, "out": EaseOut, "inout": EaseInOut} {
+		if e(0) != 0 || e(1) != 1 {
+			t.Errorf("%s: expected 0 -> 0 and 1 -> 1, got %0.4f and %0.4f", name, e(0), e(1))
+		}
+	}
+
+	if EaseIn(0.5) >= 0.5 || EaseOut(0.5) <= 0.5 || math.Abs(EaseInOut(0.5)-0.5) > 0.000001 {
+		t.Errorf("easing curves have the wrong shape")
+	}
+}
+
+func TestSwingHeight(t *testing.T) {
+	l := New(hexapod.NewHexapod(nil), nil)
+	l.SetState(StateStepUp)
+	l.LiftEasing = EaseOut
+
+	heights := []float64{}
+	for i := 0; i < stepUpCount; i++ {
+		l.stateCounter += 1
+		l.tickState()
+		heights = append(heights, l.feet[l.legSet()[0][0]].Y)
+	}
+
+	// Should rise every tick, quickest at the start, and end at the top.
+	for i := 1; i < len(heights); i++ {
+		if heights[i] <= heights[i-1] {
+			t.Errorf("foot didn't rise on tick %d: %v", i+1, heights)
+		}
+	}
+
+	if heights[0] <= l.stepUpPosition()/float64(stepUpCount) {
+		t.Errorf("first tick was too slow for an ease out: %v", heights)
+	}
+
+	if heights[len(heights)-1] != l.stepUpPosition() {
+		t.Errorf("foot finished at %0.2f, expected %0.2f", heights[len(heights)-1], l.stepUpPosition())
+	}
+}
+
+func TestSwingHeightNoEasing(t *testing.T) {
+	l := New(hexapod.NewHexapod(nil), nil)
+	l.SetState(StateStepDown)
+	for _, ii := range l.legSet()[0] {
+		l.feet[ii].Y = l.stepUpPosition()
+	}
+
+	l.stateCounter += 1
+	l.tickState()
+	if y := l.feet[l.legSet()[0][0]].Y; y != l.stepDownPosition() {
+		t.Errorf("foot at %0.2f after one tick, expected %0.2f", y, l.stepDownPosition())
+	}
+}
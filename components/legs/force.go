@@ -0,0 +1,116 @@
+package legs
+
+import (
+	"fmt"
+	"github.com/adammck/hexapod/math3d"
+	"math"
+)
+
+const (
+
+	// The stall torque of an AX-12 at 12v, in N*mm. The present load register is
+	// a fraction of this.
+	stallTorque = 1500.0
+)
+
+// loadFraction decodes the present load register of a Dynamixel into the
+// fraction of the maximum torque which the servo is exerting. The low ten bits
+// are the magnitude, and bit ten is set when it's pushing clockwise (i.e. the
+// direction of decreasing angle).
+func loadFraction(raw int) float64 {
+	f := float64(raw&0x3FF) / 1023.0
+	if raw&0x400 != 0 {
+		return -f
+	}
+
+	return f
+}
+
+// FootForce estimates the force (in newtons, in the hexapod coordinate space)
+// which the leg is exerting through its foot, by reading the load and angle of
+// each servo. A leg holding the body up pushes down, i.e. negative Y.
+//
+// This is very rough. The load registers are noisy, and not calibrated. The
+// tarsus is assumed to be rigid, since the other three joints are enough to
+// find the force.
+func (leg *Leg) FootForce() (math3d.Vector3, error) {
+	a, err := leg.Angles()
+	if err != nil {
+		return math3d.ZeroVector3, err
+	}
+
+	return leg.footForce(a)
+}
+
+// footForce is like FootForce, but with the present angles of the servos already
+// known.
+func (leg *Leg) footForce(a JointAngles) (math3d.Vector3, error) {
+	servos := leg.Servos()
+	torque := [3]float64{}
+	for i := range torque {
+		raw, err := servos[i].Load()
+		if err != nil {
+			return math3d.ZeroVector3, err
+		}
+
+		torque[i] = loadFraction(raw) * stallTorque
+	}
+
+	// Undo any reversed joints, to get the torques in terms of the IK.
+	rev := leg.Reversed.apply(JointAngles{torque[0], torque[1], torque[2], 0})
+	torque = [3]float64{rev.Coxa, rev.Femur, rev.Tibia}
+
+	return leg.forceFromTorques(a, torque)
+}
+
+// forceFromTorques returns the force at the foot which would be balanced by the
+// given torques (in N*mm) at the coxa, femur, and tibia, when the leg is at the
+// given angles. This solves t = J^T * f, where J is the Jacobian of the foot
+// position with respect to those three joints.
+func (leg *Leg) forceFromTorques(a JointAngles, torque [3]float64) (math3d.Vector3, error) {
+	j := leg.jacobian(a)
+
+	// Rows of J^T are the columns of J, i.e. the derivative of the foot position
+	// with respect to each joint.
+	det := det3(j[0].X, j[0].Y, j[0].Z, j[1].X, j[1].Y, j[1].Z, j[2].X, j[2].Y, j[2].Z)
+	if math.Abs(det) < 0.000001 {
+		return math3d.ZeroVector3, fmt.Errorf("leg %s is at a singularity", leg.Name)
+	}
+
+	t := torque
+	return math3d.Vector3{
+		det3(t[0], j[0].Y, j[0].Z, t[1], j[1].Y, j[1].Z, t[2], j[2].Y, j[2].Z) / det,
+		det3(j[0].X, t[0], j[0].Z, j[1].X, t[1], j[1].Z, j[2].X, t[2], j[2].Z) / det,
+		det3(j[0].X, j[0].Y, t[0], j[1].X, j[1].Y, t[1], j[2].X, j[2].Y, t[2]) / det,
+	}, nil
+}
+
+// Load returns the average load of the servos of every initialized leg, as a
+// fraction of their maximum torque, regardless of direction. This reads every
+// servo, so don't call it too often.
+func (l *Legs) Load() (float64, error) {
+	total := 0.0
+	n := 0
+
+	for _, leg := range l.Legs {
+		if !leg.Initialized {
+			continue
+		}
+
+		for _, servo := range leg.Servos() {
+			raw, err := servo.Load()
+			if err != nil {
+				return 0, err
+			}
+
+			total += math.Abs(loadFraction(raw))
+			n += 1
+		}
+	}
+
+	if n == 0 {
+		return 0, nil
+	}
+
+	return total / float64(n), nil
+}
@@ -0,0 +1,76 @@
+package legs
+
+import (
+	"github.com/adammck/hexapod"
+	"github.com/adammck/hexapod/math3d"
+	"math"
+	"testing"
+)
+
+func TestLoadFraction(t *testing.T) {
+	data := map[int]float64{
+		0:            0,
+		1023:         1,
+		0x400 | 1023: -1,
+		0x400 | 0:    0,
+		511:          511.0 / 1023.0,
+	}
+
+	for raw, exp := range data {
+		if f := loadFraction(raw); math.Abs(f-exp) > 0.000001 {
+			t.Errorf("load %d: got %0.4f, expected %0.4f", raw, f, exp)
+		}
+	}
+}
+
+func TestFootForce(t *testing.T) {
+	leg := &Leg{Origin: &math3d.Vector3{0, 0, 0}, Angle: 30}
+	m := mockLeg(leg)
+
+	a, err := poseLeg(leg, m, math3d.Vector3{150, -90, -60})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// Load the servos as if the foot was pushing down with 5N (and a bit
+	// sideways).
+	exp := math3d.Vector3{1, -5, 0.5}
+	loadLeg(leg, m, a, exp)
+
+	f, err := leg.FootForce()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// The load registers only have ten bits, so this is pretty rough.
+	if f.Distance(exp) > 0.1 {
+		t.Errorf("got force %s, expected %s", f, exp)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	l, _, m := mockLegs(hexapod.NewHexapod(nil))
+	if load, err := l.Load(); err != nil || load != 0 {
+		t.Errorf("expected zero load before init, got %0.2f (err=%v)", load, err)
+	}
+
+	runInit(l, func(int) float64 { return 12 }, m)
+	for i, leg := range m {
+		for j, s := range leg {
+			if (i+j)%2 == 0 {
+				s.load = encodeLoad(stallTorque / 2)
+			} else {
+				s.load = encodeLoad(-stallTorque / 2)
+			}
+		}
+	}
+
+	load, err := l.Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if math.Abs(load-0.5) > 0.001 {
+		t.Errorf("got load %0.4f, expected 0.5", load)
+	}
+}
@@ -0,0 +1,68 @@
+package legs
+
+import (
+	"errors"
+	"github.com/adammck/hexapod/math3d"
+)
+
+var (
+	errFrozen = errors.New("legs are frozen")
+)
+
+// frozenPose is a snapshot of the pose of the body and the goal of every foot,
+// taken by Freeze.
+type frozenPose struct {
+	position math3d.Vector3
+	rotation float64
+	pitch    float64
+	roll     float64
+
+	// The goal of each foot (in the hexapod space), and the tarsus direction.
+	goals [6]math3d.Vector3
+	up    math3d.Vector3
+}
+
+// Freeze holds every leg exactly where it is, until Unfreeze is called. Nothing
+// else happens in the meantime: the legs don't step, and any attempt to move or
+// lean the body is ignored. This is useful for taking photos.
+func (l *Legs) Freeze() {
+	if l.frozen != nil {
+		return
+	}
+
+	h := l.hexapod
+	f := &frozenPose{
+		position: h.Position,
+		rotation: h.Rotation,
+		pitch:    h.Pitch,
+		roll:     h.Roll,
+		up:       l.tarsusDirection(),
+	}
+
+	for i := range l.Legs {
+		f.goals[i] = l.footGoal(i)
+	}
+
+	l.frozen = f
+}
+
+// Unfreeze returns the legs to normal after Freeze. The body is put back where
+// it was when frozen, since the feet haven't moved, in case anything leaned it
+// in the meantime.
+func (l *Legs) Unfreeze() {
+	if l.frozen == nil {
+		return
+	}
+
+	h := l.hexapod
+	h.Position = l.frozen.position
+	h.Rotation = l.frozen.rotation
+	h.Pitch = l.frozen.pitch
+	h.Roll = l.frozen.roll
+	l.frozen = nil
+}
+
+// Frozen returns true if the legs are frozen. See Freeze.
+func (l *Legs) Frozen() bool {
+	return l.frozen != nil
+}
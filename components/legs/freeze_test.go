@@ -0,0 +1,69 @@
+package legs
+
+import (
+	"github.com/adammck/hexapod"
+	"github.com/adammck/hexapod/math3d"
+	"testing"
+	"time"
+)
+
+func TestFreeze(t *testing.T) {
+	h := hexapod.NewHexapod(nil)
+	l, _, m := mockLegs(h)
+	h.Add(l)
+	for _, leg := range l.Legs {
+		leg.Initialized = true
+	}
+
+	l.SetState(StateStand)
+	l.updateFeet()
+
+	angles := func() [6][4]float64 {
+		r := [6][4]float64{}
+		for i := range m {
+			for j, s := range m[i] {
+				r[i][j] = s.angle
+			}
+		}
+		return r
+	}
+
+	before := angles()
+	l.Freeze()
+
+	// Try to move the body every which way.
+	if err := h.SetPose(math3d.Vector3{10, 0, 10}, 20); err == nil {
+		t.Errorf("expected body movement to be rejected while frozen")
+	}
+
+	h.Pitch = 5
+	h.Roll = -5
+	for i := 0; i < 10; i++ {
+		l.Tick(time.Now())
+	}
+
+	if after := angles(); after != before {
+		t.Errorf("servos moved while frozen")
+	}
+
+	if l.stateCounter != 0 {
+		t.Errorf("state machine ran while frozen")
+	}
+
+	l.Unfreeze()
+	if h.Pitch != 0 || h.Roll != 0 || !h.Position.Zero() {
+		t.Errorf("body wasn't restored on unfreeze")
+	}
+
+	if err := h.SetPose(math3d.Vector3{1, 0, 1}, 0); err != nil {
+		t.Errorf("unexpected error after unfreeze: %s", err)
+	}
+
+	// Shutting down while frozen unfreezes, and sits down.
+	l.Freeze()
+	h.Shutdown = true
+	l.Tick(time.Now())
+	if l.Frozen() || l.State != StateSitDown {
+		t.Errorf("expected to unfreeze and sit down, got %s (frozen=%v)", l.State, l.Frozen())
+	}
+}
@@ -0,0 +1,100 @@
+package legs
+
+import (
+	"fmt"
+)
+
+// Gait determines which legs step together.
+type Gait interface {
+
+	// LegSets returns the sets of legs (by index) which are lifted together, in
+	// the order that they should be stepped. Every leg should appear once.
+	LegSets() [][]int
+}
+
+// legSetGait is a gait which steps a fixed sequence of leg sets.
+type legSetGait struct {
+	name string
+	sets [][]int
+}
+
+func (g *legSetGait) LegSets() [][]int {
+	return g.sets
+}
+
+func (g *legSetGait) String() string {
+	return g.name
+}
+
+var (
+
+	// WaveGait steps one leg at a time. It's slow, but very stable.
+	WaveGait Gait = &legSetGait{"wave", [][]int{
+		[]int{0},
+		[]int{1},
+		[]int{2},
+		[]int{3},
+		[]int{4},
+		[]int{5},
+	}}
+
+	// RippleGait steps two (opposite) legs at a time.
+	RippleGait Gait = &legSetGait{"ripple", [][]int{
+		[]int{0, 3},
+		[]int{1, 4},
+		[]int{2, 5},
+	}}
+
+	// TripodGait steps three legs at a time, leaving a tripod on the ground. It's
+	// the fastest, but the least stable.
+	TripodGait Gait = &legSetGait{"tripod", [][]int{
+		[]int{0, 2, 4},
+		[]int{1, 3, 5},
+	}}
+)
+
+// Gait returns the gait which the legs are currently stepping with. This might
+// not be the last one passed to SetGait, if the legs are mid-cycle.
+func (l *Legs) Gait() Gait {
+	return l.gait
+}
+
+// SetGait switches to the given gait. Switching in the middle of a step cycle
+// would change the leg sets out from under the feet which are in the air, so
+// the switch is deferred until the current cycle is finished. If any legs are
+// disabled, returns an error (and keeps the current gait) if the body wouldn't
+// be stable while each leg set of the new gait is lifted, like DisableLeg.
+func (l *Legs) SetGait(g Gait) error {
+	if l.disabled != [6]bool{} {
+		prev := l.gait
+		l.gait = g
+		set, m := l.weakestLegSet()
+		l.gait = prev
+
+		if m < l.MinStabilityMargin {
+			return fmt.Errorf("can't switch to %s gait: lifting %v would leave a margin of %0.1f mm", g, set, m)
+		}
+	}
+
+	l.nextGait = g
+
+	if l.sLegsIndex == 0 && !l.stepping() {
+		l.applyGait()
+	}
+
+	return nil
+}
+
+// applyGait switches to the pending gait, if there is one. This must only be
+// called between step cycles.
+func (l *Legs) applyGait() {
+	if l.nextGait != nil {
+		l.gait = l.nextGait
+		l.nextGait = nil
+	}
+}
+
+// stepping returns true if any legs are in the middle of a step.
+func (l *Legs) stepping() bool {
+	return l.State == StateStepUp || l.State == StateStepOver || l.State == StateStepDown
+}
@@ -0,0 +1,83 @@
+package legs
+
+import (
+	"github.com/adammck/hexapod"
+	"testing"
+)
+
+func TestGaitsCoverEveryLeg(t *testing.T) {
+	for _, g := range []Gait{WaveGait, RippleGait, TripodGait, TrotGait} {
+		n := [6]int{}
+		for _, set := range g.LegSets() {
+			for _, i := range set {
+				n[i] += 1
+			}
+		}
+
+		if n != [6]int{1, 1, 1, 1, 1, 1} {
+			t.Errorf("gait %v steps legs %v times", g, n)
+		}
+	}
+}
+
+func TestSetGaitWhileStanding(t *testing.T) {
+	l := New(hexapod.NewHexapod(nil), nil)
+	l.SetState(StateStand)
+
+	l.SetGait(TripodGait)
+	if l.Gait() != TripodGait {
+		t.Errorf("gait wasn't switched immediately while standing")
+	}
+}
+
+func TestSetGaitWhileStepping(t *testing.T) {
+	h := hexapod.NewHexapod(nil)
+	l := New(h, nil)
+	l.SetState(StateStand)
+
+	// Walk forwards, switching to a tripod gait partway through a cycle.
+	switched := false
+	for i := 0; i < 200; i++ {
+		h.Position.Z += 1
+
+		if !switched && l.State == StateStepOver && l.sLegsIndex == 1 {
+			l.SetGait(TripodGait)
+			if l.Gait() != RippleGait {
+				t.Errorf("gait was switched mid-cycle")
+			}
+		}
+
+		l.stateCounter += 1
+		if err := l.tickState(); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		if l.Gait() == TripodGait {
+			switched = true
+		}
+
+		// Every lifted foot must belong to the leg set being stepped.
+		for ii, f := range l.feet {
+			if f.Y <= l.stepDownPosition() {
+				continue
+			}
+
+			if !l.stepping() {
+				t.Fatalf("tick %d: foot %d is lifted in state %s", i, ii, l.State)
+			}
+
+			found := false
+			for _, jj := range l.legSet()[l.sLegsIndex] {
+				found = found || (ii == jj)
+			}
+
+			if !found {
+				t.Fatalf("tick %d: foot %d was left lifted by gait switch", i, ii)
+			}
+		}
+	}
+
+	if !switched {
+		t.Errorf("gait was never switched")
+	}
+}
@@ -0,0 +1,62 @@
+package legs
+
+// Geometry is the shape of a leg. Distances are in mm, and angles in degrees.
+type Geometry struct {
+
+	// The length of each segment. The coxa also drops a bit on the Y axis,
+	// between the coxa and femur servos.
+	CoxaLength   float64
+	CoxaDrop     float64
+	FemurLength  float64
+	TibiaLength  float64
+	TarsusLength float64
+
+	// How far the femur servo is mounted to the side of the coxa (along the Z
+	// axis of the leg, with the coxa at zero), so the femur doesn't swing in a
+	// plane through the coxa axis.
+	CoxaOffset float64
+
+	// How far the femur servo is twisted around the coxa (i.e. around the X axis
+	// of the leg), so the femur, tibia, and tarsus don't swing in a vertical
+	// plane.
+	Twist float64
+}
+
+// OriginalGeometry is the shape of the original legs, which is the only shape
+// which SolverClosedForm can solve.
+var OriginalGeometry = Geometry{
+	CoxaLength:   coxaLength,
+	CoxaDrop:     coxaDrop,
+	FemurLength:  femurLength,
+	TibiaLength:  tibiaLength,
+	TarsusLength: tarsusLength,
+}
+
+// geometry returns the shape of the leg, which is OriginalGeometry unless it's
+// been set.
+func (leg *Leg) geometry() Geometry {
+	if leg.Geometry == nil {
+		return OriginalGeometry
+	}
+
+	return *leg.Geometry
+}
+
+// planar returns true if the femur, tibia, and tarsus swing in a vertical plane
+// through the coxa axis, which the closed form solver assumes.
+func (g Geometry) planar() bool {
+	return g.CoxaOffset == 0 && g.Twist == 0
+}
+
+// minReach returns the minimum horizontal distance (in mm) between the origin
+// of a leg and its foot. Any closer, and the foot would be inside the coxa. At
+// the origin itself, the heading of the coxa is undefined.
+func (g Geometry) minReach() float64 {
+	return g.CoxaLength
+}
+
+// maxReach returns the furthest (in mm) which the foot could possibly be from
+// the origin of the leg, i.e. with every segment in a straight line.
+func (g Geometry) maxReach() float64 {
+	return g.CoxaLength + g.CoxaDrop + g.CoxaOffset + g.FemurLength + g.TibiaLength + g.TarsusLength
+}
@@ -0,0 +1,127 @@
+package legs
+
+import (
+	"fmt"
+	"github.com/adammck/hexapod/math3d"
+	"math"
+)
+
+const (
+
+	// The number of halvings to search for the nearest reachable height, when the
+	// requested height can't be reached. After this many, it's well under 1mm.
+	heightSearchSteps = 16
+)
+
+// GroundPlane describes the ground which the feet are placed on, in the WORLD
+// coordinate space, for when it isn't flat and level with where the hexapod
+// started, e.g. on a step. The zero value is flat ground at the usual height.
+type GroundPlane struct {
+
+	// The height (in mm, on the Y axis) of the ground at the origin, relative to
+	// where the feet are usually put down. See FootDown.
+	Height float64
+
+	// How much the ground rises (in mm per mm) along the X and Z axes.
+	SlopeX float64
+	SlopeZ float64
+}
+
+// heightAt returns the height of the ground at the given point, relative to
+// where the feet are usually put down.
+func (g GroundPlane) heightAt(x float64, z float64) float64 {
+	return g.Height + (g.SlopeX * x) + (g.SlopeZ * z)
+}
+
+// footDownAt returns the height (on the Y axis) which a foot at the given point
+// should be lowered to, so it lands on the ground plane.
+func (l *Legs) footDownAt(p math3d.Vector3) float64 {
+	return l.stepDownPosition() + l.GroundPlane.heightAt(p.X, p.Z)
+}
+
+// footUpAt returns the height (on the Y axis) which a foot at the given point
+// should be lifted to when stepping.
+func (l *Legs) footUpAt(p math3d.Vector3) float64 {
+	return l.footDownAt(p) + l.stepHeight()
+}
+
+// groundPlane fits a plane (y = ax + bz + c) through the feet which are holding
+// the body up, in the WORLD coordinate space, by least squares. At least three feet
+// must be down, and they must not be in a line.
+func (l *Legs) groundPlane() (a float64, b float64, c float64, err error) {
+	var sx, sz, sy, sxx, szz, sxz, sxy, szy, n float64
+
+	for i := range l.Legs {
+		if !l.supporting(i) {
+			continue
+		}
+
+		f := l.feet[i]
+		sx += f.X
+		sz += f.Z
+		sy += f.Y
+		sxx += f.X * f.X
+		szz += f.Z * f.Z
+		sxz += f.X * f.Z
+		sxy += f.X * f.Y
+		szy += f.Z * f.Y
+		n += 1
+	}
+
+	if n < 3 {
+		return 0, 0, 0, fmt.Errorf("need three feet down to find the ground, have %d", int(n))
+	}
+
+	// Solve the normal equations with Cramer's rule.
+	det := det3(sxx, sxz, sx, sxz, szz, sz, sx, sz, n)
+	if math.Abs(det) < 0.000001 {
+		return 0, 0, 0, fmt.Errorf("planted feet are in a line")
+	}
+
+	a = det3(sxy, sxz, sx, szy, szz, sz, sy, sz, n) / det
+	b = det3(sxx, sxy, sx, sxz, szy, sz, sx, sy, n) / det
+	c = det3(sxx, sxz, sxy, sxz, szz, szy, sx, sz, sy) / det
+	return a, b, c, nil
+}
+
+// det3 returns the determinant of a 3x3 matrix, given in row-major order.
+func det3(a, b, c, d, e, f, g, h, i float64) float64 {
+	return (a * ((e * i) - (f * h))) - (b * ((d * i) - (f * g))) + (c * ((d * h) - (e * g)))
+}
+
+// SetHeightAboveGround moves the body up or down, so that its origin is the
+// given distance (in mm) above the ground plane, measured perpendicular to it.
+// The ground is found from the feet which are holding the body up. If the legs can't reach that far,
+// the body is moved as far as they can reach towards it.
+func (l *Legs) SetHeightAboveGround(mm float64) error {
+	a, b, c, err := l.groundPlane()
+	if err != nil {
+		return err
+	}
+
+	h := l.hexapod
+	pos := h.Position
+	ground := (a * pos.X) + (b * pos.Z) + c
+	target := ground + (mm * math.Sqrt(1+(a*a)+(b*b)))
+
+	p := pos
+	p.Y = target
+	if h.SetPose(p, h.Rotation) == nil {
+		return nil
+	}
+
+	// Can't reach, so search for the nearest height which can. Assume that the
+	// current height is reachable.
+	near, far := pos.Y, target
+	for i := 0; i < heightSearchSteps; i++ {
+		p.Y = (near + far) / 2
+		if l.ValidatePose(p, h.Rotation) == nil {
+			near = p.Y
+		} else {
+			far = p.Y
+		}
+	}
+
+	p.Y = near
+	return h.SetPose(p, h.Rotation)
+}
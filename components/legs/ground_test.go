@@ -0,0 +1,139 @@
+package legs
+
+import (
+	"github.com/adammck/hexapod"
+	"math"
+	"testing"
+)
+
+func TestGroundPlane(t *testing.T) {
+	l := New(hexapod.NewHexapod(nil), nil)
+
+	// Tilt the ground: y = 0.1x - 0.2z + 5
+	for _, f := range l.feet {
+		f.Y = (0.1 * f.X) - (0.2 * f.Z) + 5
+	}
+
+	a, b, c, err := l.groundPlane()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if math.Abs(a-0.1) > 0.000001 || math.Abs(b+0.2) > 0.000001 || math.Abs(c-5) > 0.000001 {
+		t.Errorf("got plane a=%0.4f b=%0.4f c=%0.4f", a, b, c)
+	}
+
+	// Step a tripod, leaving the other three down.
+	l.SetGait(TripodGait)
+	l.SetState(StateStepOver)
+	for _, ii := range l.legSet()[0] {
+		l.feet[ii].Y = 1000
+	}
+
+	if _, _, _, err := l.groundPlane(); err != nil {
+		t.Errorf("unexpected error with three feet down: %s", err)
+	}
+
+	// Step four at once, which isn't a great idea.
+	l.gait = &legSetGait{"test", [][]int{[]int{0, 1, 2, 3}, []int{4, 5}}}
+	if _, _, _, err := l.groundPlane(); err == nil {
+		t.Errorf("expected error with two feet down")
+	}
+}
+
+func TestSetHeightAboveGround(t *testing.T) {
+	h := hexapod.NewHexapod(nil)
+	l := New(h, nil)
+	h.Add(l)
+
+	if err := l.SetHeightAboveGround(40); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if math.Abs(h.Position.Y-40) > 0.000001 {
+		t.Errorf("flat ground: body at Y=%0.4f, expected 40", h.Position.Y)
+	}
+
+	// On a slope, the body must be raised a bit more to keep the same clearance.
+	for _, f := range l.feet {
+		f.Y = 0.2 * f.X
+	}
+
+	if err := l.SetHeightAboveGround(40); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if exp := 40 * math.Sqrt(1.04); math.Abs(h.Position.Y-exp) > 0.000001 {
+		t.Errorf("slope: body at Y=%0.4f, expected %0.4f", h.Position.Y, exp)
+	}
+
+	// Too high to reach should stop at the highest reachable height.
+	for _, f := range l.feet {
+		f.Y = 0
+	}
+
+	if err := l.SetHeightAboveGround(1000); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if h.Position.Y < 40 || h.Position.Y > 1000 {
+		t.Errorf("unreachable: body at Y=%0.4f", h.Position.Y)
+	}
+
+	if err := l.ValidatePose(h.Position, h.Rotation); err != nil {
+		t.Errorf("body was moved out of reach: %s", err)
+	}
+
+	above := h.Position
+	above.Y += 1
+	if l.ValidatePose(above, h.Rotation) == nil {
+		t.Errorf("body stopped at Y=%0.4f, but could go higher", h.Position.Y)
+	}
+}
+
+func TestRaisedGroundPlane(t *testing.T) {
+	l := New(hexapod.NewHexapod(nil), nil)
+	flat := *l.footfallPosition(l.Legs[1])
+
+	l.GroundPlane = GroundPlane{Height: 30}
+	if p := l.footfallPosition(l.Legs[1]); math.Abs(p.Y-(flat.Y+30)) > 0.0001 {
+		t.Errorf("footfall at y=%0.2f, expected %0.2f", p.Y, flat.Y+30)
+	}
+
+	// Step the first set down onto the raised ground.
+	l.SetState(StateStepDown)
+	for _, ii := range l.legSet()[0] {
+		l.feet[ii].Y = l.footUpAt(*l.feet[ii])
+	}
+
+	for i := 0; i < stepDownCount; i++ {
+		l.stateCounter += 1
+		l.tickState()
+	}
+
+	for _, ii := range l.legSet()[0] {
+		if y := l.feet[ii].Y; y != l.stepDownPosition()+30 {
+			t.Errorf("leg %d landed at y=%0.2f, expected %0.2f", ii, y, l.stepDownPosition()+30)
+		}
+
+		if !l.planted(ii) {
+			t.Errorf("leg %d should be planted", ii)
+		}
+	}
+}
+
+func TestTiltedGroundPlane(t *testing.T) {
+	l := New(hexapod.NewHexapod(nil), nil)
+	l.GroundPlane = GroundPlane{SlopeZ: 0.1}
+
+	// The front legs land higher than the back ones.
+	front := l.footfallPosition(l.Legs[0])
+	back := l.footfallPosition(l.Legs[4])
+	if exp := l.stepDownPosition() + (0.1 * front.Z); math.Abs(front.Y-exp) > 0.0001 {
+		t.Errorf("front foot at y=%0.2f, expected %0.2f", front.Y, exp)
+	}
+
+	if back.Y >= front.Y {
+		t.Errorf("expected back foot (y=%0.2f) to be lower than front (y=%0.2f)", back.Y, front.Y)
+	}
+}
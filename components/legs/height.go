@@ -0,0 +1,116 @@
+package legs
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+
+	// The time between height corrections. Each reads the angles of every
+	// supporting leg, so this can't be too frequent.
+	heightInterval = 100 * time.Millisecond
+
+	// The furthest (in mm) that the height controller may move the feet from
+	// their goals, so a leg which can't be read properly (or a body which is
+	// being held up) doesn't wind the correction up forever.
+	maxHeightCorrection = 20.0
+)
+
+// PIDGains are the gains of a PID controller. The integral and derivative are
+// per second.
+type PIDGains struct {
+	P float64
+	I float64
+	D float64
+}
+
+// IsZero returns true if every gain is zero, i.e. the controller is disabled.
+func (g PIDGains) IsZero() bool {
+	return g.P == 0 && g.I == 0 && g.D == 0
+}
+
+// controlHeight corrects the goals of the feet to hold the body at the height
+// which they're commanding, against disturbances (e.g. a push, or servos which
+// sag under load). The actual height is found by forward kinematics from the
+// present angles of the supporting legs. The correction is only updated while
+// standing, and held while stepping; in any other state (e.g. sitting down) it's
+// forgotten, so the feet reach their usual goals. Does nothing (and forgets any
+// previous correction) unless HeightGains is set. The gains can be changed at
+// any time.
+func (l *Legs) controlHeight(now time.Time) {
+	if l.HeightGains.IsZero() || !(l.Standing() || l.stepping()) {
+		l.heightCorrection = 0
+		l.heightIntegral = 0
+		l.heightTime = time.Time{}
+		return
+	}
+
+	if !l.Standing() || now.Sub(l.heightTime) < heightInterval {
+		return
+	}
+
+	e, err := l.heightError(now)
+	if err != nil {
+		fmt.Printf("error controlling height: %s\n", err)
+		return
+	}
+
+	// Only integrate over the time since the last correction, if there was one
+	// recently enough for the derivative to mean anything.
+	var d float64
+	if dt := now.Sub(l.heightTime).Seconds(); dt < (heightInterval * 2).Seconds() {
+		l.heightIntegral += e * dt
+		d = (e - l.heightLastError) / dt
+	}
+
+	g := l.HeightGains
+	c := (g.P * e) + (g.I * l.heightIntegral) + (g.D * d)
+
+	// Stop integrating once the correction is saturated.
+	if c > maxHeightCorrection || c < -maxHeightCorrection {
+		if c > 0 {
+			c = maxHeightCorrection
+		} else {
+			c = -maxHeightCorrection
+		}
+
+		if g.I != 0 {
+			l.heightIntegral = (c - (g.P * e) - (g.D * d)) / g.I
+		}
+	}
+
+	l.heightCorrection = c
+	l.heightLastError = e
+	l.heightTime = now
+}
+
+// heightError returns the difference (in mm) between the height at which the
+// supporting feet are commanding the body to be, and the height at which it
+// actually is. It's positive when the body is too low.
+func (l *Legs) heightError(now time.Time) (float64, error) {
+	var want, got float64
+	n := 0
+
+	m := l.hexapod.Local()
+	for i, leg := range l.Legs {
+		if !leg.Initialized || !l.supporting(i) {
+			continue
+		}
+
+		a, err := l.legAngles(i, now, l.PositionMaxAge)
+		if err != nil {
+			return 0, err
+		}
+
+		want += -l.feet[i].MultiplyByMatrix44(m).Y
+		got += -leg.ForwardKinematics(a).Y
+		n += 1
+	}
+
+	if n == 0 {
+		return 0, fmt.Errorf("no feet are down")
+	}
+
+	return (want - got) / float64(n), nil
+}
@@ -0,0 +1,122 @@
+package legs
+
+import (
+	"github.com/adammck/hexapod"
+	"math"
+	"testing"
+	"time"
+)
+
+// sag moves the mock servos of each leg to where they'd be if the body had sunk
+// the given distance (in mm) below its goal, as if it were being pushed down.
+func sag(t *testing.T, l *Legs, m [6][4]*mockServo, mm float64) {
+	for i, leg := range l.Legs {
+		p := l.footGoal(i)
+		p.Y += mm
+
+		c, f, tt, tr, err := leg.SolveIKWithUp(p, l.tarsusDirection())
+		if err != nil {
+			t.Fatalf("leg %s: can't sag: %s", leg.Name, err)
+		}
+
+		a := leg.servoAngles(JointAngles{c, f, tt, tr})
+		for j, s := range []float64{a.Coxa, a.Femur, a.Tibia, a.Tarsus} {
+			m[i][j].angle = s
+		}
+	}
+}
+
+func TestControlHeight(t *testing.T) {
+	c := &hexapod.FakeClock{T: time.Unix(100, 0)}
+	h := hexapod.NewHexapod(nil)
+	h.Clock = c
+	l, _, m := mockLegs(h)
+	l.SetState(StateStand)
+	for _, leg := range l.Legs {
+		leg.Initialized = true
+	}
+
+	run := func(ticks int) float64 {
+		for tick := 0; tick < ticks; tick++ {
+			l.updateFeet()
+			sag(t, l, m, 10)
+			l.controlHeight(c.Now())
+			c.Advance(20 * time.Millisecond)
+		}
+
+		e, err := l.heightError(c.Now())
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		return e
+	}
+
+	// Without gains, the push isn't corrected.
+	if e := run(50); math.Abs(e-10) > 0.01 {
+		t.Errorf("disabled: error is %0.4f, expected 10", e)
+	}
+
+	// Proportional alone corrects some of it, but not all.
+	l.HeightGains = PIDGains{P: 0.5}
+	if e := run(100); math.Abs(e-(20.0/3)) > 0.01 {
+		t.Errorf("proportional: error is %0.4f, expected 6.67", e)
+	}
+
+	// With some integral, the body is driven back to the commanded height.
+	l.HeightGains = PIDGains{P: 0.5, I: 2}
+	if e := run(500); math.Abs(e) > 0.01 {
+		t.Errorf("PI: error is %0.4f, expected 0", e)
+	}
+
+	if math.Abs(l.heightCorrection-10) > 0.01 {
+		t.Errorf("correction is %0.4f, expected 10", l.heightCorrection)
+	}
+}
+
+func TestHeightCorrectionStepAndSit(t *testing.T) {
+	c := &hexapod.FakeClock{T: time.Unix(100, 0)}
+	h := hexapod.NewHexapod(nil)
+	h.Clock = c
+	l, _, _ := mockLegs(h)
+	l.HeightGains = PIDGains{P: 0.5, I: 2}
+	l.SetState(StateStand)
+
+	// As if the body had been sagging for a while.
+	l.heightCorrection = 10
+	l.heightIntegral = 5
+
+	// Only the feet which are holding the body up are corrected. The swinging
+	// feet are lifted as high as usual.
+	l.SetState(StateStepUp)
+	l.controlHeight(c.Now())
+	if l.supporting(l.legSet()[0][0]) {
+		t.Fatalf("expected the first leg set to be swinging")
+	}
+
+	m := h.Local()
+	for i := range l.Legs {
+		exp := l.feet[i].MultiplyByMatrix44(m).Y
+		if l.supporting(i) {
+			exp -= 10
+		}
+
+		if y := l.footGoal(i).Y; math.Abs(y-exp) > 0.000001 {
+			t.Errorf("stepping: leg %d: goal at %0.2f, expected %0.2f", i, y, exp)
+		}
+	}
+
+	// Sitting down forgets it, so the feet go exactly where they're sent.
+	l.SetState(StateSitDown)
+	l.controlHeight(c.Now())
+	if l.heightCorrection != 0 || l.heightIntegral != 0 {
+		t.Errorf("sitting: correction is %0.2f (integral %0.2f), expected 0", l.heightCorrection, l.heightIntegral)
+	}
+
+	for i := range l.Legs {
+		exp := l.feet[i].MultiplyByMatrix44(m).Y
+		if y := l.footGoal(i).Y; y != exp {
+			t.Errorf("sitting: leg %d: goal at %0.2f, expected %0.2f", i, y, exp)
+		}
+	}
+}
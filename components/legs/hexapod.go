@@ -7,74 +7,237 @@ import (
 	"github.com/adammck/hexapod/math3d"
 	"github.com/adammck/hexapod/utils"
 	"math"
+	"sort"
 	"strings"
 	"time"
 )
 
-type State string
-
 const (
-	sDefault  State = ""
-	sInit     State = "sInit"
-	sHalt     State = "sHalt"
-	sStandUp  State = "sStandUp"
-	sSitDown  State = "sSitDown"
-	sStand    State = "sStand"
-	sStepUp   State = "sStepUp"
-	sStepOver State = "sStepOver"
-	sStepDown State = "sStepDown"
-
-	// The offset (on the Y axis) which feet should be moved to on the up step,
-	// relative to the origin.
+
+	// The default height (on the Y axis) which feet should be lifted to on the up
+	// step, relative to where they touch down. See StepHeight.
 	baseFootUp = 40.0
 
-	// The offset (on the Y axis) which feet should be positioned at on the down
-	// step (which is the default position when standing), relative to the
-	// origin.
+	// The default offset (on the Y axis) which feet should be positioned at on
+	// the down step (which is the default position when standing), relative to
+	// the origin. See FootDown.
 	baseFootDown = 0.0
 
-	// Blah
+	// The clearance when sitting, and the default clearance when standing. See
+	// StandClearance.
 	sitDownClearance = 0.0
 	standUpClearance = 40.0
 
-	// Distance (on the X/Z axis) from the origin to the point at which the feet
-	// should be positioned. This isn't adjustable at runtime, because there are
-	// very few valid settings.
+	// The distance (in mm) which the clearance is changed by each tick, when
+	// standing up, sitting down, or switching stance.
+	clearanceStep = 2.0
+
+	// The default CrouchClearance, and the (much smaller) distance which the
+	// clearance is changed by each tick while crouching.
+	defaultCrouchClearance = 15.0
+	crouchStep             = 0.2
+
+	// The default distance (on the X/Z axis) from the origin to the point at
+	// which the feet should be positioned. See StanceRadius and StrideRadius.
 	stepRadius = 220.0
 
-	// The number of legs to move at once.
-	legSetSize = 2
+	// The default minimum stability margin, in mm. See IsStable.
+	defaultStabilityMargin = 40.0
 
 	// Minimum distance which the desired foot position should be from its actual
 	// position before a step should be taken to correct it.
 	minStepDistance = 20.0
 
+	// The distance (in mm) and rotation (in degrees) which the body must have
+	// moved since the last footfalls were planned, for the next ones to be
+	// placed at StrideRadius rather than StanceRadius. See striding.
+	minStrideDistance = 1.0
+	minStrideRotation = 0.1
+
 	// The number of ticks which should be spent in each state.
 	// TODO: Replace these with durations, ticks are variable now.
 	stepUpCount   = 4
 	stepOverCount = 4
 	stepDownCount = 4
 
+	// The default SitDownDuration. This must be well within the time which the
+	// main loop keeps running after Shutdown is set.
+	defaultSitDownDuration = 1500 * time.Millisecond
+
 	// The time (in seconds) between each leg initialization. This should be as
 	// low as possible, since it delays startup.
 	initInterval = 0.25
 )
 
+// Network is the subset of dynamixel.DynamixelNetwork which the legs use.
+type Network interface {
+	SetBuffered(buffered bool)
+	Action() error
+}
+
 type Legs struct {
 	hexapod *hexapod.Hexapod
-	Network *dynamixel.DynamixelNetwork
+	Network Network
 
 	// The state that the legs are currently in.
 	State        State
 	stateCounter int
 	stateTime    time.Time
 
+	// Called (with the state being left or entered) once each time the state
+	// changes, and each tick (after the work of the state has been done) while
+	// in a given state. These can't change the state; see SetState.
+	OnExitState  func(s State)
+	OnEnterState func(s State)
+	OnState      map[State]func()
+
+	// Whether the state callbacks are currently running.
+	inStateCallback bool
+
 	// ???
 	Legs [6]*Leg
 
+	// The number of times to retry reads from servos (pings and present angles)
+	// which fail, before giving up and returning an error. It's copied to each
+	// leg at boot.
+	Retries int
+
+	// The minimum stability margin (in mm) at which IsStable returns true.
+	MinStabilityMargin float64
+
+	// The source of the measured attitude of the body (e.g. an IMU), and the
+	// maximum tilt (in degrees) at which the legs will take a step. Beyond that,
+	// they'll hold their stance, since stepping on a steep slope is how the
+	// hexapod falls over. No limit is enforced if either of these are zero.
+	Attitude AttitudeSource
+	MaxTilt  float64
+
+	// Whether we've already warned the operator that the hexapod is too tilted
+	// to step. This is reset once it's safe again.
+	tiltWarned bool
+
+	// The fewest feet which must be left on the ground when each leg set is
+	// lifted. If the gait has its own minimum (see SupportGait), the higher is
+	// used. At least one foot is always left down. See minSupport.
+	MinSupport    int
+	supportWarned bool
+
+	// Whether the legs are stepping one at a time, because the current gait
+	// would leave too few feet down, or can't step at all. See supported.
+	catchUp  bool
+	stranded bool
+
+	// The pose (in the same terms as the solved IK angles) which the operator is
+	// asked to hold a leg in while calibrating it. See CalibrateLeg.
+	ReferencePose JointAngles
+
+	// Called (with the index of the leg, and the distance in mm) when a planted
+	// foot is measured to have moved further than SlipThreshold since it was
+	// planted. This reads the servos regularly, so is disabled when nil.
+	OnSlip        func(leg int, distance float64)
+	SlipThreshold float64
+
+	// The measured positions (in the world space) of each foot when it was
+	// planted, and the last time that they were checked for slip.
+	slipRef  [6]*math3d.Vector3
+	slipTime time.Time
+
+	// The distance (on the X/Z axis, in mm) from the origin at which the feet
+	// rest when the hexapod is standing still, and at which they're placed when
+	// taking a step while the body is moving. A wider stance is more stable, but
+	// the legs can reach further when stepping closer in. Once the body stops,
+	// the feet settle back at StanceRadius. Use SetStepRadius to set both at once.
+	StanceRadius float64
+	StrideRadius float64
+
+	// The fraction of the distance between the body and its home position (the
+	// point at which the feet would be at their home positions) to drift each
+	// tick while standing, when the body isn't being moved by anything else.
+	// Zero disables it. See nudgeHome.
+	HomeNudge float64
+
+	// The vertical profile of the feet while they're lifted and lowered. When nil,
+	// the feet are moved all at once, as fast as the servos can go.
+	LiftEasing  Easing
+	LowerEasing Easing
+
+	// How long to take to sit down, and the profile of the movement, so the
+	// hexapod folds up deliberately rather than flopping. The body is lowered
+	// linearly when the easing is nil. See sitDown.
+	SitDownDuration time.Duration
+	SitDownEasing   Easing
+
+	// The clearance when the legs started to sit down.
+	sitDownFrom float64
+
+	// The delay between each leg in a set beginning to lift, so their servos
+	// don't all draw their peak current at once. Zero lifts them together. See
+	// liftCounter.
+	LiftStagger time.Duration
+
+	// The state counter at which each leg of the current set (by its index in
+	// the set, not in Legs) began to lift, or -1 if it hasn't yet.
+	liftStart [6]int
+
+	// How far to move the body (as a fraction of the distance between the center
+	// of pressure and the center of the planted feet) to even out the load on
+	// the feet while standing. Zero disables it. See balance.
+	BalanceGain float64
+	balanceTime time.Time
+
+	// The gains of the controller which holds the body at the commanded height
+	// while standing, by correcting the goals of the feet. Zero disables it. See
+	// controlHeight.
+	HeightGains PIDGains
+
+	// The working state of the height controller: how far (in mm) the feet are
+	// lowered below their goals, the integral and last value of the error, and
+	// when it was measured.
+	heightCorrection float64
+	heightIntegral   float64
+	heightLastError  float64
+	heightTime       time.Time
+
+	// The position of the body at the end of the last nudge, to spot whether
+	// anything else has moved it since.
+	nudgePos *math3d.Vector3
+
+	// The minimum distance (on the X/Z axis, in mm) between the feet of adjacent
+	// legs. Footfalls which are planned any closer are pushed apart, so the feet
+	// don't collide during sharp turns. Zero disables it. See separateFootfalls.
+	FootClearance float64
+
+	// The clearance (in mm) which the body is raised to when standing, and the
+	// height which feet are lifted to when stepping. See SetStance.
+	StandClearance float64
+	StepHeight     float64
+
+	// The fraction of StepHeight to lift the feet by. See Limp.
+	limp float64
+
+	// The height (on the Y axis, in the world space) at which the feet touch the
+	// ground. Soft surfaces (e.g. carpet) need the feet pushed a little lower to
+	// bear weight. Setting this directly only takes effect as each foot is
+	// stepped, and planted feet stay where they are; see SetFootDown.
+	FootDown float64
+
+	// The ground which the feet are put down on. The feet only move onto it as
+	// they're stepped, like FootDown.
+	GroundPlane GroundPlane
+
+	// Whether to keep the tarsi vertical in the world space while the body is
+	// tilted, so the feet stay flat on the ground. Otherwise, they're kept
+	// perpendicular to the body, and slide around as it leans.
+	LevelFeet bool
+
 	// ???
 	baseClearance float64
 
+	// The clearance which the body is lowered to while crouched, and whether it
+	// is. See SetCrouched.
+	CrouchClearance float64
+	crouched        bool
+
 	// The order in which legs are initialized at startup. We start them one at
 	// a time, rather than all at once, to reduce the load on the power supply.
 	// When starting them all at once, quite often, the voltage drops low enough
@@ -94,25 +257,130 @@ type Legs struct {
 	// where the foot is now, but are set when the foot should be relocated.
 	nextFeet [6]*math3d.Vector3
 
+	// The position and rotation of the body when the last footfalls were
+	// planned. See striding.
+	footfallPos      math3d.Vector3
+	footfallRotation float64
+
 	// Whether the hexapod should be prevented from moving its feet. It can't
 	// walk when this is enable, only lean, so this is only useful for testing.
 	dontMove bool
 
+	// Whether to keep stepping even when the feet don't need to move, so the
+	// hexapod marches on the spot. This is useful for checking the step timing
+	// and the servos with the body on a stand.
+	March bool
+
 	// The count (not index!) of the leg which we're currently initializing.
 	// When it reaches six, we've finished initialzing.
 	initCounter int
 
+	// The maximum change (in degrees) in the goal of each servo per tick, from
+	// the position that it was read at when the leg was initialized, until the
+	// leg has caught up with its goal. This eases the legs into the stance, where
+	// they'd otherwise snap there from wherever they were resting. Zero disables
+	// it. See Leg.MaxJointDelta.
+	InitJointDelta float64
+
+	// What to do with the servos once the legs have sat down and halted. See
+	// HaltBehavior.
+	HaltBehavior HaltBehavior
+
+	// The voltage below which the servos might brown out, i.e. reset. If this is
+	// set, the voltage is measured while initializing, and as many legs as the
+	// supply can handle are initialized at once. See initBatchSize.
+	BrownoutVoltage float64
+
+	// The number of init intervals which have passed, the voltage measured
+	// before the last batch of legs was initialized, and the size of it.
+	initBatches int
+	initVoltage float64
+	initBatch   int
+
+	// Whether Restart should be called at the start of the next tick. See
+	// RequestRestart.
+	restartPending bool
+
 	// Which legset are we currently stepping?
 	sLegsIndex int
+
+	// The fraction of each step cycle which each leg spends planted on the
+	// ground. Zero means as little as the gait allows. See SetDutyFactor.
+	DutyFactor float64
+
+	// How long to wait after each leg set has landed before lifting the next,
+	// to let the body settle. This is on top of the pause for the duty factor.
+	// Zero means no wait. See settled.
+	SettleDelay time.Duration
+	landedTime  time.Time
+
+	// How far (in degrees per N*m of load) the tarsus of each foot gives while
+	// it's being put down, and for AnkleCushion after it lands, like an ankle.
+	// This softens the impact on hard floors. It's on top of the Compliance of
+	// the leg. Zero disables it. See ankleCompliance.
+	AnkleCompliance float64
+	AnkleCushion    time.Duration
+
+	// When the foot of each leg last touched down.
+	footLanded [6]time.Time
+
+	// The index of the leg which has been taken out of the gait to be moved
+	// around directly, and its goal in the hexapod coordinate space. See
+	// SetManipulator.
+	manipulator     int
+	manipulatorGoal math3d.Vector3
+
+	// The last read of the present angles of each leg, and how old that can be
+	// before it's read again. Slip checks and force estimates share these, to
+	// save bus time. See Positions.
+	positions      [6]cachedAngles
+	PositionMaxAge time.Duration
+
+	// Which legs have been taken out of the gait permanently, e.g. because
+	// they're broken or missing. See DisableLeg.
+	disabled [6]bool
+
+	// Where to record the goals of every foot and servo each tick. Nothing is
+	// recorded when this is nil.
+	AngleLog *AngleLog
+
+	// Where to record the path of every swinging foot each tick, and the number
+	// of steps which each leg has started. Nothing is recorded when this is nil.
+	SwingLog *SwingLog
+	steps    [6]int
+
+	// The pose which the legs are frozen in, or nil if they're not. See Freeze.
+	frozen *frozenPose
+
+	// The gait which the legs are stepping with, and the gait which they'll
+	// switch to once the current step cycle is finished. See SetGait.
+	gait     Gait
+	nextGait Gait
 }
 
 func New(h *hexapod.Hexapod, n *dynamixel.DynamixelNetwork) *Legs {
 	l := &Legs{
-		hexapod:       h,
-		Network:       n,
-		State:         sDefault,
-		baseClearance: sitDownClearance,
-		initOrder:     []int{0, 3, 1, 4, 2, 5},
+		hexapod:            h,
+		Network:            n,
+		State:              StateDefault,
+		baseClearance:      sitDownClearance,
+		MinStabilityMargin: defaultStabilityMargin,
+		ReferencePose:      defaultReferencePose,
+		SlipThreshold:      defaultSlipThreshold,
+		StanceRadius:       stepRadius,
+		StrideRadius:       stepRadius,
+		StandClearance:     standUpClearance,
+		StepHeight:         baseFootUp,
+		limp:               1,
+		FootDown:           baseFootDown,
+		FootClearance:      defaultFootClearance,
+		PositionMaxAge:     defaultPositionMaxAge,
+		SitDownDuration:    defaultSitDownDuration,
+		SitDownEasing:      EaseInOut,
+		CrouchClearance:    defaultCrouchClearance,
+		gait:               RippleGait,
+		manipulator:        noManipulator,
+		initOrder:          []int{0, 3, 1, 4, 2, 5},
 		Legs: [6]*Leg{
 
 			// Leg origins are relative to the hexapod origin, which is the X/Z
@@ -136,54 +404,142 @@ func New(h *hexapod.Hexapod, n *dynamixel.DynamixelNetwork) *Legs {
 		l.homeFootPosition(l.Legs[5]),
 	}
 
+	for _, leg := range l.Legs {
+		leg.OnDiscontinuity = logDiscontinuity
+	}
+
 	return l
 }
 
 // Boot pings all servos, and returns an error if any of them fail to respond.
+// The legs refuse to stand up with servos missing, since a leg which can't move
+// can't hold the body up.
 func (l *Legs) Boot() error {
-	notResponding := make([]string, 0)
+	_, err := l.Ping()
+	if err != nil {
+		return err
+	}
 
+	// Don't bother sending ACKs for writes.
 	for _, leg := range l.Legs {
+		leg.Retries = l.Retries
 		for _, servo := range leg.Servos() {
-			fmt.Printf("Pinging #%d\n", servo.Ident)
-			pingErr := servo.Ping()
-			if pingErr != nil {
-				notResponding = append(notResponding, string(servo.Ident))
-			}
+			servo.SetStatusReturnLevel(1)
 		}
 	}
 
-	if len(notResponding) != 0 {
-		strings.Join(notResponding, ", ")
-		return fmt.Errorf("servos not responding to ping: %s")
+	return nil
+}
+
+// Ping pings every servo of every leg, and returns a map of servo ID to whether
+// it responded. If any didn't, an error listing them (and which joint each is
+// expected to be, to help spot cables plugged into the wrong leg) is also
+// returned. Failed pings are retried (see Retries) before giving up.
+func (l *Legs) Ping() (map[uint8]bool, error) {
+	res := map[uint8]bool{}
+	missing := []int{}
+	joints := map[int]string{}
+
+	for i, leg := range l.Legs {
+		if l.disabled[i] {
+			continue
+		}
+
+		ids := leg.ServoIDs()
+		for i, servo := range leg.Servos() {
+			fmt.Printf("Pinging #%d\n", ids[i])
+			err := utils.Retry(l.Retries, servo.Ping)
+			res[ids[i]] = (err == nil)
+			if err != nil {
+				missing = append(missing, int(ids[i]))
+				joints[int(ids[i])] = fmt.Sprintf("%s %s", leg.Name, jointNames[i])
+			}
+		}
 	}
 
-	// Don't bother sending ACKs for writes.
-	for _, leg := range l.Legs {
-		for _, servo := range leg.Servos() {
-			servo.SetStatusReturnLevel(1)
+	if len(missing) != 0 {
+		sort.Ints(missing)
+		s := make([]string, len(missing))
+		for i, id := range missing {
+			s[i] = fmt.Sprintf("%d (%s)", id, joints[id])
 		}
+
+		return res, fmt.Errorf("%w: %s", hexapod.ErrServoMissing, strings.Join(s, ", "))
 	}
 
-	return nil
+	return res, nil
 }
 
+// SetState switches to the given state, and calls the exit and enter callbacks
+// if it has changed. Setting the state from within a callback is ignored, so
+// they can't change it out from under the loop or each other.
 func (l *Legs) SetState(s State) {
+	if l.inStateCallback {
+		fmt.Printf("can't set state to %s from a state callback\n", s)
+		return
+	}
+
+	prev := l.State
 	l.stateCounter = 0
-	l.stateTime = time.Now()
+	l.stateTime = l.hexapod.Now()
 	l.State = s
+
+	if s == prev {
+		return
+	}
+
+	l.stateCallback(func() {
+		if l.OnExitState != nil {
+			l.OnExitState(prev)
+		}
+
+		if l.OnEnterState != nil {
+			l.OnEnterState(s)
+		}
+	})
+}
+
+// stateCallback runs the given function, which calls one of the state
+// callbacks, while preventing it from setting the state.
+func (l *Legs) stateCallback(f func()) {
+	l.inStateCallback = true
+	defer func() { l.inStateCallback = false }()
+	f()
+}
+
+// Standing returns true if the legs are standing still, i.e. not initializing,
+// stepping, or sitting down.
+func (l *Legs) Standing() bool {
+	return l.State == StateStand
 }
 
 // stepUpPosition returns the height (on the Y axis) which a foot should reach
 // when stepping up. This is generally static, but is increased while the L2
 // trigger is pressed. This is pretty handy for stepping over obstacles.
 func (l *Legs) stepUpPosition() float64 {
-	//return baseFootUp + ((float64(h.Controller.L2) / 255.0) * 100)
-	return baseFootUp
+	//return l.StepHeight + ((float64(h.Controller.L2) / 255.0) * 100)
+	return l.FootDown + l.stepHeight()
 }
 
+// stepDownPosition returns the height (on the Y axis) which a foot should be
+// lowered to when stepping down. See FootDown.
 func (l *Legs) stepDownPosition() float64 {
-	return baseFootDown
+	return l.FootDown
+}
+
+// SetFootDown changes FootDown, and moves the feet which are down by the same
+// amount, so they're still planted at the new height. Otherwise they'd be left
+// hanging above (or pushed below) it until they're next stepped, and wouldn't
+// count as support in the meantime.
+func (l *Legs) SetFootDown(y float64) {
+	d := y - l.FootDown
+	for _, foot := range l.feet {
+		if foot.Y <= l.footDownAt(*foot) {
+			foot.Y += d
+		}
+	}
+
+	l.FootDown = y
 }
 
 // Clearance returns the distance (on the Y axis) which the body should be off
@@ -197,7 +553,7 @@ func (l *Legs) Clearance() float64 {
 // StateDuration returns the duration since the hexapod entered the current
 // state. This is a pretty fragile and crappy way of synchronizing things.
 func (l *Legs) StateDuration() time.Duration {
-	return time.Since(l.stateTime)
+	return l.hexapod.Now().Sub(l.stateTime)
 }
 
 //
@@ -224,13 +580,78 @@ func (l *Legs) SyncLegs(f func(leg *Leg)) {
 	})
 }
 
+// SetStepRadius sets both StanceRadius and StrideRadius to the given distance.
+func (l *Legs) SetStepRadius(r float64) {
+	l.StanceRadius = r
+	l.StrideRadius = r
+}
+
 // homeFootPosition returns a vector in the WORLD coordinate space for the home
-// position of the given leg.
+// position of the given leg, where it rests when standing up.
 func (l *Legs) homeFootPosition(leg *Leg) *math3d.Vector3 {
-	r := utils.Rad(l.hexapod.Rotation + leg.Angle)
-	x := math.Cos(r) * stepRadius
-	z := -math.Sin(r) * stepRadius
-	return l.hexapod.Position.Add(math3d.Vector3{x, l.stepDownPosition(), z})
+	return l.radialFootPosition(leg, l.StanceRadius)
+}
+
+// footfallPosition returns a vector in the WORLD coordinate space for the point
+// at which the given leg should be placed when it next steps down. That's at
+// StrideRadius while the body is moving, and its home position otherwise, so
+// the feet come to rest at StanceRadius.
+func (l *Legs) footfallPosition(leg *Leg) *math3d.Vector3 {
+	if l.striding() {
+		return l.radialFootPosition(leg, l.StrideRadius)
+	}
+
+	return l.homeFootPosition(leg)
+}
+
+// striding returns true if the body has moved or turned since the last
+// footfalls were planned, i.e. it's walking rather than settling.
+func (l *Legs) striding() bool {
+	h := l.hexapod
+	return h.Position.Distance(l.footfallPos) > minStrideDistance ||
+		math.Abs(utils.NormalizeDeg(h.Rotation-l.footfallRotation)) > minStrideRotation
+}
+
+// radialFootPosition returns a vector in the WORLD coordinate space for a foot
+// on the ground, along the heading of the given leg, r mm from the origin. If
+// any legs are disabled, the feet are shifted so the origin is still over the
+// middle of them.
+func (l *Legs) radialFootPosition(leg *Leg, r float64) *math3d.Vector3 {
+	v := math3d.Vector3{r, l.stepDownPosition(), 0}.RotateY(leg.Angle)
+	v = *v.Subtract(l.disabledOffset(r))
+	p := l.hexapod.Position.Add(v.RotateY(l.hexapod.Rotation))
+	p.Y += l.GroundPlane.heightAt(p.X, p.Z)
+	return p
+}
+
+// ValidatePose returns an error if any leg wouldn't be able to reach its foot
+// (where it is now, in the world space) if the hexapod were moved to the given
+// position and rotation. Moving there would collapse the hexapod.
+func (l *Legs) ValidatePose(position math3d.Vector3, rotation float64) error {
+	if l.frozen != nil {
+		return errFrozen
+	}
+
+	if err := l.holdBack(position); err != nil {
+		return err
+	}
+
+	h := *l.hexapod
+	h.Position = position
+	h.Rotation = rotation
+	m := h.Local()
+
+	for i, leg := range l.Legs {
+		if !l.inGait(i) {
+			continue
+		}
+
+		if !leg.CanReach(l.feet[i].MultiplyByMatrix44(m)) {
+			return fmt.Errorf("leg %s can't reach its foot from %s", leg.Name, position)
+		}
+	}
+
+	return nil
 }
 
 // Projects a point in the World coordinate space into the coordinate space of
@@ -242,38 +663,70 @@ func (l *Legs) Project(legIndex int, vec math3d.Vector3) math3d.Vector3 {
 	return vec.MultiplyByMatrix44(*wm)
 }
 
+// tarsusDirection returns the direction (in the hexapod coordinate space) which
+// the tarsi should point, from the foot towards the tibia. This is straight up,
+// unless LevelFeet is set, in which case it's straight up in the WORLD space.
+func (l *Legs) tarsusDirection() math3d.Vector3 {
+	if l.frozen != nil {
+		return l.frozen.up
+	}
+
+	if !l.LevelFeet {
+		return up
+	}
+
+	m := l.hexapod.Local()
+	a := up.MultiplyByMatrix44(m)
+	b := math3d.ZeroVector3.MultiplyByMatrix44(m)
+	return *a.Subtract(b)
+}
+
+// legSet returns the sets of legs (by index) which the current gait steps with.
+// The manipulator and any disabled legs are left out, so some sets might be
+// empty.
 func (l *Legs) legSet() [][]int {
-	switch legSetSize {
-	case 1:
-		return [][]int{
-			[]int{0},
-			[]int{1},
-			[]int{2},
-			[]int{3},
-			[]int{4},
-			[]int{5},
-		}
-	case 2:
-		return [][]int{
-			[]int{0, 3},
-			[]int{1, 4},
-			[]int{2, 5},
-		}
-	case 3:
-		return [][]int{
-			[]int{0, 2, 4},
-			[]int{1, 3, 5},
+	if l.catchUp {
+		return l.catchUpSets()
+	}
+
+	sets := l.gait.LegSets()
+	if l.manipulator == noManipulator && l.disabled == [6]bool{} {
+		return sets
+	}
+
+	res := make([][]int, len(sets))
+	for i, set := range sets {
+		res[i] = []int{}
+		for _, ii := range set {
+			if l.inGait(ii) {
+				res[i] = append(res[i], ii)
+			}
 		}
-	default:
-		panic("invalid legSetSize!")
 	}
+
+	return res
+}
+
+// wantsStep returns true if another step cycle should be started, either because
+// the feet need to move, or because we're marching.
+func (l *Legs) wantsStep() bool {
+	return !l.hexapod.Shutdown && (l.March || l.needsMove())
+}
+
+// SetMarching sets March, to start or stop stepping on the spot.
+func (l *Legs) SetMarching(marching bool) {
+	l.March = marching
 }
 
 // Returns true if any of the feet are of sufficient distance from their desired
 // positions that we need to take a step.
 func (l *Legs) needsMove() bool {
 	for i, _ := range l.Legs {
-		a := l.homeFootPosition(l.Legs[i])
+		if !l.inGait(i) {
+			continue
+		}
+
+		a := l.footfallPosition(l.Legs[i])
 		a.Y = l.feet[i].Y
 		if l.feet[i].Distance(*a) > minStepDistance {
 			return true
@@ -284,91 +737,158 @@ func (l *Legs) needsMove() bool {
 }
 
 func (l *Legs) Tick(now time.Time) error {
+	if l.restartPending {
+		l.restartPending = false
+		l.Restart()
+	}
+
+	// Shutting down overrides a freeze, since the main loop won't wait for long
+	// for the legs to sit down.
+	if l.hexapod.Shutdown && l.frozen != nil {
+		l.Unfreeze()
+	}
+
+	if l.frozen != nil {
+		l.updateFeet()
+		return nil
+	}
+
+	// Once the hexapod is shutting down, sit down as soon as the feet are all on
+	// the ground.
+	if l.hexapod.Shutdown && l.State == StateStand {
+		l.SetState(StateSitDown)
+	}
+
 	l.stateCounter += 1
 	fmt.Printf("State=%s[%d]\n", l.State, l.stateCounter)
 
-	switch l.State {
-	case sDefault:
-		l.SetState(sInit)
+	err := l.tickState()
+	if err != nil {
+		return err
+	}
 
-	case sInit:
+	if f, ok := l.OnState[l.State]; ok {
+		l.stateCallback(f)
+	}
 
-		// Initialize one leg each second.
-		if int(l.StateDuration().Seconds()/initInterval) > l.initCounter {
+	l.updateFeet()
+	l.logAngles(now)
+	l.logSwing(now)
+	l.checkSlip(now)
+	l.balance(now)
+	l.controlHeight(now)
+	return nil
+}
 
-			// If we still have legs to initialize, do the next one.
-			if l.initCounter < len(l.Legs) {
-				leg := l.Legs[l.initOrder[l.initCounter]]
+// tickState performs the work of the current state, and advances to the next
+// state if it's time.
+func (l *Legs) tickState() error {
+	switch l.State {
+	case StateDefault:
+		l.SetState(StateInit)
 
-				for _, servo := range leg.Servos() {
-					servo.SetTorqueEnable(true)
-					servo.SetMovingSpeed(1024)
-				}
+	case StateInit:
 
-				leg.Initialized = true
-				l.initCounter += 1
+		// Initialize a batch of legs (usually just one) each interval.
+		if int(l.StateDuration().Seconds()/initInterval) > l.initBatches {
+			l.initBatches += 1
+
+			// If we still have legs to initialize, do the next batch.
+			if l.initCounter < len(l.Legs) {
+				l.initLegs(l.initBatchSize())
 
 			} else {
 				// No more legs to initialize, so advance to the next state.
-				// We wait until the next initCounter before advancing, to
-				// give the last leg a second to start.
-				l.SetState(sStandUp)
+				// We wait until the next interval before advancing, to give
+				// the last leg a second to start.
+				l.SetState(StateStandUp)
 			}
 		}
 
 	// TODO: Remove this state? Maybe we should add a separate interface method
 	//       which is called when the parent wants to shut everything down.
-	case sHalt:
+	case StateHalt:
 		for _, leg := range l.Legs {
 			for _, servo := range leg.Servos() {
 				servo.SetStatusReturnLevel(2)
-				servo.SetTorqueEnable(false)
 				servo.SetLed(false)
+				if l.HaltBehavior == HaltRelax {
+					servo.SetTorqueEnable(false)
+				}
+			}
+
+			if l.HaltBehavior == HaltRelax {
+				leg.ForgetGoals()
 			}
 		}
 
-		return fmt.Errorf("halted")
+		return hexapod.ErrHalted
 
 	// After initialzation, raise the clearance to lift the body off the
 	// ground, into the standing position.
-	case sStandUp:
-		l.baseClearance += 2
-		if l.baseClearance >= standUpClearance {
-			l.SetState(sStand)
+	case StateStandUp:
+		l.baseClearance += clearanceStep
+		if l.baseClearance >= l.StandClearance {
+			l.SetState(StateStand)
 		}
 
 	// Before halting, lower the clearance until the body is sitting on the
-	// ground.
-	case sSitDown:
-		l.baseClearance -= 2
-		if l.baseClearance <= sitDownClearance {
-			l.SetState(sHalt)
+	// ground. The target is fixed, so nothing which changes while sitting (e.g.
+	// the stance or step height) can stop us from reaching it.
+	case StateSitDown:
+		if l.sitDown() {
+			l.SetState(StateHalt)
 		}
 
-	case sStand:
-		if !l.dontMove && l.needsMove() {
-			l.SetState(sStepUp)
+	case StateStand:
+		l.adjustClearance()
+		l.nudgeHome()
+		if l.sLegsIndex == 0 {
+			l.applyGait()
 		}
 
-	case sStepUp:
-		if l.stateCounter == 1 {
+		if !l.dontMove && l.wantsStep() && l.canStep() {
+			l.SetState(StateStepUp)
+		}
+
+	case StateStepUp:
+		if l.stateCounter <= 1 {
+			l.resetLift()
 			for _, ii := range l.legSet()[l.sLegsIndex] {
-				l.feet[ii].Y = l.stepUpPosition()
+				l.steps[ii] += 1
+			}
+		}
+
+		lifted := true
+		for k, ii := range l.legSet()[l.sLegsIndex] {
+			c := l.liftCounter(k)
+			if c < stepUpCount {
+				lifted = false
+			}
+
+			if c > 0 {
+				f := *l.feet[ii]
+				l.feet[ii].Y = ease(l.LiftEasing, c, stepUpCount, l.footDownAt(f), l.footUpAt(f))
 			}
 		}
 
 		// TODO: Project the next step position, rather than just moving it home
 		//       every time. This will half (!!) the number of steps to move in a
 		//       constant direciton.
-		if l.stateCounter >= stepUpCount {
+		if lifted {
 			for _, ii := range l.legSet()[l.sLegsIndex] {
-				l.nextFeet[ii] = l.homeFootPosition(l.Legs[ii])
+				l.nextFeet[ii] = l.footfallPosition(l.Legs[ii])
 			}
 
-			l.SetState(sStepOver)
+			l.footfallPos = l.hexapod.Position
+			l.footfallRotation = l.hexapod.Rotation
+
+			l.separateFootfalls(l.legSet()[l.sLegsIndex])
+
+			l.SetState(StateStepOver)
 		}
 
-	case sStepOver:
+	case StateStepOver:
 		if l.stateCounter == 1 {
 			for _, ii := range l.legSet()[l.sLegsIndex] {
 				l.feet[ii].X = l.nextFeet[ii].X
@@ -378,32 +898,42 @@ func (l *Legs) Tick(now time.Time) error {
 		}
 
 		if l.stateCounter >= stepOverCount {
-			l.SetState(sStepDown)
+			l.SetState(StateStepDown)
 		}
 
-	case sStepDown:
-		if l.stateCounter == 1 {
-			for _, ii := range l.legSet()[l.sLegsIndex] {
-				l.feet[ii].Y = l.stepDownPosition()
-			}
+	case StateStepDown:
+		for _, ii := range l.legSet()[l.sLegsIndex] {
+			f := *l.feet[ii]
+			l.feet[ii].Y = l.swingHeight(l.LowerEasing, stepDownCount, l.footUpAt(f), l.footDownAt(f))
 		}
 
-		if l.stateCounter >= stepDownCount {
+		if l.stateCounter == stepDownCount {
+			l.land(l.hexapod.Now())
+		}
+
+		if l.stateCounter >= stepDownCount+l.stancePause() && l.settled() {
 			l.sLegsIndex += 1
 
 			if l.sLegsIndex >= len(l.legSet()) {
 				l.sLegsIndex = 0
+				l.catchUp = false
+				l.applyGait()
 
 				// If we still need to move, switch back to StepUp.
 				// Otherwise, stand still.
-				if l.needsMove() {
-					l.SetState(sStepUp)
+				if l.wantsStep() && l.canStep() {
+					l.SetState(StateStepUp)
 				} else {
-					l.SetState(sStand)
+					l.SetState(StateStand)
 				}
 
+			} else if l.canStep() {
+				l.SetState(StateStepUp)
+
 			} else {
-				l.SetState(sStepUp)
+				// Too dangerous to lift the next legset, so wait in the
+				// standing state. It'll carry on from the same legset.
+				l.SetState(StateStand)
 			}
 		}
 
@@ -411,15 +941,40 @@ func (l *Legs) Tick(now time.Time) error {
 		return fmt.Errorf("unknown state: %#v", l.State)
 	}
 
-	// Update the position of each foot
+	return nil
+}
+
+// footGoal returns the goal of the given leg, in the hexapod coordinate space.
+func (l *Legs) footGoal(i int) math3d.Vector3 {
+	switch {
+	case l.frozen != nil:
+		return l.frozen.goals[i]
+	case i == l.manipulator:
+		return l.manipulatorGoal
+	default:
+		p := l.feet[i].MultiplyByMatrix44(l.hexapod.Local())
+		if l.supporting(i) {
+			p.Y -= l.heightCorrection
+		}
+		return p
+	}
+}
+
+// updateFeet sets the goal of each initialized leg to the current position of
+// its foot.
+func (l *Legs) updateFeet() {
+	u := l.tarsusDirection()
+	now := l.hexapod.Now()
 	l.Sync(func() {
 		for i, leg := range l.Legs {
-			if leg.Initialized {
-				pp := l.feet[i].MultiplyByMatrix44(l.hexapod.Local())
-				leg.SetGoal(pp)
+			if leg.Initialized && !l.disabled[i] {
+				leg.ankleCompliance = l.ankleCompliance(i, now)
+				pp := l.footGoal(i)
+				err := leg.SetGoalWithUp(pp, u)
+				if err != nil {
+					fmt.Printf("leg %s: %s (%s)\n", leg.Name, err, pp)
+				}
 			}
 		}
 	})
-
-	return nil
 }
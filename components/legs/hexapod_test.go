@@ -1,58 +1,522 @@
 package legs
 
 import (
+	"errors"
+	"github.com/adammck/hexapod"
 	"github.com/adammck/hexapod/math3d"
+	"github.com/adammck/hexapod/utils"
+	"math"
 	"testing"
+	"time"
 )
 
-type Vector3 math3d.Vector3
+func TestLevelFeet(t *testing.T) {
+	h := hexapod.NewHexapod(nil)
+	l := New(h, nil)
+	l.LevelFeet = true
 
-type eg struct {
-	pos Vector3 // position
-	rot float64 // rotation (heading)
-	vec Vector3 // input
-	exp Vector3 // expected result
+	for lean := -15.0; lean <= 15.0; lean += 5.0 {
+		h.Pitch = lean
+		h.Roll = lean / 2
+		u := l.tarsusDirection()
+
+		// Convert the direction back into the world space. It should be vertical.
+		m := h.World()
+		a := u.MultiplyByMatrix44(m)
+		b := math3d.ZeroVector3.MultiplyByMatrix44(m)
+		if w := a.Subtract(b); w.Distance(up) > 0.000001 {
+			t.Errorf("lean=%0.1f: tarsus points %s in world, expected %s", lean, w, up)
+		}
+
+		// And each leg should still solve, with its tarsus in the plane of the
+		// leg as close to vertical as it can get.
+		for i, leg := range l.Legs {
+			leg.Initialized = true
+			pp := l.feet[i].MultiplyByMatrix44(h.Local())
+			_, femur, tibia, tarsus, err := leg.SolveIKWithUp(pp, u)
+			if err != nil {
+				t.Errorf("lean=%0.1f, leg=%s: unexpected error: %s", lean, leg.Name, err)
+				continue
+			}
+
+			if math.IsNaN(femur + tibia + tarsus) {
+				t.Errorf("lean=%0.1f, leg=%s: got NaN", lean, leg.Name)
+			}
+		}
+	}
 }
 
-func TestWorld(t *testing.T) {
-	data := []eg{
-		eg{Vector3{00, 00, 00}, 0.0, Vector3{0, 0, 0}, Vector3{0, 0, 00}},
-		eg{Vector3{00, 00, 10}, 0.0, Vector3{0, 0, 0}, Vector3{0, 0, 10}},
-		eg{Vector3{00, 00, 20}, 0.0, Vector3{0, 0, 0}, Vector3{0, 0, 20}},
-		eg{Vector3{00, 00, 30}, 0.0, Vector3{0, 0, 0}, Vector3{0, 0, 30}},
+func TestHomeFootPosition(t *testing.T) {
+	h := hexapod.NewHexapod(nil)
+	l := New(h, nil)
+
+	for _, rot := range []float64{0, 30, 90, 135, -45, 270, 721} {
+		h.Rotation = rot
+		h.Position = math3d.Vector3{10, 0, -20}
+
+		for _, leg := range l.Legs {
+			r := utils.Rad(rot + leg.Angle)
+			exp := math3d.Vector3{
+				10 + (math.Cos(r) * stepRadius),
+				l.stepDownPosition(),
+				-20 + (-math.Sin(r) * stepRadius),
+			}
+
+			if actual := l.homeFootPosition(leg); actual.Distance(exp) > 0.000001 {
+				t.Errorf("rot=%0.1f, leg=%s: got %s, expected %s", rot, leg.Name, actual, exp)
+			}
+		}
+	}
+}
+
+func TestValidatePose(t *testing.T) {
+	h := hexapod.NewHexapod(nil)
+	l := New(h, nil)
+	h.Add(l)
+
+	type example struct {
+		pos math3d.Vector3
+		rot float64
+		ok  bool
+	}
+
+	data := []example{
+		example{math3d.Vector3{0, 0, 0}, 0, true},
+		example{math3d.Vector3{20, 0, -20}, 0, true},
+		example{math3d.Vector3{0, 0, 0}, 15, true},
+		example{math3d.Vector3{0, 0, 200}, 0, false},
+		example{math3d.Vector3{-250, 0, 0}, 0, false},
 	}
 
 	for i, eg := range data {
-		h := Hexapod{
-			Position: eg.pos,
-			Rotation: eg.rot,
+		err := l.ValidatePose(eg.pos, eg.rot)
+		if (err == nil) != eg.ok {
+			t.Errorf("Example #%d: got %v, expected ok=%v", i+1, err, eg.ok)
 		}
 
-		actual := eg.vec.MultiplyByMatrix44(h.World())
-		if actual.Distance(eg.exp) > 0.000001 {
-			t.Errorf("Example #%d: got %s, expected: %s", i+1, actual, eg.exp)
+		// SetPose should refuse to move to invalid poses.
+		h.Position = math3d.ZeroVector3
+		h.Rotation = 0
+		err = h.SetPose(eg.pos, eg.rot)
+		if eg.ok && (err != nil || h.Position != eg.pos || h.Rotation != eg.rot) {
+			t.Errorf("Example #%d: SetPose failed: %v", i+1, err)
+		}
+
+		if !eg.ok && (err == nil || !h.Position.Zero() || h.Rotation != 0) {
+			t.Errorf("Example #%d: SetPose moved to invalid pose", i+1)
 		}
 	}
 }
 
-func TestLocal(t *testing.T) {
+func TestStepAdvancesState(t *testing.T) {
+	h := hexapod.NewHexapod(nil)
+	l, n, _ := mockLegs(h)
+	h.Add(l)
 
-	data := []eg{
-		eg{Vector3{00, 00, 00}, 0.0, Vector3{10, 20, 30}, Vector3{10, 20, 30}},
-		eg{Vector3{00, 00, 10}, 0.0, Vector3{10, 20, 30}, Vector3{10, 20, 20}},
-		eg{Vector3{00, 00, 20}, 0.0, Vector3{10, 20, 30}, Vector3{10, 20, 10}},
-		eg{Vector3{00, 00, 30}, 0.0, Vector3{10, 20, 30}, Vector3{10, 20, 00}},
+	now := time.Now()
+	if err := h.Step(hexapod.InputState{Time: now}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
 	}
 
-	for i, eg := range data {
-		h := Hexapod{
-			Position: eg.pos,
-			Rotation: eg.rot,
+	if l.State != StateInit {
+		t.Errorf("state is %s, expected %s", l.State, StateInit)
+	}
+
+	// The legs aren't initialized yet, but should be synced anyway.
+	if n.actions != 1 || n.buffered {
+		t.Errorf("expected one ACTION after unbuffering, got %d", n.actions)
+	}
+
+	// Still too soon to initialize the first leg.
+	h.Step(hexapod.InputState{Time: now})
+	if l.State != StateInit || l.initCounter != 0 {
+		t.Errorf("state is %s[%d], expected %s[0]", l.State, l.initCounter, StateInit)
+	}
+}
+
+func TestStanceAndStrideRadius(t *testing.T) {
+	h := hexapod.NewHexapod(nil)
+	l := New(h, nil)
+	leg := l.Legs[2]
+
+	// Both default to the step radius.
+	if a, b := l.homeFootPosition(leg), l.footfallPosition(leg); a.Distance(*b) > 0.000001 {
+		t.Errorf("home %s != footfall %s by default", a, b)
+	}
+
+	// While the body is moving, the feet are placed at the stride radius.
+	h.Position.Z = 10
+	stride := *l.footfallPosition(leg)
+	l.StanceRadius = 250
+	if p := l.footfallPosition(leg); p.Distance(stride) > 0.000001 {
+		t.Errorf("changing stance moved footfall from %s to %s", stride, p)
+	}
+
+	home := *l.homeFootPosition(leg)
+	l.StrideRadius = 180
+	if p := l.homeFootPosition(leg); p.Distance(home) > 0.000001 {
+		t.Errorf("changing stride moved home from %s to %s", home, p)
+	}
+
+	if d := math.Hypot(home.X, home.Z-10); math.Abs(d-250) > 0.000001 {
+		t.Errorf("home is %0.4f from origin, expected 250", d)
+	}
+
+	if p := l.footfallPosition(leg); math.Abs(math.Hypot(p.X, p.Z-10)-180) > 0.000001 {
+		t.Errorf("footfall %s is not 180 from origin", p)
+	}
+
+	// Once it's stopped, they're placed at home.
+	l.footfallPos = h.Position
+	if p := l.footfallPosition(leg); p.Distance(home) > 0.000001 {
+		t.Errorf("footfall %s is not home %s while still", p, home)
+	}
+
+	l.SetStepRadius(200)
+	if l.StanceRadius != 200 || l.StrideRadius != 200 {
+		t.Errorf("SetStepRadius(200) gave stance=%0.1f, stride=%0.1f", l.StanceRadius, l.StrideRadius)
+	}
+}
+
+func TestFeetSettleAtStanceRadius(t *testing.T) {
+	c := &hexapod.FakeClock{T: time.Unix(100, 0)}
+	h := hexapod.NewHexapod(nil)
+	h.Clock = c
+	l := New(h, nil)
+	l.SetState(StateStand)
+	l.StanceRadius = 230
+	l.StrideRadius = 190
+
+	// Walk for a while, then stand still until the feet have caught up.
+	walk(t, l, c, 1, 300)
+	walk(t, l, c, 0, 300)
+
+	if l.State != StateStand || l.needsMove() {
+		t.Fatalf("still stepping after stopping, in state %s", l.State)
+	}
+
+	for i, leg := range l.Legs {
+		home := l.homeFootPosition(leg)
+		if d := l.feet[i].Distance(*home); d > 0.000001 {
+			t.Errorf("leg %s: foot %0.2f mm from home", leg.Name, d)
+		}
+	}
+}
+
+func TestPing(t *testing.T) {
+	l, _, m := mockLegs(hexapod.NewHexapod(nil))
+
+	res, err := l.Ping()
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	if len(res) != 24 {
+		t.Errorf("expected 24 results, got %d", len(res))
+	}
+
+	// Unplug the tibia of FL and the coxa of BR.
+	m[0][2].absent = true
+	m[3][0].absent = true
+
+	res, err = l.Ping()
+	if !errors.Is(err, hexapod.ErrServoMissing) || err.Error() != "servo not responding: 11 (BR coxa), 43 (FL tibia)" {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	for id, ok := range res {
+		if exp := (id != 11 && id != 43); ok != exp {
+			t.Errorf("servo #%d: got %v, expected %v", id, ok, exp)
+		}
+	}
+
+	if err := l.Boot(); err == nil {
+		t.Errorf("expected boot to fail with servos missing")
+	}
+}
+
+func TestAngleRetries(t *testing.T) {
+	h := hexapod.NewHexapod(nil)
+	l, _, m := mockLegs(h)
+	l.Retries = 2
+	if err := l.Boot(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	m[1][2].flaky = 2
+	if _, err := l.Legs[1].FootPosition(); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	m[1][2].flaky = 2
+	if _, err := l.legAngles(1, h.Now(), 0); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	// Once the retries run out, the error is returned.
+	m[1][2].flaky = 3
+	if _, err := l.Legs[1].Angles(); err == nil {
+		t.Errorf("expected error after retries exhausted")
+	}
+}
+
+// runInit runs the init state until it's finished, and returns the number of
+// batches (intervals) which it took.
+func runInit(l *Legs, sag func(int) float64, m [6][4]*mockServo) int {
+	c := &hexapod.FakeClock{}
+	l.hexapod.Clock = c
+	l.SetState(StateInit)
+	for i := 0; i < 100; i++ {
+		c.Advance(time.Duration(initInterval * float64(time.Second)))
+		l.tickState()
+
+		v := sag(l.initCounter)
+		for _, leg := range m {
+			for _, s := range leg {
+				s.voltage = v
+			}
 		}
 
-		actual := eg.vec.MultiplyByMatrix44(h.Local())
-		if actual.Distance(eg.exp) > 0.000001 {
-			t.Errorf("Example #%d: got %s, expected: %s", i+1, actual, eg.exp)
+		if l.State != StateInit {
+			return l.initBatches
 		}
 	}
+
+	return -1
+}
+
+func TestInitSequential(t *testing.T) {
+	l, _, m := mockLegs(hexapod.NewHexapod(nil))
+	if n := runInit(l, func(int) float64 { return 12 }, m); n != 7 {
+		t.Errorf("expected 7 intervals without a brownout voltage, took %d", n)
+	}
+}
+
+// The first goals after initializing should be ramped from wherever the servos
+// were resting, rather than snapping there.
+func TestInitRamp(t *testing.T) {
+	l, _, m := mockLegs(hexapod.NewHexapod(nil))
+	l.InitJointDelta = 5
+	for _, s := range m[0] {
+		s.angle = 40
+	}
+
+	runInit(l, func(int) float64 { return 12 }, m)
+
+	// Each servo moves by at most 5 degrees per goal from where it was read,
+	// until it gets there.
+	leg := l.Legs[0]
+	goal := l.footGoal(0)
+	for tick := 1; tick <= 100; tick++ {
+		prev := [4]float64{}
+		for i, s := range m[0] {
+			prev[i] = s.angle
+		}
+
+		if err := leg.SetGoal(goal); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		for i, s := range m[0] {
+			if d := math.Abs(s.angle - prev[i]); d > 5.000001 {
+				t.Fatalf("tick %d: servo %d jumped %0.2f deg from %0.2f", tick, i, d, prev[i])
+			}
+		}
+
+		if leg.rampDelta == 0 {
+			break
+		}
+	}
+
+	if len(m[0][0].moves) < 2 {
+		t.Errorf("moved coxa %d times, expected a ramp", len(m[0][0].moves))
+	}
+
+	if leg.rampDelta != 0 {
+		t.Errorf("still ramping after 100 ticks")
+	}
+
+	// Once it's caught up, the goals aren't limited any more.
+	m[0][1].moves = nil
+	goal.Y -= 40
+	leg.SetGoal(goal)
+	if n := len(m[0][1].moves); n != 1 {
+		t.Fatalf("moved femur %d times, expected 1", n)
+	}
+
+	c, f, _, _, _ := leg.SolveIK(goal)
+	a := leg.servoAngles(JointAngles{c, f, 0, 0})
+	if math.Abs(m[0][1].angle-a.Femur) > 0.000001 {
+		t.Errorf("femur at %0.2f, expected %0.2f", m[0][1].angle, a.Femur)
+	}
+}
+
+func TestInitStrongSupply(t *testing.T) {
+	l, _, m := mockLegs(hexapod.NewHexapod(nil))
+	l.BrownoutVoltage = 10
+
+	// The voltage doesn't sag at all, so the rest should go at once.
+	if n := runInit(l, func(int) float64 { return 12 }, m); n != 3 {
+		t.Errorf("expected 3 intervals on a strong supply, took %d", n)
+	}
+}
+
+func TestInitWeakSupply(t *testing.T) {
+	l, _, m := mockLegs(hexapod.NewHexapod(nil))
+	l.BrownoutVoltage = 10
+
+	// Each leg drops the voltage by 0.4v, so after the first, only four can go
+	// at once before the voltage reaches the floor.
+	sag := func(n int) float64 { return 12 - (0.4 * float64(n)) }
+	if n := runInit(l, sag, m); n != 4 {
+		t.Errorf("expected 4 intervals on a weak supply, took %d", n)
+	}
+
+	for i, leg := range l.Legs {
+		if !leg.Initialized {
+			t.Errorf("leg %d wasn't initialized", i)
+		}
+	}
+}
+
+func TestFootDown(t *testing.T) {
+	h := hexapod.NewHexapod(nil)
+	l := New(h, nil)
+	l.SetFootDown(-5)
+	l.SetState(StateStepUp)
+
+	set := l.legSet()[0]
+	peak := math.Inf(-1)
+	for l.sLegsIndex == 0 {
+		l.stateCounter += 1
+		l.tickState()
+		peak = math.Max(peak, l.feet[set[0]].Y)
+	}
+
+	if exp := l.FootDown + l.StepHeight; math.Abs(peak-exp) > 0.000001 {
+		t.Errorf("lifted to %0.2f, expected %0.2f", peak, exp)
+	}
+
+	for _, i := range set {
+		if l.feet[i].Y != l.FootDown {
+			t.Errorf("leg %d: lowered to %0.2f, expected %0.2f", i, l.feet[i].Y, l.FootDown)
+		}
+	}
+}
+
+func TestRestart(t *testing.T) {
+	l, _, m := mockLegs(hexapod.NewHexapod(nil))
+	runInit(l, func(int) float64 { return 12 }, m)
+	l.SetState(StateStand)
+
+	l.Restart()
+	if l.State != StateInit {
+		t.Errorf("expected init state, got %s", l.State)
+	}
+
+	for i, leg := range l.Legs {
+		if leg.Initialized {
+			t.Errorf("leg %d is still initialized", i)
+		}
+	}
+
+	// It should initialize every leg again, just like the first time.
+	if n := runInit(l, func(int) float64 { return 12 }, m); n != 7 {
+		t.Errorf("expected 7 intervals to initialize again, took %d", n)
+	}
+}
+
+func TestRequestRestart(t *testing.T) {
+	h := hexapod.NewHexapod(nil)
+	l, _, m := mockLegs(h)
+	runInit(l, func(int) float64 { return 12 }, m)
+	l.SetState(StateStand)
+
+	// Nothing changes until the next tick.
+	l.RequestRestart()
+	if l.State != StateStand || !l.Legs[0].Initialized {
+		t.Errorf("restarted before the next tick")
+	}
+
+	l.Tick(h.Now())
+	if l.State != StateInit || l.Legs[0].Initialized {
+		t.Errorf("expected to restart on the next tick, state is %s", l.State)
+	}
+
+	// Only once.
+	l.SetState(StateStand)
+	l.Tick(h.Now())
+	if l.State == StateInit {
+		t.Errorf("restarted again")
+	}
+}
+
+func TestMarch(t *testing.T) {
+	h := hexapod.NewHexapod(nil)
+	l := New(h, nil)
+	l.SetState(StateStand)
+
+	// Nothing needs to move, so it should stand still.
+	l.stateCounter += 1
+	l.tickState()
+	if l.State != StateStand {
+		t.Fatalf("expected to stand still, got %s", l.State)
+	}
+
+	l.March = true
+	lifted := [6]bool{}
+	cycles := 0
+	for i := 0; i < 1000 && cycles < 2; i++ {
+		l.stateCounter += 1
+		prev := l.sLegsIndex
+		l.tickState()
+
+		for ii, foot := range l.feet {
+			if foot.Y > l.stepDownPosition() {
+				lifted[ii] = true
+			}
+		}
+
+		if prev != 0 && l.sLegsIndex == 0 {
+			cycles += 1
+		}
+	}
+
+	if cycles != 2 {
+		t.Fatalf("expected to keep stepping, but finished %d cycles", cycles)
+	}
+
+	for i, leg := range l.Legs {
+		if !lifted[i] {
+			t.Errorf("leg %d was never lifted", i)
+		}
+
+		if d := l.feet[i].Distance(*l.footfallPosition(leg)); d > 0.000001 {
+			t.Errorf("leg %d: foot is %0.2f mm from home", i, d)
+		}
+	}
+}
+
+func TestSetFootDownWhileStanding(t *testing.T) {
+	h := hexapod.NewHexapod(nil)
+	l := New(h, nil)
+	l.SetState(StateStand)
+	l.feet[0].Y = baseFootUp
+
+	// Lowering the ground shouldn't leave the planted feet in the air.
+	l.SetFootDown(-5)
+
+	for i, foot := range l.feet {
+		if i == 0 {
+			if foot.Y != baseFootUp {
+				t.Errorf("lifted foot moved to %0.2f", foot.Y)
+			}
+		} else if !l.planted(i) || foot.Y != -5 {
+			t.Errorf("leg %d: foot at %0.2f, expected planted at -5", i, foot.Y)
+		}
+	}
+
+	if ok, m := l.IsStable(); !ok {
+		t.Errorf("expected to be stable, margin=%0.2f", m)
+	}
 }
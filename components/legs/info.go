@@ -0,0 +1,36 @@
+package legs
+
+import (
+	"github.com/adammck/hexapod/math3d"
+)
+
+// LegInfo describes a single leg, for tooling and diagnostics.
+type LegInfo struct {
+	Index       int
+	Name        string
+	Origin      math3d.Vector3
+	Angle       float64
+	ServoIDs    [4]uint8
+	Initialized bool
+
+	// The current goal of the foot, in the WORLD coordinate space.
+	Goal math3d.Vector3
+}
+
+// ListLegs returns a description of each leg, in order.
+func (l *Legs) ListLegs() []LegInfo {
+	res := make([]LegInfo, len(l.Legs))
+	for i, leg := range l.Legs {
+		res[i] = LegInfo{
+			Index:       i,
+			Name:        leg.Name,
+			Origin:      *leg.Origin,
+			Angle:       leg.Angle,
+			ServoIDs:    leg.ServoIDs(),
+			Initialized: leg.Initialized,
+			Goal:        *l.feet[i],
+		}
+	}
+
+	return res
+}
@@ -0,0 +1,55 @@
+package legs
+
+import (
+	"github.com/adammck/hexapod"
+	"github.com/adammck/hexapod/math3d"
+	"testing"
+)
+
+func TestListLegs(t *testing.T) {
+	l := New(hexapod.NewHexapod(nil), nil)
+	l.Legs[3].Initialized = true
+
+	exp := []struct {
+		name  string
+		angle float64
+		first uint8
+	}{
+		{"FL", -120, 41},
+		{"FR", -60, 51},
+		{"MR", 1, 61},
+		{"BR", 60, 11},
+		{"BL", 120, 21},
+		{"ML", 180, 31},
+	}
+
+	info := l.ListLegs()
+	if len(info) != len(exp) {
+		t.Fatalf("expected %d legs, got %d", len(exp), len(info))
+	}
+
+	for i, e := range exp {
+		li := info[i]
+		if li.Index != i || li.Name != e.name || li.Angle != e.angle {
+			t.Errorf("leg %d: got %+v", i, li)
+		}
+
+		if li.ServoIDs != [4]uint8{e.first, e.first + 1, e.first + 2, e.first + 3} {
+			t.Errorf("leg %d: got servo IDs %v", i, li.ServoIDs)
+		}
+
+		if li.Initialized != (i == 3) {
+			t.Errorf("leg %d: initialized=%v", i, li.Initialized)
+		}
+
+		if li.Goal.Distance(*l.homeFootPosition(l.Legs[i])) > 0.000001 {
+			t.Errorf("leg %d: goal %s isn't home", i, li.Goal)
+		}
+	}
+
+	// Should be a copy.
+	info[0].Origin = math3d.Vector3{1, 2, 3}
+	if l.Legs[0].Origin.X == 1 {
+		t.Errorf("ListLegs exposed the origin of leg 0")
+	}
+}
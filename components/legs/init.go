@@ -0,0 +1,114 @@
+package legs
+
+import (
+	"fmt"
+	"math"
+)
+
+// initLegs initializes the next n legs in initOrder, by turning their torque on.
+func (l *Legs) initLegs(n int) {
+	for i := 0; i < n && l.initCounter < len(l.Legs); i++ {
+		ii := l.initOrder[l.initCounter]
+		if !l.disabled[ii] {
+			l.initLeg(l.Legs[ii])
+		}
+
+		l.initCounter += 1
+	}
+}
+
+// initLeg turns on the torque of each servo in the given leg. If InitJointDelta
+// is set, the present position of each servo is read first, so the first goals
+// can be ramped from there rather than snapping the leg into place.
+func (l *Legs) initLeg(leg *Leg) {
+	leg.ForgetGoals()
+	if l.InitJointDelta > 0 {
+		err := leg.readGoals()
+		if err != nil {
+			fmt.Printf("leg %s: error reading position: %s\n", leg.Name, err)
+		} else {
+			leg.rampDelta = l.InitJointDelta
+		}
+	}
+
+	for _, servo := range leg.Servos() {
+		servo.SetTorqueEnable(true)
+		servo.SetMovingSpeed(1024)
+	}
+
+	leg.Initialized = true
+}
+
+// initBatchSize returns the number of legs to initialize at once. Without a
+// BrownoutVoltage, it's always one, which is slow but safe. Otherwise, the first
+// leg is initialized alone, to measure how much the voltage sags. After that,
+// as many legs are initialized at once as the voltage can drop by before it
+// reaches the floor. If it's already below the floor, zero is returned, to wait
+// for it to recover.
+func (l *Legs) initBatchSize() int {
+	if l.BrownoutVoltage <= 0 {
+		return 1
+	}
+
+	// Any servo will do, as long as it's attached.
+	leg := l.Legs[l.initOrder[0]]
+	for _, i := range l.initOrder {
+		if !l.disabled[i] {
+			leg = l.Legs[i]
+			break
+		}
+	}
+
+	v, err := leg.Coxa.Voltage()
+	if err != nil {
+		fmt.Printf("error reading voltage: %s\n", err)
+		return 1
+	}
+
+	n := 1
+	if v < l.BrownoutVoltage {
+		fmt.Printf("WARNING: voltage is %.2fv (floor %.2fv); waiting\n", v, l.BrownoutVoltage)
+		n = 0
+
+	} else if l.initBatch > 0 {
+		sag := (l.initVoltage - v) / float64(l.initBatch)
+		if sag <= 0 {
+			n = len(l.Legs)
+		} else {
+			n = int(math.Max(1, math.Floor(((v-l.BrownoutVoltage)/sag)+0.000001)))
+		}
+	}
+
+	// Only remember the voltage before batches which actually did something,
+	// since the sag is measured per leg.
+	if n > 0 {
+		l.initVoltage = v
+		l.initBatch = n
+	}
+
+	return n
+}
+
+// Restart forgets that the legs were ever initialized, and goes back to the init
+// state, as if they'd just booted. This is for when the servos might have been
+// reset (e.g. the serial port was reconnected). They hold their last goal until
+// they're initialized again.
+func (l *Legs) Restart() {
+	for _, leg := range l.Legs {
+		leg.Initialized = false
+		leg.ForgetGoals()
+	}
+
+	l.initCounter = 0
+	l.initBatches = 0
+	l.initBatch = 0
+	l.SetState(StateInit)
+}
+
+// RequestRestart arranges for Restart to be called at the start of the next
+// tick. Unlike Restart, this is safe to call from the middle of a tick (e.g. from
+// inside a servo read which has just reconnected the serial port), since the rest
+// of the tick carries on as if nothing happened.
+func (l *Legs) RequestRestart() {
+	l.restartPending = true
+}
@@ -0,0 +1,57 @@
+package legs
+
+import (
+	"github.com/adammck/hexapod/math3d"
+	"github.com/adammck/hexapod/utils"
+)
+
+// The angle (in degrees) to nudge each joint by when estimating the Jacobian.
+const jacobianDelta = 0.01
+
+// Jacobian is the rate of change (in mm per radian, in the hexapod coordinate
+// space) of the position of a foot, with respect to each of the coxa, femur,
+// and tibia. i.e. each element is a column of the matrix. The tarsus isn't
+// included, since the foot is at its end.
+type Jacobian [3]math3d.Vector3
+
+// Velocity returns the velocity of the foot (in mm per second) when the coxa,
+// femur, and tibia are moving at the given rates (in degrees per second).
+func (j Jacobian) Velocity(rates [3]float64) math3d.Vector3 {
+	v := math3d.Vector3{}
+	for i := range j {
+		v = *v.Add(j[i].Scale(utils.Rad(rates[i])))
+	}
+
+	return v
+}
+
+// Jacobian reads the present angle of each servo, and returns the Jacobian of
+// the leg at those angles.
+func (leg *Leg) Jacobian() (Jacobian, error) {
+	a, err := leg.Angles()
+	if err != nil {
+		return Jacobian{}, err
+	}
+
+	return leg.jacobian(a), nil
+}
+
+// jacobian returns the Jacobian of the leg at the given angles. It's found
+// numerically, by nudging each joint.
+func (leg *Leg) jacobian(a JointAngles) Jacobian {
+	p := leg.ForwardKinematics(a)
+	s := 1 / utils.Rad(jacobianDelta)
+
+	ac := a
+	ac.Coxa += jacobianDelta
+	af := a
+	af.Femur += jacobianDelta
+	at := a
+	at.Tibia += jacobianDelta
+
+	return Jacobian{
+		leg.ForwardKinematics(ac).Subtract(p).Scale(s),
+		leg.ForwardKinematics(af).Subtract(p).Scale(s),
+		leg.ForwardKinematics(at).Subtract(p).Scale(s),
+	}
+}
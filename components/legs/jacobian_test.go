@@ -0,0 +1,35 @@
+package legs
+
+import (
+	"github.com/adammck/hexapod/math3d"
+	"testing"
+)
+
+func TestJacobian(t *testing.T) {
+	leg := &Leg{Origin: &math3d.Vector3{0, 0, 0}, Angle: 30}
+	m := mockLeg(leg)
+
+	a, err := poseLeg(leg, m, math3d.Vector3{150, -90, -60})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	j, err := leg.Jacobian()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// Moving the joints a little for a tenth of a second should move the foot
+	// about as far as the velocity predicts.
+	rates := [3]float64{2, -3, 1}
+	b := a
+	b.Coxa += rates[0] * 0.1
+	b.Femur += rates[1] * 0.1
+	b.Tibia += rates[2] * 0.1
+
+	exp := leg.ForwardKinematics(b).Subtract(leg.ForwardKinematics(a))
+	act := j.Velocity(rates).Scale(0.1)
+	if act.Distance(*exp) > 0.01 {
+		t.Errorf("got displacement %s, expected %s", act, exp)
+	}
+}
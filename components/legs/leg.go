@@ -3,11 +3,77 @@ package legs
 import (
 	"fmt"
 	"github.com/adammck/dynamixel"
+	"github.com/adammck/hexapod"
 	"github.com/adammck/hexapod/math3d"
 	"github.com/adammck/hexapod/utils"
 	"math"
 )
 
+const (
+
+	// The dimensions (in mm) of each segment of the original legs. The coxa also
+	// drops a bit on the Y axis, between the coxa and femur servos. See Geometry.
+	coxaLength   = 39.0
+	coxaDrop     = 12.0
+	femurLength  = 100.0
+	tibiaLength  = 85.0
+	tarsusLength = 64.0
+
+	// The default GoalEpsilon. This is about a third of the resolution of an
+	// AX-12, which is 0.29 degrees.
+	defaultGoalEpsilon = 0.1
+)
+
+var (
+
+	// These are the same as the errors in the hexapod package, for convenience.
+	ErrUnreachable    = hexapod.ErrUnreachable
+	ErrNotInitialized = hexapod.ErrNotInitialized
+
+	// The default direction of the tarsus, from the foot towards the tibia.
+	up = math3d.Vector3{0, 1, 0}
+
+	// The name of each joint, in the same order as Servos.
+	jointNames = [4]string{"coxa", "femur", "tibia", "tarsus"}
+)
+
+// JointAngles holds an angle (in degrees) for each joint of a leg.
+type JointAngles struct {
+	Coxa   float64
+	Femur  float64
+	Tibia  float64
+	Tarsus float64
+}
+
+// JointFlags holds a boolean for each joint of a leg.
+type JointFlags struct {
+	Coxa   bool
+	Femur  bool
+	Tibia  bool
+	Tarsus bool
+}
+
+// apply returns the given angles, with the sign of each flagged joint flipped.
+func (f JointFlags) apply(a JointAngles) JointAngles {
+	if f.Coxa {
+		a.Coxa = -a.Coxa
+	}
+
+	if f.Femur {
+		a.Femur = -a.Femur
+	}
+
+	if f.Tibia {
+		a.Tibia = -a.Tibia
+	}
+
+	if f.Tarsus {
+		a.Tarsus = -a.Tarsus
+	}
+
+	return a
+}
+
 type Leg struct {
 	Origin *math3d.Vector3
 
@@ -15,25 +81,117 @@ type Leg struct {
 	Angle float64
 
 	Name   string
-	Coxa   *dynamixel.DynamixelServo
-	Femur  *dynamixel.DynamixelServo
-	Tibia  *dynamixel.DynamixelServo
-	Tarsus *dynamixel.DynamixelServo
+	BaseID int
+	Coxa   Servo
+	Femur  Servo
+	Tibia  Servo
+	Tarsus Servo
+
+	// The number of times to retry reads of the servo angles which fail, before
+	// giving up and returning an error. Legs sets this from its own Retries.
+	Retries int
 
 	// Has the leg been initialized yet? It can't be moved until it has.
 	Initialized bool
+
+	// The angle (in degrees) which each servo reads when its joint is at zero.
+	// These are added to the solved angles before moving the servos, to make up
+	// for servo horns which weren't attached quite straight. See CalibrateLeg.
+	CalibrationOffsets JointAngles
+
+	// Which servos are mounted the other way around, i.e. turn the opposite way
+	// to the solved angles when moved to a positive angle. The IK assumes the
+	// orientations of the original build, so mirror-image builds usually have
+	// to reverse some joints on one side.
+	Reversed JointFlags
+
+	// The range of each joint. Goals are clamped to this range, to avoid moving
+	// the leg into the body or another leg. No limits are enforced when nil.
+	Limits *JointLimits
+
+	// The range which the coxa may turn through, which is usually much narrower
+	// than Limits, so the feet of adjacent legs can't reach each other during
+	// sharp turns. Targets outside of it are unreachable (rather than clamped),
+	// so the body won't be moved there, and the feet are stepped instead. No
+	// range is enforced when nil.
+	CoxaRange *AngleRange
+
+	// Called (with the name of the joint and the solved angle) when a goal is
+	// set within LimitWarning degrees of a joint limit. This is useful to spot
+	// that the legs are working at the edge of their range.
+	OnNearLimit  func(leg *Leg, joint string, angle float64)
+	LimitWarning float64
+
+	// Called (with the name of the joint and the solved angles before and after)
+	// when the IK solution of a joint changes by more than DiscontinuityThreshold
+	// degrees between goals, while the target moves only a little. This catches
+	// the solution flipping to another branch. Zero disables the check. See
+	// checkContinuity.
+	OnDiscontinuity        func(leg *Leg, joint string, from float64, to float64)
+	DiscontinuityThreshold float64
+
+	// The previous IK solution, its target, and whether they're known. See
+	// checkContinuity.
+	solution       JointAngles
+	solutionTarget math3d.Vector3
+	solutionKnown  bool
+
+	// How to solve the IK. The default (closed form) is only correct for legs
+	// which are exactly like the original ones. See Solver.
+	Solver Solver
+
+	// The shape of the leg. Nil means OriginalGeometry. Legs whose femur doesn't
+	// swing in a vertical plane through the coxa axis can't be solved by the
+	// closed form, so need SolverIterative or SolverFallback.
+	Geometry *Geometry
+
+	// The maximum change (in degrees) in the goal of each servo per SetGoal, i.e.
+	// per tick, so a big jump in the target is spread over several ticks rather
+	// than slamming the servo across at full speed. Zero means no limit. The
+	// first goal after ForgetGoals is never limited, since there's nothing to
+	// limit it relative to.
+	MaxJointDelta float64
+
+	// Like MaxJointDelta, but only until the servos have caught up with their
+	// goals after the leg was initialized. Zero means not ramping. See
+	// Legs.InitJointDelta.
+	rampDelta float64
+
+	// The minimum change (in degrees) in the goal of a servo which is worth
+	// sending. Smaller changes are skipped, to save bus time, since the servo
+	// can't resolve them anyway.
+	GoalEpsilon float64
+
+	// How far (in degrees per N*m of load) each joint gives when pushed, i.e. the
+	// inverse of its stiffness. Zero (the default) holds the joint rigidly. The
+	// load of each compliant joint is read every time a goal is set. See comply.
+	Compliance JointAngles
+
+	// Extra compliance for the tarsus, which is set by Legs while the foot is
+	// landing. See Legs.AnkleCompliance.
+	ankleCompliance float64
+
+	// The last goal sent to each servo, in the same order as Servos, and whether
+	// it's known. If not, the next goal is always sent. See ForgetGoals.
+	goals     [4]float64
+	goalKnown [4]bool
 }
 
 func NewLeg(network *dynamixel.DynamixelNetwork, baseId int, name string, origin *math3d.Vector3, angle float64) *Leg {
 	return &Leg{
-		Origin:      origin,
-		Angle:       angle,
-		Name:        name,
-		Coxa:        dynamixel.NewServo(network, uint8(baseId+1)),
-		Femur:       dynamixel.NewServo(network, uint8(baseId+2)),
-		Tibia:       dynamixel.NewServo(network, uint8(baseId+3)),
-		Tarsus:      dynamixel.NewServo(network, uint8(baseId+4)),
-		Initialized: false,
+		Origin:       origin,
+		Angle:        angle,
+		Name:         name,
+		BaseID:       baseId,
+		Coxa:         dynamixel.NewServo(network, uint8(baseId+1)),
+		Femur:        dynamixel.NewServo(network, uint8(baseId+2)),
+		Tibia:        dynamixel.NewServo(network, uint8(baseId+3)),
+		Tarsus:       dynamixel.NewServo(network, uint8(baseId+4)),
+		Initialized:  false,
+		LimitWarning: defaultLimitWarning,
+		GoalEpsilon:  defaultGoalEpsilon,
+
+		DiscontinuityThreshold: defaultDiscontinuityThreshold,
 	}
 }
 
@@ -44,8 +202,8 @@ func (leg *Leg) Matrix() math3d.Matrix44 {
 }
 
 // Servos returns an array of all servos attached to this leg.
-func (leg *Leg) Servos() [4]*dynamixel.DynamixelServo {
-	return [4]*dynamixel.DynamixelServo{
+func (leg *Leg) Servos() [4]Servo {
+	return [4]Servo{
 		leg.Coxa,
 		leg.Femur,
 		leg.Tibia,
@@ -53,6 +211,17 @@ func (leg *Leg) Servos() [4]*dynamixel.DynamixelServo {
 	}
 }
 
+// ServoIDs returns the IDs of the servos attached to this leg, in the same order
+// as Servos.
+func (leg *Leg) ServoIDs() [4]uint8 {
+	return [4]uint8{
+		uint8(leg.BaseID + 1),
+		uint8(leg.BaseID + 2),
+		uint8(leg.BaseID + 3),
+		uint8(leg.BaseID + 4),
+	}
+}
+
 func (leg *Leg) SetLED(state bool) {
 	for _, s := range leg.Servos() {
 		s.SetLed(state)
@@ -64,7 +233,8 @@ func _sss(a float64, b float64, c float64) float64 {
 	return utils.Deg(math.Acos(((b * b) + (c * c) - (a * a)) / (2 * b * c)))
 }
 
-func (leg *Leg) segments() (*Segment, *Segment, *Segment, *Segment) {
+func (leg *Leg) segments(coxaAngle float64) (*Segment, *Segment, *Segment, *Segment) {
+	g := leg.geometry()
 
 	// The position of the object in space must be specified by two segments. The
 	// first positions it, then the second (which is always zero-length) rotates
@@ -73,27 +243,60 @@ func (leg *Leg) segments() (*Segment, *Segment, *Segment, *Segment) {
 	r2 := MakeSegment("r2", r1, *math3d.MakeSingularEulerAngle(math3d.RotationHeading, leg.Angle), *math3d.MakeVector3(0, 0, 0))
 
 	// Movable segments (angles in deg, vectors in mm)
-	coxa := MakeSegment("coxa", r2, *math3d.MakeSingularEulerAngle(math3d.RotationHeading, 40), *math3d.MakeVector3(39, -12, 0))
-	femur := MakeSegment("femur", coxa, *math3d.MakeSingularEulerAngle(math3d.RotationBank, 90), *math3d.MakeVector3(100, 0, 0))
-	tibia := MakeSegment("tibia", femur, *math3d.MakeSingularEulerAngle(math3d.RotationBank, 0), *math3d.MakeVector3(85, 0, 0))
+	coxa := MakeSegment("coxa", r2, *math3d.MakeSingularEulerAngle(math3d.RotationHeading, coxaAngle), *math3d.MakeVector3(g.CoxaLength, -g.CoxaDrop, g.CoxaOffset))
+	femur := MakeSegment("femur", coxa, *math3d.MakeSingularEulerAngle(math3d.RotationBank, 90), *math3d.MakeVector3(g.FemurLength, 0, 0))
+	tibia := MakeSegment("tibia", femur, *math3d.MakeSingularEulerAngle(math3d.RotationBank, 0), *math3d.MakeVector3(g.TibiaLength, 0, 0))
 	tarsus := MakeSegment("tarsus", tibia, *math3d.MakeSingularEulerAngle(math3d.RotationBank, 90), *math3d.MakeVector3(76.5, 0, 0))
 
 	// Return just the useful segments
 	return coxa, femur, tibia, tarsus
 }
 
-// Sets the goal position of this leg to the given x/y/z coordinates, relative
-// to the center of the hexapod.
-func (leg *Leg) SetGoal(p math3d.Vector3) {
-	_, femur, _, _ := leg.segments()
+// SolveIK returns the angles (in degrees) which each servo should be moved to
+// in order to place the foot at the given x/y/z coordinates, relative to the
+// center of the hexapod. No servos are moved, so this can be called on legs
+// which haven't been initialized (or aren't attached to anything). The tarsus
+// is kept vertical, so the tibia ends at the same height at any reach.
+func (leg *Leg) SolveIK(p math3d.Vector3) (coxa float64, femur float64, tibia float64, tarsus float64, err error) {
+	return leg.SolveIKWithUp(p, up)
+}
 
-	// TODO (adammck): Return an error instead!
-	if !leg.Initialized {
-		panic("leg not initialized")
+// SolveIKWithUp is like SolveIK, but points the tarsus along the given vector
+// (from the foot towards the tibia) rather than straight up. This is useful for
+// keeping the feet flat on the ground while the body is tilted. Only the part
+// of the vector in the plane of the leg is used, since the tarsus can't twist.
+// The angles are found by the leg's Solver. Points which would need the coxa to
+// turn outside of CoxaRange are unreachable.
+func (leg *Leg) SolveIKWithUp(p math3d.Vector3, u math3d.Vector3) (coxa float64, femur float64, tibia float64, tarsus float64, err error) {
+	coxa, femur, tibia, tarsus, err = leg.solve(p, u)
+	if err == nil && !leg.CoxaRange.contains(coxa) {
+		return 0, 0, 0, 0, ErrUnreachable
+	}
+
+	return
+}
+
+// solve is SolveIKWithUp, without checking the range of the coxa.
+func (leg *Leg) solve(p math3d.Vector3, u math3d.Vector3) (coxa float64, femur float64, tibia float64, tarsus float64, err error) {
+	closed := leg.Solver == SolverClosedForm || (leg.Solver == SolverFallback && leg.geometry().planar())
+	if closed {
+		coxa, femur, tibia, tarsus, err = leg.solveClosedForm(p, u)
+		if err == nil || leg.Solver == SolverClosedForm {
+			return
+		}
 	}
 
+	a, err := leg.solveIterative(p, u)
+	return a.Coxa, a.Femur, a.Tibia, a.Tarsus, err
+}
+
+// solveClosedForm is SolveIKWithUp for legs shaped like the original ones,
+// solved with trig. It's fast, and copes with different segment lengths, but is
+// wrong for legs whose femur doesn't swing in a vertical plane through the coxa
+// axis (see Geometry.planar).
+func (leg *Leg) solveClosedForm(p math3d.Vector3, u math3d.Vector3) (coxa float64, femur float64, tibia float64, tarsus float64, err error) {
+	geo := leg.geometry()
 	v := &math3d.Vector3{p.X, p.Y, p.Z}
-	vv := v.Add(math3d.Vector3{0, 64, 0})
 
 	// Solve the angle of the coxa by looking at the position of the target from
 	// above (x,z). It's the only joint which rotates around the Y axis, so we can
@@ -101,20 +304,33 @@ func (leg *Leg) SetGoal(p math3d.Vector3) {
 
 	adj := v.X - leg.Origin.X
 	opp := v.Z - leg.Origin.Z
+	if math.Hypot(adj, opp) < geo.minReach() {
+		return 0, 0, 0, 0, ErrUnreachable
+	}
+
 	theta := utils.Deg(math.Atan2(-opp, adj))
 	coxaAngle := (theta - leg.Angle)
 
+	// Flatten the tarsus direction into the plane of the leg, then find the end
+	// of the tibia by walking up the tarsus from the foot.
+	out := math3d.Vector3{adj, 0, opp}.Unit()
+	uo := (u.X * out.X) + (u.Z * out.Z)
+	uu := math3d.Vector3{out.X * uo, u.Y, out.Z * uo}.Unit()
+	vv := v.Add(uu.Scale(geo.TarsusLength))
+
 	// Solve the other joints with a bunch of trig. Since we've already set the Y
 	// rotation and the other joints only rotate around X (relative to the coxa,
-	// anyway), we can solve them with a shitload of triangles.
+	// anyway), we can solve them with a shitload of triangles. The femur starts
+	// at the end of the coxa, so rotate the segments to match.
 
-	r := femur.Start()
+	_, fs, _, _ := leg.segments(coxaAngle)
+	r := fs.Start()
 	t := r
 	t.Y = -50
 
-	a := 100.0 // femur length
-	b := 85.0  // tibia length
-	c := 64.0  // tarsus length
+	a := geo.FemurLength
+	b := geo.TibiaLength
+	c := geo.TarsusLength
 	d := r.Distance(*vv)
 	e := r.Distance(*v)
 	f := r.Distance(t)
@@ -131,21 +347,267 @@ func (leg *Leg) SetGoal(p math3d.Vector3) {
 	tibiaAngle := 180 - hh
 	tarsusAngle := 180 - (dd + ee)
 
-	// fmt.Printf("v=%v, vv=%v, r=%v, t=%v\n", v, vv, r, t)
-	// fmt.Printf("a=%0.4f, b=%0.4f, c=%0.4f, d=%0.4f, e=%0.4f, f=%0.4f, g=%0.4f\n", a, b, c, d, e, f, g)
-	// fmt.Printf("aa=%0.4f, bb=%0.4f, cc=%0.4f, dd=%0.4f, ee=%0.4f\n", aa, bb, cc, dd, ee)
-	// fmt.Printf("coxaAngle=%0.4f (s/o=%0.4f) (s/v=%0.4f) (e/o=%0.4f) (e/v=%0.4f)\n", coxaAngle, coxa.Start().Distance(ik.ZeroVector3), coxa.Start().Distance(*v), coxa.End().Distance(ik.ZeroVector3), coxa.End().Distance(*v))
-	// fmt.Printf("femurAngle=%0.4f (s/o=%0.4f) (s/v=%0.4f) (e/o=%0.4f) (e/v=%0.4f)\n", femurAngle, femur.Start().Distance(ik.ZeroVector3), femur.Start().Distance(*v), femur.End().Distance(ik.ZeroVector3), femur.End().Distance(*v))
-	// fmt.Printf("tibiaAngle=%0.4f (s/o=%0.4f) (s/v=%0.4f) (e/o=%0.4f) (e/v=%0.4f)\n", tibiaAngle, tibia.Start().Distance(ik.ZeroVector3), tibia.Start().Distance(*v), tibia.End().Distance(ik.ZeroVector3), tibia.End().Distance(*v))
-	// fmt.Printf("tarsusAngle=%0.4f (s/o=%0.4f) (s/v=%0.4f) (e/o=%0.4f) (e/v=%0.4f)\n", tarsusAngle, tarsus.Start().Distance(ik.ZeroVector3), tarsus.Start().Distance(*v), tarsus.End().Distance(ik.ZeroVector3), tarsus.End().Distance(*v))
-
 	if math.IsNaN(coxaAngle) || math.IsNaN(femurAngle) || math.IsNaN(tibiaAngle) || math.IsNaN(tarsusAngle) {
-		fmt.Println("ERROR")
-		return
+		return 0, 0, 0, 0, ErrUnreachable
+	}
+
+	return coxaAngle, (0 - femurAngle), tibiaAngle, tarsusAngle, nil
+}
+
+// ForwardKinematics returns the position of the foot (relative to the center
+// of the hexapod) when the servos are at the given angles. This is the inverse
+// of SolveIK, so the angles should be in the same terms (i.e. without any
+// calibration offsets). The shape of the leg is taken from its Geometry.
+func (leg *Leg) ForwardKinematics(a JointAngles) math3d.Vector3 {
+	g := leg.geometry()
+
+	// The elevation (in degrees above horizontal, before the twist) of each
+	// segment. Every joint but the coxa rotates in the same plane, so they
+	// accumulate.
+	ef := 0 - a.Femur
+	et := ef - a.Tibia
+	es := et - a.Tarsus
+
+	// Walk along the leg in that plane, from the femur servo.
+	x := (g.FemurLength * math.Cos(utils.Rad(ef))) + (g.TibiaLength * math.Cos(utils.Rad(et))) + (g.TarsusLength * math.Cos(utils.Rad(es)))
+	y := (g.FemurLength * math.Sin(utils.Rad(ef))) + (g.TibiaLength * math.Sin(utils.Rad(et))) + (g.TarsusLength * math.Sin(utils.Rad(es)))
+
+	// Twist the plane around the coxa, move it to the end of the coxa, then
+	// rotate the whole leg into place.
+	tw := utils.Rad(g.Twist)
+	v := math3d.Vector3{
+		g.CoxaLength + x,
+		-g.CoxaDrop + (y * math.Cos(tw)),
+		g.CoxaOffset + (y * math.Sin(tw)),
 	}
 
-	leg.Coxa.MoveTo(coxaAngle)
-	leg.Femur.MoveTo(0 - femurAngle)
-	leg.Tibia.MoveTo(tibiaAngle)
-	leg.Tarsus.MoveTo(tarsusAngle)
+	return *leg.Origin.Add(v.RotateY(leg.Angle + a.Coxa))
+}
+
+// FootPosition returns the actual position of the foot (relative to the center
+// of the hexapod), by reading the present angle of each servo. This hits the
+// network four times, so don't call it too often.
+func (leg *Leg) FootPosition() (math3d.Vector3, error) {
+	a, err := leg.Angles()
+	if err != nil {
+		return math3d.ZeroVector3, err
+	}
+
+	return leg.ForwardKinematics(a), nil
+}
+
+// Angles reads the present angle of each servo, and returns them in the terms
+// of the IK, i.e. without the calibration offsets or reversal. This hits the
+// network four times, like FootPosition.
+func (leg *Leg) Angles() (JointAngles, error) {
+	r := [4]float64{}
+	for i, servo := range leg.Servos() {
+		a, err := leg.readAngle(servo)
+		if err != nil {
+			return JointAngles{}, err
+		}
+
+		r[i] = a
+	}
+
+	return leg.jointAngles(JointAngles{r[0], r[1], r[2], r[3]}), nil
+}
+
+// readAngle reads the present angle of the given servo, retrying (see Retries)
+// if the read fails.
+func (leg *Leg) readAngle(servo Servo) (float64, error) {
+	var a float64
+	err := utils.Retry(leg.Retries, func() error {
+		var err error
+		a, err = servo.Angle()
+		return err
+	})
+
+	return a, err
+}
+
+// servoAngles converts the given joint angles (as returned by SolveIK) into the
+// angles which the servos should be moved to, by applying the direction and the
+// calibration offset of each joint.
+func (leg *Leg) servoAngles(a JointAngles) JointAngles {
+	a = leg.Reversed.apply(a)
+	off := leg.CalibrationOffsets
+	return JointAngles{
+		Coxa:   a.Coxa + off.Coxa,
+		Femur:  a.Femur + off.Femur,
+		Tibia:  a.Tibia + off.Tibia,
+		Tarsus: a.Tarsus + off.Tarsus,
+	}
+}
+
+// jointAngles is the inverse of servoAngles.
+func (leg *Leg) jointAngles(s JointAngles) JointAngles {
+	off := leg.CalibrationOffsets
+	return leg.Reversed.apply(JointAngles{
+		Coxa:   s.Coxa - off.Coxa,
+		Femur:  s.Femur - off.Femur,
+		Tibia:  s.Tibia - off.Tibia,
+		Tarsus: s.Tarsus - off.Tarsus,
+	})
+}
+
+// JointVelocities returns the average rate (in degrees per second) at which each
+// joint must turn to move the foot from one position to another (relative to the
+// center of the hexapod) in dt seconds. The joints are ordered as in Servos.
+// This is useful for checking whether a step can be completed in time, given
+// the maximum speed of the servos. The foot doesn't necessarily move in a
+// straight line between the two, so this isn't the peak rate.
+func (leg *Leg) JointVelocities(from math3d.Vector3, to math3d.Vector3, dt float64) ([4]float64, error) {
+	if dt <= 0 {
+		return [4]float64{}, fmt.Errorf("invalid duration: %f", dt)
+	}
+
+	c1, f1, t1, s1, err := leg.SolveIK(from)
+	if err != nil {
+		return [4]float64{}, err
+	}
+
+	c2, f2, t2, s2, err := leg.SolveIK(to)
+	if err != nil {
+		return [4]float64{}, err
+	}
+
+	return [4]float64{
+		math.Abs(utils.NormalizeDeg(c2-c1)) / dt,
+		math.Abs(f2-f1) / dt,
+		math.Abs(t2-t1) / dt,
+		math.Abs(s2-s1) / dt,
+	}, nil
+}
+
+// CanReach returns true if the foot of this leg can be placed at the given
+// x/y/z coordinates, relative to the center of the hexapod.
+func (leg *Leg) CanReach(p math3d.Vector3) bool {
+	_, _, _, _, err := leg.SolveIK(p)
+	return err == nil
+}
+
+// Sets the goal position of this leg to the given x/y/z coordinates, relative
+// to the center of the hexapod. Returns ErrUnreachable (and doesn't move) if
+// the foot can't be placed there.
+func (leg *Leg) SetGoal(p math3d.Vector3) error {
+	return leg.SetGoalWithUp(p, up)
+}
+
+// SetGoalWithOrientation is like SetGoal, but holds the tarsus at the given pitch
+// (in degrees from vertical, in the plane of the leg) rather than vertical. A
+// positive pitch leans the top of the tarsus away from the body, so the foot
+// points back towards it. Returns ErrUnreachable (and doesn't move) if the foot
+// can't be placed there at that angle.
+func (leg *Leg) SetGoalWithOrientation(p math3d.Vector3, pitch float64) error {
+	if math.Abs(pitch) >= 90 {
+		return fmt.Errorf("invalid pitch: %0.2f", pitch)
+	}
+
+	r := utils.Rad(pitch)
+	out := math3d.Vector3{p.X - leg.Origin.X, 0, p.Z - leg.Origin.Z}.Unit()
+	u := out.Scale(math.Sin(r)).Add(up.Scale(math.Cos(r)))
+	return leg.SetGoalWithUp(p, *u)
+}
+
+// nearLimit calls OnNearLimit, if it's set.
+func (leg *Leg) nearLimit(joint string, angle float64) {
+	if leg.OnNearLimit != nil {
+		leg.OnNearLimit(leg, joint, angle)
+	}
+}
+
+// SetGoalWithUp is like SetGoal, but points the tarsus along the given vector.
+// See SolveIKWithUp. If any servo can't be moved, the rest still are, and the
+// first error is returned. The goal of that servo is forgotten, so it's sent
+// again next time even if it hasn't changed.
+func (leg *Leg) SetGoalWithUp(p math3d.Vector3, u math3d.Vector3) error {
+
+	if !leg.Initialized {
+		return ErrNotInitialized
+	}
+
+	coxa, femur, tibia, tarsus, err := leg.SolveIKWithUp(p, u)
+	if err != nil {
+		return err
+	}
+
+	leg.checkContinuity(p, JointAngles{coxa, femur, tibia, tarsus})
+	a := leg.comply(JointAngles{coxa, femur, tibia, tarsus})
+	if leg.Limits != nil {
+		a = leg.Limits.clamp(a, leg.LimitWarning, leg.nearLimit)
+	}
+
+	max := leg.MaxJointDelta
+	if leg.rampDelta > 0 && (max == 0 || leg.rampDelta < max) {
+		max = leg.rampDelta
+	}
+
+	a = leg.servoAngles(a)
+	servos := leg.Servos()
+	limited := false
+	var moveErr error
+	for i, angle := range [4]float64{a.Coxa, a.Femur, a.Tibia, a.Tarsus} {
+
+		// Skip goals which are close enough to the last one sent before they're
+		// limited, since a limit smaller than GoalEpsilon would skip every step.
+		if leg.goalKnown[i] && math.Abs(angle-leg.goals[i]) <= leg.GoalEpsilon {
+			continue
+		}
+
+		if leg.goalKnown[i] && max > 0 {
+			d := math.Max(-max, math.Min(max, angle-leg.goals[i]))
+			if d != angle-leg.goals[i] {
+				limited = true
+			}
+
+			angle = leg.goals[i] + d
+		}
+
+		if err := servos[i].MoveTo(angle); err != nil {
+			leg.goalKnown[i] = false
+			if moveErr == nil {
+				moveErr = err
+			}
+
+			continue
+		}
+
+		leg.goals[i] = angle
+		leg.goalKnown[i] = true
+	}
+
+	// Once every servo has caught up, stop ramping.
+	if !limited {
+		leg.rampDelta = 0
+	}
+
+	return moveErr
+}
+
+// readGoals reads the present angle of each servo into the cache of the goals
+// last sent to them, as if the leg had been moved there by SetGoal. If any read
+// fails, the goals are forgotten.
+func (leg *Leg) readGoals() error {
+	for i, servo := range leg.Servos() {
+		a, err := leg.readAngle(servo)
+		if err != nil {
+			leg.ForgetGoals()
+			return err
+		}
+
+		leg.goals[i] = a
+		leg.goalKnown[i] = true
+	}
+
+	return nil
+}
+
+// ForgetGoals clears the cache of the goals last sent to each servo, so the
+// next goal is sent regardless. This must be called after moving the servos by
+// any means other than SetGoal, or after they've been relaxed.
+func (leg *Leg) ForgetGoals() {
+	leg.goalKnown = [4]bool{}
+	leg.solutionKnown = false
+	leg.rampDelta = 0
 }
@@ -1,24 +1,28 @@
 package legs
 
 import (
+	"errors"
+	"github.com/adammck/hexapod"
 	"github.com/adammck/hexapod/math3d"
+	"github.com/adammck/hexapod/utils"
+	"math"
 	"testing"
 )
 
 func TestLegMatrix(t *testing.T) {
 
 	type example struct {
-		legOrigin  Vector3
+		legOrigin  math3d.Vector3
 		legHeading float64
-		vec        Vector3
-		exp        Vector3
+		vec        math3d.Vector3
+		exp        math3d.Vector3
 	}
 
 	// TODO (adammck): Moar
 	data := []example{
-		example{ZeroVector3, 0, Vector3{1, 1, 1}, Vector3{1, 1, 1}},
-		example{ZeroVector3, 180.0, Vector3{10, 20, 30}, Vector3{-10, 20, -30}},
-		example{Vector3{10, 20, 30}, 90.0, Vector3{1, 2, 3}, Vector3{13, 22, 29}},
+		example{math3d.ZeroVector3, 0, math3d.Vector3{1, 1, 1}, math3d.Vector3{1, 1, 1}},
+		example{math3d.ZeroVector3, 180.0, math3d.Vector3{10, 20, 30}, math3d.Vector3{-10, 20, -30}},
+		example{math3d.Vector3{10, 20, 30}, 90.0, math3d.Vector3{1, 2, 3}, math3d.Vector3{13, 22, 29}},
 	}
 
 	for i, eg := range data {
@@ -36,3 +40,463 @@ func TestLegMatrix(t *testing.T) {
 		}
 	}
 }
+
+func TestSolveIK(t *testing.T) {
+	leg := Leg{
+		Origin: &math3d.Vector3{0, 0, 0},
+		Angle:  0,
+		Name:   "whatever",
+	}
+
+	type example struct {
+		target math3d.Vector3
+		coxa   float64
+	}
+
+	// The femur starts at the end of the coxa, wherever the coxa is turned to.
+	// Solving from a fixed coxa angle (it used to be 40 degrees) only places the
+	// foot correctly at that angle, so these are spread well away from it.
+	data := []example{
+		example{math3d.Vector3{200, -80, 0}, 0},
+		example{math3d.Vector3{150, -80, -150}, 45},
+		example{math3d.Vector3{150, -80, 150}, -45},
+		example{math3d.Vector3{100, -80, -100 * math.Sqrt(3)}, 60},
+		example{math3d.Vector3{220, -40, 0}, 0},
+	}
+
+	for i, eg := range data {
+		coxa, femur, tibia, tarsus, err := leg.SolveIK(eg.target)
+		if err != nil {
+			t.Errorf("Example #%d: unexpected error: %s", i+1, err)
+			continue
+		}
+
+		if math.Abs(coxa-eg.coxa) > 0.000001 {
+			t.Errorf("Example #%d: coxa is %0.4f, expected %0.4f", i+1, coxa, eg.coxa)
+		}
+
+		// Walk back down the leg (in the plane of the coxa) to check that the
+		// angles actually place the foot at the target.
+		p := planarFoot(leg, coxa, femur, tibia, tarsus)
+		if p.Distance(eg.target) > 0.0001 {
+			t.Errorf("Example #%d: foot would be at %s, expected %s", i+1, p, eg.target)
+		}
+
+		// The tarsus should always be vertical.
+		if d := 0 - femur - tibia - tarsus; math.Abs(d+90) > 0.0001 {
+			t.Errorf("Example #%d: tarsus is at %0.4f deg, expected -90", i+1, d)
+		}
+	}
+}
+
+// The tarsus is held vertical rather than solved as part of the chain, so the
+// end of the tibia (and so the body) stays at the same height wherever the foot
+// is placed.
+func TestSolveIKConstantHeight(t *testing.T) {
+	leg := Leg{
+		Origin: &math3d.Vector3{0, 0, 0},
+		Angle:  0,
+	}
+
+	for x := 80.0; x <= 220; x += 20 {
+		coxa, femur, tibia, tarsus, err := leg.SolveIK(math3d.Vector3{x, -80, 0})
+		if err != nil {
+			t.Errorf("x=%0.0f: unexpected error: %s", x, err)
+			continue
+		}
+
+		// Walk back up the tarsus from the foot.
+		p := planarFoot(leg, coxa, femur, tibia, tarsus)
+		es := utils.Rad(0 - femur - tibia - tarsus)
+		y := p.Y - (tarsusLength * math.Sin(es))
+		if math.Abs(y-(-80+tarsusLength)) > 0.0001 {
+			t.Errorf("x=%0.0f: tibia ends at y=%0.4f, expected %0.4f", x, y, -80+tarsusLength)
+		}
+	}
+}
+
+func TestSolveIKUnreachable(t *testing.T) {
+	leg := Leg{
+		Origin: &math3d.Vector3{0, 0, 0},
+		Angle:  0,
+		Name:   "whatever",
+	}
+
+	data := []math3d.Vector3{
+		math3d.Vector3{1000, 0, 0},
+		math3d.Vector3{0, 0, 0},
+		math3d.Vector3{0, -80, 0},
+		math3d.Vector3{10, -80, 10},
+	}
+
+	for i, target := range data {
+		_, _, _, _, err := leg.SolveIK(target)
+		if err != ErrUnreachable {
+			t.Errorf("Example #%d: got %v, expected ErrUnreachable", i+1, err)
+		}
+	}
+}
+
+func TestSetGoalUnreachable(t *testing.T) {
+	leg := Leg{
+		Origin:      &math3d.Vector3{0, 0, 0},
+		Angle:       0,
+		Name:        "whatever",
+		Initialized: true,
+	}
+
+	// The servos are nil, so this would crash if it tried to move them.
+	err := leg.SetGoal(math3d.ZeroVector3)
+	if err != ErrUnreachable {
+		t.Errorf("got %v, expected ErrUnreachable", err)
+	}
+}
+
+// planarFoot returns the position of the foot of the given leg when its servos
+// are at the given angles, by walking along each segment in the vertical plane
+// of the coxa. The servo angles are as returned by SolveIK.
+func planarFoot(leg Leg, coxa, femur, tibia, tarsus float64) math3d.Vector3 {
+	heading := utils.Rad(leg.Angle + coxa)
+	out := math3d.Vector3{math.Cos(heading), 0, -math.Sin(heading)}
+
+	// Elevation (above horizontal) of each segment.
+	ef := utils.Rad(0 - femur)
+	et := ef - utils.Rad(tibia)
+	es := et - utils.Rad(tarsus)
+
+	h := 39.0 + (100 * math.Cos(ef)) + (85 * math.Cos(et)) + (64 * math.Cos(es))
+	y := -12.0 + (100 * math.Sin(ef)) + (85 * math.Sin(et)) + (64 * math.Sin(es))
+
+	return math3d.Vector3{
+		leg.Origin.X + (out.X * h),
+		leg.Origin.Y + y,
+		leg.Origin.Z + (out.Z * h),
+	}
+}
+
+func TestSolveIKWithUp(t *testing.T) {
+	leg := Leg{
+		Origin: &math3d.Vector3{0, 0, 0},
+		Angle:  0,
+		Name:   "whatever",
+	}
+
+	target := math3d.Vector3{200, -80, 0}
+
+	for lean := -20.0; lean <= 20.0; lean += 5.0 {
+		r := utils.Rad(lean)
+
+		// Tilt the tarsus around the Z axis (in the plane of the leg), and add
+		// some sideways component, which should be ignored.
+		u := math3d.Vector3{math.Sin(r), math.Cos(r), 0.3}
+
+		coxa, femur, tibia, tarsus, err := leg.SolveIKWithUp(target, u)
+		if err != nil {
+			t.Errorf("lean=%0.1f: unexpected error: %s", lean, err)
+			continue
+		}
+
+		p := planarFoot(leg, coxa, femur, tibia, tarsus)
+		if p.Distance(target) > 0.0001 {
+			t.Errorf("lean=%0.1f: foot would be at %s, expected %s", lean, p, target)
+		}
+
+		if d := 0 - femur - tibia - tarsus; math.Abs(d-(-90-lean)) > 0.0001 {
+			t.Errorf("lean=%0.1f: tarsus is at %0.4f deg, expected %0.4f", lean, d, -90-lean)
+		}
+	}
+}
+
+func TestForwardKinematics(t *testing.T) {
+	data := []Leg{
+		Leg{Origin: &math3d.Vector3{0, 0, 0}, Angle: 0},
+		Leg{Origin: &math3d.Vector3{61.167, 24, 98}, Angle: -120},
+		Leg{Origin: &math3d.Vector3{-66, 24, 0}, Angle: 180},
+	}
+
+	for i, leg := range data {
+		a := JointAngles{10, -20, 70, 40}
+		p := leg.ForwardKinematics(a)
+		if exp := planarFoot(leg, a.Coxa, a.Femur, a.Tibia, a.Tarsus); p.Distance(exp) > 0.000001 {
+			t.Errorf("Example #%d: got %s, expected %s", i+1, p, exp)
+		}
+
+		// Should round-trip through the IK.
+		target := *leg.Origin.Add(math3d.Vector3{180, -100, 30}.RotateY(leg.Angle))
+		coxa, femur, tibia, tarsus, err := leg.SolveIK(target)
+		if err != nil {
+			t.Errorf("Example #%d: unexpected error: %s", i+1, err)
+			continue
+		}
+
+		if p := leg.ForwardKinematics(JointAngles{coxa, femur, tibia, tarsus}); p.Distance(target) > 0.0001 {
+			t.Errorf("Example #%d: round trip gave %s, expected %s", i+1, p, target)
+		}
+	}
+}
+
+func TestAngles(t *testing.T) {
+	leg := &Leg{
+		Origin:             &math3d.Vector3{0, 0, 0},
+		Reversed:           JointFlags{Coxa: true},
+		CalibrationOffsets: JointAngles{1, 2, 3, 4},
+	}
+
+	m := mockLeg(leg)
+	exp := JointAngles{10, -20, 70, 40}
+	s := leg.servoAngles(exp)
+	for i, a := range [4]float64{s.Coxa, s.Femur, s.Tibia, s.Tarsus} {
+		m[i].angle = a
+	}
+
+	a, err := leg.Angles()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if math.Abs(a.Coxa-exp.Coxa)+math.Abs(a.Femur-exp.Femur)+math.Abs(a.Tibia-exp.Tibia)+math.Abs(a.Tarsus-exp.Tarsus) > 0.0001 {
+		t.Errorf("got %+v, expected %+v", a, exp)
+	}
+
+	m[2].absent = true
+	if _, err := leg.Angles(); err == nil {
+		t.Errorf("expected error with a missing servo")
+	}
+}
+
+func TestJointVelocities(t *testing.T) {
+	leg := &Leg{Origin: &math3d.Vector3{0, 0, 0}, Angle: 0}
+	from := math3d.Vector3{200, -80, -20}
+	to := math3d.Vector3{200, -80, 20}
+
+	c1, f1, t1, s1, _ := leg.SolveIK(from)
+	c2, f2, t2, s2, _ := leg.SolveIK(to)
+
+	v, err := leg.JointVelocities(from, to, 0.5)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	exp := [4]float64{
+		math.Abs(c2-c1) * 2,
+		math.Abs(f2-f1) * 2,
+		math.Abs(t2-t1) * 2,
+		math.Abs(s2-s1) * 2,
+	}
+
+	for i := range exp {
+		if math.Abs(v[i]-exp[i]) > 0.000001 {
+			t.Errorf("joint %d: got %0.4f deg/s, expected %0.4f", i, v[i], exp[i])
+		}
+	}
+
+	// Moving the other way should take the same speed.
+	if vv, _ := leg.JointVelocities(to, from, 0.5); vv != v {
+		t.Errorf("reverse: got %v, expected %v", vv, v)
+	}
+
+	if _, err := leg.JointVelocities(from, to, 0); err == nil {
+		t.Errorf("expected error for zero duration")
+	}
+
+	if _, err := leg.JointVelocities(from, math3d.Vector3{1000, 0, 0}, 1); err != ErrUnreachable {
+		t.Errorf("expected ErrUnreachable, got %v", err)
+	}
+}
+
+func TestGoalCaching(t *testing.T) {
+	leg := &Leg{
+		Origin:      &math3d.Vector3{0, 0, 0},
+		Initialized: true,
+		GoalEpsilon: 0.1,
+	}
+
+	m := mockLeg(leg)
+	target := math3d.Vector3{200, -80, 30}
+
+	// The first goal is always sent.
+	leg.SetGoal(target)
+	for i, s := range m {
+		if len(s.moves) != 1 {
+			t.Errorf("servo %d: expected one move, got %d", i, len(s.moves))
+		}
+	}
+
+	// An unchanged (or barely changed) target isn't sent again.
+	leg.SetGoal(target)
+	leg.SetGoal(math3d.Vector3{200, -80, 30.001})
+	for i, s := range m {
+		if len(s.moves) != 1 {
+			t.Errorf("servo %d: expected no more moves, got %d", i, len(s.moves)-1)
+		}
+	}
+
+	// Moving along the Y axis doesn't change the coxa, so only the others should
+	// be sent.
+	leg.SetGoal(math3d.Vector3{200, -50, 30})
+	for i, exp := range []int{1, 2, 2, 2} {
+		if n := len(m[i].moves); n != exp {
+			t.Errorf("servo %d: expected %d moves, got %d", i, exp, n)
+		}
+	}
+
+	// Once forgotten, the goal should be sent again.
+	leg.ForgetGoals()
+	leg.SetGoal(math3d.Vector3{200, -50, 30})
+	for i, exp := range []int{2, 3, 3, 3} {
+		if n := len(m[i].moves); n != exp {
+			t.Errorf("servo %d: expected %d moves after forgetting, got %d", i, exp, n)
+		}
+	}
+}
+
+func TestGoalCachingAfterError(t *testing.T) {
+	leg := &Leg{
+		Origin:      &math3d.Vector3{0, 0, 0},
+		Initialized: true,
+		GoalEpsilon: 0.1,
+	}
+
+	m := mockLeg(leg)
+	target := math3d.Vector3{200, -80, 30}
+
+	// The femur drops off the bus for one write. The others are still sent.
+	m[1].absent = true
+	if err := leg.SetGoal(target); err == nil {
+		t.Errorf("expected error")
+	}
+
+	m[1].absent = false
+	for i, s := range m {
+		if len(s.moves) != 1 {
+			t.Errorf("servo %d: expected one move, got %d", i, len(s.moves))
+		}
+	}
+
+	// The same target again resends the goal which failed, but not the rest.
+	if err := leg.SetGoal(target); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	for i, exp := range []int{1, 2, 1, 1} {
+		if n := len(m[i].moves); n != exp {
+			t.Errorf("servo %d: expected %d moves, got %d", i, exp, n)
+		}
+	}
+}
+
+func TestMaxJointDelta(t *testing.T) {
+	leg := &Leg{
+		Origin:        &math3d.Vector3{0, 0, 0},
+		Initialized:   true,
+		MaxJointDelta: 5,
+	}
+
+	m := mockLeg(leg)
+	leg.SetGoal(math3d.Vector3{200, -80, 0})
+
+	// Swing the coxa 30 degrees. It should take six ticks to get there.
+	target := math3d.Vector3{200, -80, 0}.RotateY(-30)
+	for i := 1; i <= 6; i++ {
+		leg.SetGoal(target)
+		if a := m[0].angle; math.Abs(a+float64(i*5)) > 0.0001 {
+			t.Errorf("tick %d: coxa at %0.2f, expected %d", i, a, -i*5)
+		}
+	}
+
+	leg.SetGoal(target)
+	if n := len(m[0].moves); n != 7 {
+		t.Errorf("expected coxa to stop once there, but moved %d times", n)
+	}
+
+	// Without a limit, it's all at once.
+	leg.MaxJointDelta = 0
+	leg.SetGoal(math3d.Vector3{200, -80, 0})
+	if a := m[0].angle; math.Abs(a) > 0.0001 {
+		t.Errorf("coxa at %0.2f, expected 0", a)
+	}
+}
+
+// A limit smaller than GoalEpsilon should still get there, a little at a time,
+// and stop ramping once it has.
+func TestMaxJointDeltaBelowEpsilon(t *testing.T) {
+	leg := &Leg{
+		Origin:        &math3d.Vector3{0, 0, 0},
+		Initialized:   true,
+		GoalEpsilon:   0.1,
+		MaxJointDelta: 0.05,
+	}
+
+	m := mockLeg(leg)
+	leg.SetGoal(math3d.Vector3{200, -80, 0})
+
+	target := math3d.Vector3{200, -80, 0}.RotateY(-1)
+	leg.rampDelta = 0.05
+	for i := 0; i < 30; i++ {
+		leg.SetGoal(target)
+	}
+
+	if a := m[0].angle; math.Abs(a+1) > leg.GoalEpsilon {
+		t.Errorf("coxa at %0.2f, expected -1", a)
+	}
+
+	if leg.rampDelta != 0 {
+		t.Errorf("still ramping at %0.2f", leg.rampDelta)
+	}
+}
+
+func TestSetGoalNotInitialized(t *testing.T) {
+	leg := &Leg{Origin: &math3d.Vector3{0, 0, 0}}
+	m := mockLeg(leg)
+
+	err := leg.SetGoal(math3d.Vector3{200, -80, 0})
+	if !errors.Is(err, hexapod.ErrNotInitialized) {
+		t.Errorf("expected ErrNotInitialized, got %v", err)
+	}
+
+	if len(m[0].moves) != 0 {
+		t.Errorf("uninitialized leg was moved")
+	}
+}
+
+func TestSetGoalWithOrientation(t *testing.T) {
+	leg := &Leg{Origin: &math3d.Vector3{0, 0, 0}, Angle: 30}
+	m := mockLeg(leg)
+	leg.Initialized = true
+
+	data := []struct {
+		p     math3d.Vector3
+		pitch float64
+	}{
+		{math3d.Vector3{150, -80, -90}, 0},
+		{math3d.Vector3{150, -80, -90}, 20},
+		{math3d.Vector3{120, -60, -60}, -30},
+		{math3d.Vector3{170, -60, -90}, 10},
+	}
+
+	for i, d := range data {
+		err := leg.SetGoalWithOrientation(d.p, d.pitch)
+		if err != nil {
+			t.Errorf("example %d: unexpected error: %s", i+1, err)
+			continue
+		}
+
+		a := JointAngles{m[0].angle, m[1].angle, m[2].angle, m[3].angle}
+		if p := leg.ForwardKinematics(a); p.Distance(d.p) > 0.0001 {
+			t.Errorf("example %d: foot is at %s, expected %s", i+1, p, d.p)
+		}
+
+		if e := 0 - a.Femur - a.Tibia - a.Tarsus; math.Abs(e-(-90-d.pitch)) > 0.0001 {
+			t.Errorf("example %d: tarsus is at %0.4f deg, expected %0.4f", i+1, e, -90-d.pitch)
+		}
+	}
+
+	// At full stretch, the tarsus can't be leaned that far out.
+	if err := leg.SetGoalWithOrientation(math3d.Vector3{260, -60, -150}, 60); err != ErrUnreachable {
+		t.Errorf("expected ErrUnreachable, got %v", err)
+	}
+
+	if err := leg.SetGoalWithOrientation(math3d.Vector3{150, -80, -90}, 90); err == nil {
+		t.Errorf("expected error for horizontal tarsus")
+	}
+}
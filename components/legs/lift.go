@@ -0,0 +1,29 @@
+package legs
+
+import (
+	"time"
+)
+
+// liftCounter returns the number of ticks that the kth leg of the set which is
+// being stepped has been lifting for, or zero if it hasn't started yet. Each leg
+// starts LiftStagger after the one before it. Without a stagger, this is the
+// same as the state counter.
+func (l *Legs) liftCounter(k int) int {
+	if l.liftStart[k] < 0 {
+		if l.StateDuration() < time.Duration(k)*l.LiftStagger {
+			return 0
+		}
+
+		l.liftStart[k] = l.stateCounter - 1
+	}
+
+	return l.stateCounter - l.liftStart[k]
+}
+
+// resetLift forgets when each leg began to lift. This must be called before the
+// first tick of each step.
+func (l *Legs) resetLift() {
+	for i := range l.liftStart {
+		l.liftStart[i] = -1
+	}
+}
@@ -0,0 +1,52 @@
+package legs
+
+import (
+	"github.com/adammck/hexapod"
+	"testing"
+	"time"
+)
+
+func TestLiftStagger(t *testing.T) {
+	c := &hexapod.FakeClock{T: time.Unix(100, 0)}
+	h := hexapod.NewHexapod(nil)
+	h.Clock = c
+	l := New(h, nil)
+	l.SetGait(TripodGait)
+	l.LiftStagger = 20 * time.Millisecond
+	l.SetState(StateStepUp)
+
+	// The tick on which each leg of the first set left the ground.
+	set := l.legSet()[0]
+	started := map[int]int{}
+
+	for tick := 1; tick <= 20 && l.State == StateStepUp; tick++ {
+		l.stateCounter += 1
+		l.tickState()
+
+		for k, ii := range set {
+			if _, ok := started[k]; !ok && l.feet[ii].Y > l.stepDownPosition() {
+				started[k] = tick
+			}
+		}
+
+		c.Advance(10 * time.Millisecond)
+	}
+
+	// At 10ms per tick, each leg should start two ticks after the one before.
+	for k := range set {
+		if exp := 1 + (k * 2); started[k] != exp {
+			t.Errorf("leg %d of set started lifting on tick %d, expected %d", k, started[k], exp)
+		}
+	}
+
+	// And the set should only move on once the last leg has finished lifting.
+	if l.State != StateStepOver {
+		t.Errorf("expected to move on to %s, but in %s", StateStepOver, l.State)
+	}
+
+	for _, ii := range set {
+		if y := l.feet[ii].Y; y != l.stepUpPosition() {
+			t.Errorf("leg %d is at %0.2f, expected %0.2f", ii, y, l.stepUpPosition())
+		}
+	}
+}
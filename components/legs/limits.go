@@ -0,0 +1,65 @@
+package legs
+
+import (
+	"github.com/adammck/hexapod/utils"
+)
+
+const (
+
+	// The default width (in degrees) of the band inside each joint limit in which
+	// OnNearLimit is called.
+	defaultLimitWarning = 5.0
+)
+
+// AngleRange is a range of angles, in degrees.
+type AngleRange struct {
+	Min float64
+	Max float64
+}
+
+// contains returns true if the given angle is within the range, or if the range
+// is nil. The angle is wrapped first, so it doesn't matter which way around it
+// was solved.
+func (r *AngleRange) contains(a float64) bool {
+	if r == nil {
+		return true
+	}
+
+	a = utils.NormalizeDeg(a)
+	return a >= r.Min && a <= r.Max
+}
+
+// JointLimits holds the minimum and maximum angle (in the same terms as the
+// solved IK angles) of each joint of a leg.
+type JointLimits struct {
+	Min JointAngles
+	Max JointAngles
+}
+
+// clamp returns the given angles, limited to the range of each joint, and
+// calls warn with the name of each joint which is within band degrees of (or
+// beyond) its limit.
+func (lim *JointLimits) clamp(a JointAngles, band float64, warn func(joint string, angle float64)) JointAngles {
+	return JointAngles{
+		Coxa:   clampJoint("coxa", a.Coxa, lim.Min.Coxa, lim.Max.Coxa, band, warn),
+		Femur:  clampJoint("femur", a.Femur, lim.Min.Femur, lim.Max.Femur, band, warn),
+		Tibia:  clampJoint("tibia", a.Tibia, lim.Min.Tibia, lim.Max.Tibia, band, warn),
+		Tarsus: clampJoint("tarsus", a.Tarsus, lim.Min.Tarsus, lim.Max.Tarsus, band, warn),
+	}
+}
+
+func clampJoint(name string, a float64, min float64, max float64, band float64, warn func(joint string, angle float64)) float64 {
+	if warn != nil && (a < min+band || a > max-band) {
+		warn(name, a)
+	}
+
+	if a < min {
+		return min
+	}
+
+	if a > max {
+		return max
+	}
+
+	return a
+}
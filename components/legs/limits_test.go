@@ -0,0 +1,88 @@
+package legs
+
+import (
+	"github.com/adammck/hexapod"
+	"github.com/adammck/hexapod/math3d"
+	"testing"
+)
+
+func TestJointLimits(t *testing.T) {
+	leg := &Leg{
+		Origin:       &math3d.Vector3{0, 0, 0},
+		Name:         "whatever",
+		Initialized:  true,
+		LimitWarning: 5,
+	}
+
+	m := mockLeg(leg)
+	target := math3d.Vector3{200, -80, 30}
+	coxa, femur, tibia, tarsus, _ := leg.SolveIK(target)
+
+	near := map[string]float64{}
+	leg.OnNearLimit = func(l *Leg, joint string, angle float64) {
+		near[joint] = angle
+	}
+
+	// Coxa is well within its limits, femur is in the warning band, and tibia
+	// is beyond its limit.
+	leg.Limits = &JointLimits{
+		Min: JointAngles{coxa - 45, femur - 3, -180, -180},
+		Max: JointAngles{coxa + 45, 180, tibia - 10, 180},
+	}
+
+	if err := leg.SetGoal(target); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	exp := [4]float64{coxa, femur, tibia - 10, tarsus}
+	for i, s := range m {
+		if s.angle != exp[i] {
+			t.Errorf("servo %d moved to %0.4f, expected %0.4f", i, s.angle, exp[i])
+		}
+	}
+
+	if len(near) != 2 || near["femur"] != femur || near["tibia"] != tibia {
+		t.Errorf("unexpected warnings: %v", near)
+	}
+}
+
+func TestCoxaRange(t *testing.T) {
+	leg := &Leg{
+		Origin:    &math3d.Vector3{0, 0, 0},
+		Angle:     180,
+		CoxaRange: &AngleRange{-20, 20},
+	}
+
+	// Straight out (to the left, since the leg is turned around), and a bit
+	// either side.
+	for _, deg := range []float64{0, 15, -15} {
+		p := math3d.Vector3{200, -80, 0}.RotateY(leg.Angle + deg)
+		if _, _, _, _, err := leg.SolveIK(p); err != nil {
+			t.Errorf("%0.0f deg: unexpected error: %s", deg, err)
+		}
+	}
+
+	for _, deg := range []float64{30, -30, 90} {
+		p := math3d.Vector3{200, -80, 0}.RotateY(leg.Angle + deg)
+		if _, _, _, _, err := leg.SolveIK(p); err != ErrUnreachable {
+			t.Errorf("%0.0f deg: got %v, expected ErrUnreachable", deg, err)
+		}
+	}
+}
+
+// A turn which would swing the planted feet too far around should be refused,
+// so the legs step instead.
+func TestCoxaRangeRejectsTurn(t *testing.T) {
+	l := New(hexapod.NewHexapod(nil), nil)
+	for _, leg := range l.Legs {
+		leg.CoxaRange = &AngleRange{-30, 30}
+	}
+
+	if err := l.ValidatePose(l.hexapod.Position, 10); err != nil {
+		t.Errorf("unexpected error turning 10 deg: %s", err)
+	}
+
+	if err := l.ValidatePose(l.hexapod.Position, 30); err == nil {
+		t.Errorf("expected error turning 30 deg")
+	}
+}
@@ -0,0 +1,14 @@
+package legs
+
+// Limp scales the height which the feet are lifted to when stepping by the given
+// fraction, to draw less current while the battery is running low. One lifts
+// them to StepHeight again.
+func (l *Legs) Limp(f float64) {
+	l.limp = f
+}
+
+// stepHeight returns the height (above where they touch down) which the feet
+// should be lifted to when stepping. This is StepHeight, unless limping.
+func (l *Legs) stepHeight() float64 {
+	return l.StepHeight * l.limp
+}
@@ -0,0 +1,62 @@
+package legs
+
+import (
+	"bytes"
+	"github.com/adammck/hexapod"
+	"github.com/adammck/hexapod/components/controller"
+	"github.com/adammck/hexapod/components/voltage"
+	"github.com/adammck/hexapod/math3d"
+	"math"
+	"testing"
+)
+
+func TestLimp(t *testing.T) {
+	l := New(hexapod.NewHexapod(nil), nil)
+	p := math3d.Vector3{100, 0, 100}
+
+	if y := l.footUpAt(p); y != l.StepHeight {
+		t.Errorf("got %0.2f, expected %0.2f", y, l.StepHeight)
+	}
+
+	l.Limp(0.5)
+	if y := l.footUpAt(p); y != l.StepHeight/2 {
+		t.Errorf("limping: got %0.2f, expected %0.2f", y, l.StepHeight/2)
+	}
+
+	if y := l.stepUpPosition(); y != l.FootDown+(l.StepHeight/2) {
+		t.Errorf("limping: got step up %0.2f, expected %0.2f", y, l.FootDown+(l.StepHeight/2))
+	}
+}
+
+func TestVoltageLimp(t *testing.T) {
+	h := hexapod.NewHexapod(nil)
+	l, _, m := mockLegs(h)
+	m[0][0].voltage = 10.0
+
+	// Half way between the warning and the minimum (9.6v), so everything should
+	// go at half speed, like main wires it.
+	c := controller.New(h, &bytes.Buffer{})
+	vc := voltage.New(m[0][0])
+	vc.WarningVoltage = 10.4
+	vc.Limpers = []voltage.Limper{c, l}
+	h.Add(vc)
+	h.Add(c)
+
+	full := hexapod.NewHexapod(nil)
+	fc := controller.New(full, &bytes.Buffer{})
+	full.Add(fc)
+
+	in := hexapod.InputState{LeftStick: hexapod.Stick{0, -127}}
+	if err := h.Step(in); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	full.Step(in)
+	if exp := full.Position.Z / 2; math.Abs(h.Position.Z-exp) > 0.000001 {
+		t.Errorf("walked %0.2f mm, expected %0.2f", h.Position.Z, exp)
+	}
+
+	if exp := l.StepHeight / 2; math.Abs(l.stepHeight()-exp) > 0.000001 {
+		t.Errorf("lifting feet %0.2f mm, expected %0.2f", l.stepHeight(), exp)
+	}
+}
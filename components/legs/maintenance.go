@@ -0,0 +1,86 @@
+package legs
+
+import (
+	"fmt"
+	"github.com/adammck/hexapod/math3d"
+)
+
+const (
+
+	// The stance which the other legs take while one is detached for
+	// maintenance. It should be the widest of Stances.
+	maintenanceStance = "low"
+)
+
+// MaintenanceMode detaches the given leg, so it can be worked on while the body
+// stands on the rest: the feet step out to the wide maintenance stance, then the
+// leg is disabled and its servos are relaxed. Returns an error (and changes
+// nothing) if the legs aren't standing still, or if the body wouldn't be stable
+// without the leg, while standing or while any leg set of the gait is lifted to
+// step out (as DisableLeg requires). EnableLeg reattaches it, ramping it from
+// wherever it was left.
+func (l *Legs) MaintenanceMode(i int) error {
+	if i < 0 || i >= len(l.Legs) {
+		return fmt.Errorf("invalid leg: %d", i)
+	}
+
+	if i == l.manipulator {
+		return fmt.Errorf("leg %s is a manipulator", l.Legs[i].Name)
+	}
+
+	if !l.Standing() || l.stepping() || l.sLegsIndex != 0 {
+		return fmt.Errorf("can't detach a leg while %s", l.State)
+	}
+
+	prev, wasDisabled := l.stance(), l.disabled[i]
+	if err := l.SetStance(maintenanceStance); err != nil {
+		return err
+	}
+
+	l.disabled[i] = true
+
+	if m := l.maintenanceMargin(i); m < l.MinStabilityMargin {
+		l.disabled[i] = wasDisabled
+		l.setStance(prev)
+		return fmt.Errorf("can't detach leg %s: the margin would be %0.1f mm", l.Legs[i].Name, m)
+	}
+
+	// The feet step out to the new stance, so each leg set has to be liftable
+	// without the leg, as DisableLeg requires.
+	if set, m := l.weakestLegSet(); m < l.MinStabilityMargin {
+		l.disabled[i] = wasDisabled
+		l.setStance(prev)
+		return fmt.Errorf("can't detach leg %s: lifting %v would leave a margin of %0.1f mm", l.Legs[i].Name, set, m)
+	}
+
+	leg := l.Legs[i]
+	for _, servo := range leg.Servos() {
+		servo.SetTorqueEnable(false)
+	}
+
+	// It'll be moved by hand, so must be initialized again when it's enabled.
+	leg.ForgetGoals()
+	leg.Initialized = false
+	return nil
+}
+
+// maintenanceMargin returns the lower of the stability margins without the
+// given leg, with the other planted feet where they are now, and with the legs
+// in the gait at their home positions.
+func (l *Legs) maintenanceMargin(i int) float64 {
+	now := supportMargin(l.hexapod.Position, l.plantedFeet(i))
+
+	feet := []math3d.Vector3{}
+	for ii, leg := range l.Legs {
+		if l.inGait(ii) {
+			feet = append(feet, *l.homeFootPosition(leg))
+		}
+	}
+
+	home := supportMargin(l.hexapod.Position, feet)
+	if now < home {
+		return now
+	}
+
+	return home
+}
@@ -0,0 +1,110 @@
+package legs
+
+import (
+	"github.com/adammck/hexapod"
+	"testing"
+)
+
+func TestMaintenanceMode(t *testing.T) {
+	h := hexapod.NewHexapod(nil)
+	l, _, m := mockLegs(h)
+	for _, leg := range l.Legs {
+		leg.Initialized = true
+	}
+	l.initCounter = len(l.Legs)
+
+	// Like DisableLeg, this only works with gaits which lift one leg at a time.
+	l.SetGait(WaveGait)
+	if err := l.MaintenanceMode(0); err == nil {
+		t.Errorf("expected error while not standing")
+	}
+
+	l.SetState(StateStand)
+	if err := l.MaintenanceMode(0); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if l.LegEnabled(0) || l.Legs[0].Initialized {
+		t.Errorf("leg wasn't detached")
+	}
+
+	if l.StanceRadius != Stances[maintenanceStance].StanceRadius {
+		t.Errorf("stance radius is %0.1f, expected the maintenance stance", l.StanceRadius)
+	}
+
+	for _, s := range m[0] {
+		if s.torque {
+			t.Errorf("servo #%d wasn't relaxed", s.id)
+		}
+	}
+
+	// Reattaching it initializes it again.
+	if err := l.EnableLeg(0); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if !l.Legs[0].Initialized || !m[0][0].torque {
+		t.Errorf("leg wasn't reinitialized")
+	}
+}
+
+func TestMaintenanceModeUnstable(t *testing.T) {
+	h := hexapod.NewHexapod(nil)
+	l, _, m := mockLegs(h)
+	l.SetState(StateStand)
+	for i := range m[0] {
+		m[0][i].torque = true
+	}
+
+	// Nothing is that stable on five legs.
+	l.MinStabilityMargin = 1000
+	radius := l.StanceRadius
+
+	if err := l.MaintenanceMode(0); err == nil {
+		t.Fatalf("expected error")
+	}
+
+	if !l.LegEnabled(0) || l.StanceRadius != radius || !m[0][0].torque {
+		t.Errorf("state was changed despite the error")
+	}
+}
+
+func TestMaintenanceModeWhileStepping(t *testing.T) {
+	h := hexapod.NewHexapod(nil)
+	l, _, m := mockLegs(h)
+	l.SetState(StateStand)
+	for i := range m[0] {
+		m[0][i].torque = true
+	}
+
+	// Standing on five legs is fine, but the tripod would lift two of them at
+	// once to step out, and DisableLeg refuses that too.
+	l.SetGait(TripodGait)
+	if err := l.MaintenanceMode(0); err == nil {
+		t.Fatalf("expected error")
+	}
+
+	if !l.LegEnabled(0) || !m[0][0].torque || l.wantsStep() {
+		t.Errorf("state was changed despite the error")
+	}
+}
+
+func TestMaintenanceModeKeepsDisabled(t *testing.T) {
+	h := hexapod.NewHexapod(nil)
+	l, _, _ := mockLegs(h)
+	l.SetState(StateStand)
+	l.SetGait(WaveGait)
+	l.MinStabilityMargin = 30
+	if err := l.DisableLeg(2); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	l.MinStabilityMargin = 1000
+	if err := l.MaintenanceMode(2); err == nil {
+		t.Fatalf("expected error")
+	}
+
+	if l.LegEnabled(2) {
+		t.Errorf("leg was enabled by the failed detach")
+	}
+}
@@ -0,0 +1,72 @@
+package legs
+
+import (
+	"fmt"
+	"github.com/adammck/hexapod/math3d"
+)
+
+const (
+
+	// The value of Legs.manipulator when no leg is being used as a manipulator.
+	noManipulator = -1
+)
+
+// SetManipulator takes the given leg out of the gait, and lifts its foot off the
+// ground, so it can be moved around directly with SetManipulatorGoal (e.g. to
+// point at things). The other five legs carry on supporting the body. Returns
+// an error if the legs aren't standing still, or if the body wouldn't be stable
+// on the other five.
+func (l *Legs) SetManipulator(i int) error {
+	if i < 0 || i >= len(l.Legs) {
+		return fmt.Errorf("invalid leg: %d", i)
+	}
+
+	if l.manipulator != noManipulator {
+		return fmt.Errorf("leg %s is already a manipulator", l.Legs[l.manipulator].Name)
+	}
+
+	if !l.Standing() {
+		return fmt.Errorf("can't lift a leg while %s", l.State)
+	}
+
+	m := supportMargin(l.hexapod.Position, l.plantedFeet(i))
+	if m < l.MinStabilityMargin {
+		return fmt.Errorf("not stable enough to lift leg %s (margin %0.1f mm)", l.Legs[i].Name, m)
+	}
+
+	// Start by lifting the foot straight up.
+	goal := l.feet[i].MultiplyByMatrix44(l.hexapod.Local())
+	goal.Y += l.StepHeight
+
+	l.manipulator = i
+	l.manipulatorGoal = goal
+	return nil
+}
+
+// SetManipulatorGoal moves the foot of the manipulator leg to the given point,
+// in the hexapod coordinate space. Returns ErrUnreachable if the foot can't be
+// placed there.
+func (l *Legs) SetManipulatorGoal(p math3d.Vector3) error {
+	if l.manipulator == noManipulator {
+		return fmt.Errorf("no manipulator")
+	}
+
+	if !l.Legs[l.manipulator].CanReach(p) {
+		return ErrUnreachable
+	}
+
+	l.manipulatorGoal = p
+	return nil
+}
+
+// ReleaseManipulator puts the foot of the manipulator leg back down at its home
+// position, and returns it to the gait. It's moved straight there, so it should
+// be moved to somewhere above there first.
+func (l *Legs) ReleaseManipulator() {
+	if l.manipulator == noManipulator {
+		return
+	}
+
+	l.feet[l.manipulator] = l.homeFootPosition(l.Legs[l.manipulator])
+	l.manipulator = noManipulator
+}
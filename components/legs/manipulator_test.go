@@ -0,0 +1,75 @@
+package legs
+
+import (
+	"github.com/adammck/hexapod"
+	"github.com/adammck/hexapod/math3d"
+	"testing"
+)
+
+func TestManipulator(t *testing.T) {
+	h := hexapod.NewHexapod(nil)
+	l, _, _ := mockLegs(h)
+	for _, leg := range l.Legs {
+		leg.Initialized = true
+	}
+
+	if err := l.SetManipulator(0); err == nil {
+		t.Errorf("expected error while not standing")
+	}
+
+	l.SetState(StateStand)
+	if err := l.SetManipulator(0); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// The manipulator shouldn't be stepped, or counted as support.
+	for _, set := range l.legSet() {
+		for _, ii := range set {
+			if ii == 0 {
+				t.Errorf("manipulator is still in the gait")
+			}
+		}
+	}
+
+	if len(l.plantedFeet(noManipulator)) != 5 {
+		t.Errorf("expected five planted feet")
+	}
+
+	// Move it around in the hexapod space, then move the body. It should stay
+	// put relative to the body.
+	goal := math3d.Vector3{-150, 0, 200}
+	if err := l.SetManipulatorGoal(goal); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	h.Position = math3d.Vector3{5, 0, 5}
+	l.updateFeet()
+	if p, _ := l.Legs[0].FootPosition(); p.Distance(goal) > 0.0001 {
+		t.Errorf("manipulator at %s, expected %s", p, goal)
+	}
+
+	if err := l.SetManipulatorGoal(math3d.Vector3{-1000, 0, 0}); err != ErrUnreachable {
+		t.Errorf("expected ErrUnreachable, got %v", err)
+	}
+
+	l.ReleaseManipulator()
+	if l.manipulator != noManipulator || len(l.legSet()[0]) != 2 {
+		t.Errorf("manipulator wasn't released")
+	}
+}
+
+func TestManipulatorUnstable(t *testing.T) {
+	h := hexapod.NewHexapod(nil)
+	l := New(h, nil)
+	l.SetState(StateStand)
+
+	// Lean the body right over the front left foot, so lifting it would tip.
+	h.Position = l.feet[0].Scale(0.8)
+	if err := l.SetManipulator(0); err == nil {
+		t.Errorf("expected error when unstable")
+	}
+
+	if l.manipulator != noManipulator {
+		t.Errorf("manipulator was set anyway")
+	}
+}
@@ -0,0 +1,176 @@
+package legs
+
+import (
+	"fmt"
+	"github.com/adammck/hexapod"
+	"github.com/adammck/hexapod/math3d"
+	"math"
+)
+
+// mockServo records the commands sent to it, and returns canned values for
+// reads. It's not attached to anything.
+type mockServo struct {
+	id     uint8
+	absent bool
+
+	torque bool
+	led    bool
+	speed  int
+	moves  []float64
+
+	angle   float64
+	voltage float64
+	model   int
+	load    int
+
+	// The number of angle reads to fail before succeeding again.
+	flaky int
+}
+
+func (s *mockServo) err() error {
+	if s.absent {
+		return fmt.Errorf("servo #%d: timeout", s.id)
+	}
+
+	return nil
+}
+
+func (s *mockServo) Ping() error {
+	return s.err()
+}
+
+func (s *mockServo) SetStatusReturnLevel(value int) error {
+	return s.err()
+}
+
+func (s *mockServo) SetTorqueEnable(state bool) error {
+	s.torque = state
+	return s.err()
+}
+
+func (s *mockServo) SetMovingSpeed(speed int) error {
+	s.speed = speed
+	return s.err()
+}
+
+func (s *mockServo) SetLed(state bool) error {
+	s.led = state
+	return s.err()
+}
+
+func (s *mockServo) MoveTo(angle float64) error {
+	s.moves = append(s.moves, angle)
+	s.angle = angle
+	return s.err()
+}
+
+func (s *mockServo) Angle() (float64, error) {
+	if s.flaky > 0 {
+		s.flaky -= 1
+		return 0, fmt.Errorf("servo #%d: timeout", s.id)
+	}
+
+	return s.angle, s.err()
+}
+
+func (s *mockServo) Voltage() (float64, error) {
+	return s.voltage, s.err()
+}
+
+func (s *mockServo) ModelNumber() (int, error) {
+	return s.model, s.err()
+}
+
+func (s *mockServo) Load() (int, error) {
+	return s.load, s.err()
+}
+
+// mockLeg replaces the servos of the given leg with mocks, and returns them.
+func mockLeg(leg *Leg) [4]*mockServo {
+	ids := leg.ServoIDs()
+	m := [4]*mockServo{}
+	for i := range m {
+		m[i] = &mockServo{id: ids[i], voltage: 12, model: 12}
+	}
+
+	leg.Coxa = m[0]
+	leg.Femur = m[1]
+	leg.Tibia = m[2]
+	leg.Tarsus = m[3]
+	return m
+}
+
+// poseLeg sets the angles of the given mock servos as if the foot of the leg was
+// at the given point (in the leg space), and returns them.
+func poseLeg(leg *Leg, m [4]*mockServo, p math3d.Vector3) (JointAngles, error) {
+	c, f, tb, ts, err := leg.SolveIK(p)
+	if err != nil {
+		return JointAngles{}, err
+	}
+
+	for i, angle := range [4]float64{c, f, tb, ts} {
+		m[i].angle = angle
+	}
+
+	return JointAngles{c, f, tb, ts}, nil
+}
+
+// loadLeg sets the loads of the given mock servos to the torques needed for the
+// foot to push with the given force (in newtons), with the leg at the given
+// angles.
+func loadLeg(leg *Leg, m [4]*mockServo, a JointAngles, force math3d.Vector3) {
+	j := leg.jacobian(a)
+	for i := range j {
+		torque := (j[i].X * force.X) + (j[i].Y * force.Y) + (j[i].Z * force.Z)
+		m[i].load = encodeLoad(torque)
+	}
+}
+
+// loadFeet poses and loads the mock servos as if each foot was at its goal, and
+// pushing down with the given force (in newtons).
+func loadFeet(l *Legs, m [6][4]*mockServo, force [6]float64) {
+	for i, leg := range l.Legs {
+		a, _ := poseLeg(leg, m[i], l.feet[i].MultiplyByMatrix44(l.hexapod.Local()))
+		loadLeg(leg, m[i], a, math3d.Vector3{0, -force[i], 0})
+	}
+}
+
+// encodeLoad is the inverse of loadFraction, for a torque in N*mm.
+func encodeLoad(torque float64) int {
+	raw := int(math.Abs(torque)/stallTorque*1023 + 0.5)
+	if torque < 0 {
+		raw |= 0x400
+	}
+
+	return raw
+}
+
+// mockNetwork counts the ACTIONs sent to it.
+type mockNetwork struct {
+	buffered bool
+	actions  int
+}
+
+func (n *mockNetwork) SetBuffered(buffered bool) {
+	n.buffered = buffered
+}
+
+func (n *mockNetwork) Action() error {
+	n.actions += 1
+	return nil
+}
+
+// mockLegs returns a new Legs on the given hexapod, with every servo and the
+// network replaced with mocks.
+func mockLegs(h *hexapod.Hexapod) (*Legs, *mockNetwork, [6][4]*mockServo) {
+	l := New(h, nil)
+	n := &mockNetwork{}
+	l.Network = n
+
+	m := [6][4]*mockServo{}
+	for i, leg := range l.Legs {
+		m[i] = mockLeg(leg)
+	}
+
+	return l, n, m
+}
@@ -0,0 +1,48 @@
+package legs
+
+import (
+	"fmt"
+	"github.com/adammck/hexapod"
+)
+
+// The names of the Dynamixel models which we know about, by the value of their
+// model number register.
+var models = map[int]string{
+	12:  "AX-12",
+	18:  "AX-18",
+	300: "AX-12W",
+	24:  "RX-24F",
+	28:  "RX-28",
+	64:  "RX-64",
+	107: "EX-106+",
+	29:  "MX-28",
+	310: "MX-64",
+	320: "MX-106",
+}
+
+// modelName returns the name of the Dynamixel model with the given number.
+func modelName(n int) string {
+	if name, ok := models[n]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("unknown (%d)", n)
+}
+
+// DetectModels reads the model number of each servo, and returns a map of servo
+// ID to model name. Unrecognized models are named "unknown (N)".
+func (leg *Leg) DetectModels() (map[uint8]string, error) {
+	res := map[uint8]string{}
+	ids := leg.ServoIDs()
+
+	for i, servo := range leg.Servos() {
+		n, err := servo.ModelNumber()
+		if err != nil {
+			return nil, &hexapod.ServoError{ids[i], err}
+		}
+
+		res[ids[i]] = modelName(n)
+	}
+
+	return res, nil
+}
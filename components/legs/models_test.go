@@ -0,0 +1,45 @@
+package legs
+
+import (
+	"github.com/adammck/hexapod/math3d"
+	"testing"
+)
+
+func TestModelName(t *testing.T) {
+	data := map[int]string{
+		12:  "AX-12",
+		300: "AX-12W",
+		29:  "MX-28",
+		320: "MX-106",
+		999: "unknown (999)",
+	}
+
+	for n, exp := range data {
+		if name := modelName(n); name != exp {
+			t.Errorf("model %d: got %q, expected %q", n, name, exp)
+		}
+	}
+}
+
+func TestDetectModels(t *testing.T) {
+	leg := &Leg{Origin: &math3d.Vector3{}, BaseID: 10}
+	m := mockLeg(leg)
+	m[2].model = 29
+
+	res, err := leg.DetectModels()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	exp := map[uint8]string{11: "AX-12", 12: "AX-12", 13: "MX-28", 14: "AX-12"}
+	for id, name := range exp {
+		if res[id] != name {
+			t.Errorf("servo #%d: got %q, expected %q", id, res[id], name)
+		}
+	}
+
+	m[3].absent = true
+	if _, err := leg.DetectModels(); err == nil {
+		t.Errorf("expected error with servo missing")
+	}
+}
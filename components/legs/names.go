@@ -0,0 +1,67 @@
+package legs
+
+import (
+	"strings"
+)
+
+// LegByName returns the leg with the given name (e.g. "FL"), ignoring case, and
+// whether there is one.
+func (l *Legs) LegByName(name string) (*Leg, bool) {
+	i, ok := l.LegIndex(name)
+	if !ok {
+		return nil, false
+	}
+
+	return l.Legs[i], true
+}
+
+// LegIndex returns the index (in Legs) of the leg with the given name, ignoring
+// case, and whether there is one. The index is what the rest of the legs API
+// (e.g. DisableLeg) expects.
+func (l *Legs) LegIndex(name string) (int, bool) {
+	for i, leg := range l.Legs {
+		if strings.EqualFold(leg.Name, name) {
+			return i, true
+		}
+	}
+
+	return -1, false
+}
+
+// LegNames returns the name of each leg, in the same order as Legs.
+func (l *Legs) LegNames() []string {
+	names := make([]string, len(l.Legs))
+	for i, leg := range l.Legs {
+		names[i] = leg.Name
+	}
+
+	return names
+}
+
+// LeftLegs returns the legs on the left side of the body, i.e. those whose
+// origin is at a negative X, in the same order as Legs. The middle legs stick
+// straight out sideways, so belong to the side they're on like any other. A
+// leg exactly on the center line (which the default config doesn't have) is on
+// neither side.
+func (l *Legs) LeftLegs() []*Leg {
+	return l.side(-1)
+}
+
+// RightLegs returns the legs on the right side of the body, i.e. those whose
+// origin is at a positive X. See LeftLegs.
+func (l *Legs) RightLegs() []*Leg {
+	return l.side(1)
+}
+
+// side returns the legs whose origin has the same sign on the X axis as the
+// given number.
+func (l *Legs) side(sign float64) []*Leg {
+	res := []*Leg{}
+	for _, leg := range l.Legs {
+		if leg.Origin.X*sign > 0 {
+			res = append(res, leg)
+		}
+	}
+
+	return res
+}
@@ -0,0 +1,59 @@
+package legs
+
+import (
+	"github.com/adammck/hexapod"
+	"reflect"
+	"testing"
+)
+
+func TestLegByName(t *testing.T) {
+	l := New(hexapod.NewHexapod(nil), nil)
+
+	for i, name := range []string{"FL", "FR", "MR", "BR", "BL", "ML"} {
+		leg, ok := l.LegByName(name)
+		if !ok || leg != l.Legs[i] {
+			t.Errorf("%s: got %v, expected leg %d", name, leg, i)
+		}
+
+		if ii, ok := l.LegIndex(name); !ok || ii != i {
+			t.Errorf("%s: got index %d, expected %d", name, ii, i)
+		}
+	}
+
+	if leg, ok := l.LegByName("br"); !ok || leg != l.Legs[3] {
+		t.Errorf("expected names to be case insensitive")
+	}
+
+	if _, ok := l.LegByName("XX"); ok {
+		t.Errorf("expected no leg called XX")
+	}
+
+	if i, ok := l.LegIndex("XX"); ok || i != -1 {
+		t.Errorf("got index %d for XX, expected -1", i)
+	}
+
+	if n := l.LegNames(); !reflect.DeepEqual(n, []string{"FL", "FR", "MR", "BR", "BL", "ML"}) {
+		t.Errorf("got names %v", n)
+	}
+}
+
+func TestLeftRightLegs(t *testing.T) {
+	l := New(hexapod.NewHexapod(nil), nil)
+
+	names := func(legs []*Leg) []string {
+		res := []string{}
+		for _, leg := range legs {
+			res = append(res, leg.Name)
+		}
+
+		return res
+	}
+
+	if n := names(l.LeftLegs()); !reflect.DeepEqual(n, []string{"FL", "BL", "ML"}) {
+		t.Errorf("got left legs %v", n)
+	}
+
+	if n := names(l.RightLegs()); !reflect.DeepEqual(n, []string{"FR", "MR", "BR"}) {
+		t.Errorf("got right legs %v", n)
+	}
+}
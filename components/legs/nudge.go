@@ -0,0 +1,47 @@
+package legs
+
+import (
+	"github.com/adammck/hexapod/math3d"
+)
+
+const (
+
+	// Offsets (in mm) between the feet and their home positions smaller than
+	// this aren't worth nudging the body to correct.
+	minNudge = 1.0
+)
+
+// nudgeHome drifts the body a little (see HomeNudge) towards the point where the
+// feet would be at their home positions, if the body hasn't been moved by
+// anything else since the last tick. This keeps the feet from ending up spread
+// unevenly after the body has been moved around without stepping.
+func (l *Legs) nudgeHome() {
+	if l.HomeNudge <= 0 {
+		return
+	}
+
+	pos := l.hexapod.Position
+	moved := l.nudgePos == nil || pos.Distance(*l.nudgePos) > 0
+	l.nudgePos = &pos
+	if moved {
+		return
+	}
+
+	// The offset between the center of the feet and the center of their home
+	// positions, on the X/Z axis.
+	off := math3d.Vector3{}
+	for i, leg := range l.Legs {
+		off = *off.Add(*l.feet[i].Subtract(*l.homeFootPosition(leg)))
+	}
+
+	off = off.Scale(1.0 / float64(len(l.Legs)))
+	off.Y = 0
+	if off.Length() < minNudge {
+		return
+	}
+
+	next := *pos.Add(off.Scale(l.HomeNudge))
+	if l.hexapod.SetPose(next, l.hexapod.Rotation) == nil {
+		l.nudgePos = &next
+	}
+}
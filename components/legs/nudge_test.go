@@ -0,0 +1,75 @@
+package legs
+
+import (
+	"github.com/adammck/hexapod"
+	"github.com/adammck/hexapod/math3d"
+	"testing"
+)
+
+// homeOffset returns the horizontal distance between the center of the feet and
+// the center of their home positions.
+func homeOffset(l *Legs) float64 {
+	off := math3d.Vector3{}
+	for i, leg := range l.Legs {
+		off = *off.Add(*l.feet[i].Subtract(*l.homeFootPosition(leg)))
+	}
+
+	off.Y = 0
+	return off.Length() / float64(len(l.Legs))
+}
+
+func TestNudgeHome(t *testing.T) {
+	h := hexapod.NewHexapod(nil)
+	l := New(h, nil)
+	h.Add(l)
+	l.SetState(StateStand)
+	l.dontMove = true
+	l.HomeNudge = 0.05
+
+	// Shift the body without stepping.
+	h.Position = math3d.Vector3{12, 0, -8}
+	before := homeOffset(l)
+
+	for i := 0; i < 200; i++ {
+		l.stateCounter += 1
+		l.tickState()
+	}
+
+	if after := homeOffset(l); after > minNudge || after >= before {
+		t.Errorf("offset went from %0.2f to %0.2f, expected < %0.2f", before, after, minNudge)
+	}
+}
+
+func TestNudgeHomeDisabled(t *testing.T) {
+	h := hexapod.NewHexapod(nil)
+	l := New(h, nil)
+	l.SetState(StateStand)
+	l.dontMove = true
+
+	h.Position = math3d.Vector3{12, 0, -8}
+	for i := 0; i < 200; i++ {
+		l.tickState()
+	}
+
+	if p := (math3d.Vector3{12, 0, -8}); h.Position.Distance(p) > 0 {
+		t.Errorf("body moved to %s with nudge disabled", h.Position)
+	}
+}
+
+func TestNudgeHomeWaitsForInput(t *testing.T) {
+	h := hexapod.NewHexapod(nil)
+	l := New(h, nil)
+	l.SetState(StateStand)
+	l.dontMove = true
+	l.HomeNudge = 0.05
+
+	// Keep moving the body, like the controller would.
+	for i := 0; i < 50; i++ {
+		h.Position.X += 0.5
+		l.tickState()
+	}
+
+	if h.Position.X != 25 {
+		t.Errorf("body was nudged while moving: X=%0.2f", h.Position.X)
+	}
+}
@@ -0,0 +1,35 @@
+package legs
+
+import (
+	"github.com/adammck/hexapod/math3d"
+)
+
+// ShiftOrigin moves every position which the legs store in the world space by
+// the given offset, when the origin of the world is moved. See SetOrigin.
+func (l *Legs) ShiftOrigin(offset math3d.Vector3) {
+	shift := func(v *math3d.Vector3) *math3d.Vector3 {
+		if v == nil {
+			return nil
+		}
+
+		return v.Subtract(offset)
+	}
+
+	for i := range l.Legs {
+		l.feet[i] = shift(l.feet[i])
+		l.nextFeet[i] = shift(l.nextFeet[i])
+		l.slipRef[i] = shift(l.slipRef[i])
+	}
+
+	l.nudgePos = shift(l.nudgePos)
+	l.footfallPos = *l.footfallPos.Subtract(offset)
+
+	// The ground stays put, so its height at the new origin is its height at the
+	// old one, at the offset, less the change in height of the origin.
+	g := &l.GroundPlane
+	g.Height += (g.SlopeX * offset.X) + (g.SlopeZ * offset.Z) - offset.Y
+
+	if l.frozen != nil {
+		l.frozen.position = *l.frozen.position.Subtract(offset)
+	}
+}
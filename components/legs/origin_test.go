@@ -0,0 +1,63 @@
+package legs
+
+import (
+	"github.com/adammck/hexapod"
+	"github.com/adammck/hexapod/math3d"
+	"math"
+	"testing"
+)
+
+func TestSetOrigin(t *testing.T) {
+	h := hexapod.NewHexapod(nil)
+	l := New(h, nil)
+	h.Add(l)
+
+	// Walk a long way off, then turn a bit.
+	off := math3d.Vector3{300, 0, 1200}
+	h.Position = off
+	h.Rotation = 30
+	for i := range l.feet {
+		l.feet[i] = l.feet[i].Add(off)
+	}
+
+	before := [6]math3d.Vector3{}
+	for i := range l.Legs {
+		before[i] = l.footGoal(i)
+	}
+
+	h.SetOrigin()
+	if !h.Position.Zero() || h.Rotation != 30 {
+		t.Errorf("expected position to be zero and rotation to be unchanged, got %s, %0.2f", h.Position, h.Rotation)
+	}
+
+	for i := range l.Legs {
+		if g := l.footGoal(i); g.Distance(before[i]) > 0.000001 {
+			t.Errorf("leg %d: goal moved from %s to %s", i, before[i], g)
+		}
+	}
+}
+
+func TestSetOriginGroundPlane(t *testing.T) {
+	h := hexapod.NewHexapod(nil)
+	l := New(h, nil)
+	h.Add(l)
+	l.GroundPlane = GroundPlane{Height: 5, SlopeX: 0.1, SlopeZ: -0.05}
+
+	// A point on the ground, in the old world space.
+	off := math3d.Vector3{300, 10, 1200}
+	p := math3d.Vector3{350, 0, 1100}
+	before := l.GroundPlane.heightAt(p.X, p.Z) - off.Y
+
+	h.Position = off
+	h.SetOrigin()
+
+	// It's still at the same height, in the new one.
+	q := p.Subtract(off)
+	if after := l.GroundPlane.heightAt(q.X, q.Z); math.Abs(after-before) > 0.000001 {
+		t.Errorf("ground moved from %0.4f to %0.4f", before, after)
+	}
+
+	if l.GroundPlane.SlopeX != 0.1 || l.GroundPlane.SlopeZ != -0.05 {
+		t.Errorf("slope changed to %0.4f, %0.4f", l.GroundPlane.SlopeX, l.GroundPlane.SlopeZ)
+	}
+}
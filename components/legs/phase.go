@@ -0,0 +1,48 @@
+package legs
+
+// LegSet returns the index (into the sets of the current gait) of the leg set
+// which is stepping, or which will step next if the legs are standing.
+func (l *Legs) LegSet() int {
+	return l.sLegsIndex
+}
+
+// LegSetPhase returns how far (from 0 to 1) the current leg set is through its
+// step, including the pause after it lands. It's zero while standing.
+func (l *Legs) LegSetPhase() float64 {
+	var c int
+
+	switch l.State {
+	case StateStepUp:
+		c = l.stateCounter
+
+		// The lift may take longer than stepUpCount when it's staggered, but
+		// the phase shouldn't run ahead of the feet.
+		if c > stepUpCount {
+			c = stepUpCount
+		}
+
+	case StateStepOver:
+		c = stepUpCount + l.stateCounter
+
+	case StateStepDown:
+		c = stepUpCount + stepOverCount + l.stateCounter
+
+	default:
+		return 0
+	}
+
+	// The pause may also be stretched while waiting for the body to settle.
+	n := swingCount + l.stancePause()
+	if c > n {
+		c = n
+	}
+
+	return float64(c) / float64(n)
+}
+
+// GaitPhase returns how far (from 0 to 1) the legs are through the whole step
+// cycle of the current gait, in which each leg set steps once. This is useful to
+// move other actuators (e.g. to stabilize a camera) in time with the gait.
+func (l *Legs) GaitPhase() float64 {
+	return (float64(l.sLegsIndex) + l.LegSetPhase()) / float64(len(l.legSet()))
+}
@@ -0,0 +1,52 @@
+package legs
+
+import (
+	"github.com/adammck/hexapod"
+	"testing"
+	"time"
+)
+
+func TestGaitPhase(t *testing.T) {
+	c := &hexapod.FakeClock{T: time.Unix(100, 0)}
+	h := hexapod.NewHexapod(nil)
+	h.Clock = c
+	l := New(h, nil)
+	l.SetGait(TripodGait)
+
+	if p := l.GaitPhase(); p != 0 {
+		t.Errorf("standing: got phase %0.4f, expected 0", p)
+	}
+
+	l.SetState(StateStepUp)
+	last := 0.0
+	sets := map[int]bool{}
+
+	for tick := 0; tick < 100 && l.State != StateStand; tick++ {
+		l.stateCounter += 1
+		set := l.LegSet()
+		p := l.GaitPhase()
+
+		if p < last {
+			t.Fatalf("tick %d: phase went backwards from %0.4f to %0.4f", tick, last, p)
+		}
+
+		if lp := l.LegSetPhase(); lp < 0 || lp > 1 {
+			t.Errorf("tick %d: leg set phase %0.4f out of range", tick, lp)
+		}
+
+		sets[set] = true
+		last = p
+		l.tickState()
+		c.Advance(10 * time.Millisecond)
+	}
+
+	// Both sets of the tripod gait should have stepped, all the way through the
+	// cycle, before the legs stood still again.
+	if len(sets) != 2 || last != 1 {
+		t.Errorf("stepped with sets %v to phase %0.4f, expected 2 sets to 1", sets, last)
+	}
+
+	if l.LegSet() != 0 || l.GaitPhase() != 0 {
+		t.Errorf("expected phase to wrap to 0, got set %d phase %0.4f", l.LegSet(), l.GaitPhase())
+	}
+}
@@ -0,0 +1,23 @@
+package legs
+
+import (
+	"fmt"
+)
+
+// PivotAroundFoot sets the pivot of the hexapod (see hexapod.Turn) to where the
+// foot of the given leg is now, so turning swings the body around it, e.g. to
+// pivot around an obstacle. The pivot is fixed to the body, so stays where the
+// foot was after the foot is stepped. Returns an error if the foot isn't on the
+// ground right now. Set the pivot to zero to turn around the center again.
+func (l *Legs) PivotAroundFoot(i int) error {
+	if i < 0 || i >= len(l.Legs) {
+		return fmt.Errorf("invalid leg: %d", i)
+	}
+
+	if !l.supporting(i) {
+		return fmt.Errorf("leg %s isn't standing", l.Legs[i].Name)
+	}
+
+	l.hexapod.Pivot = l.feet[i].MultiplyByMatrix44(l.hexapod.Local())
+	return nil
+}
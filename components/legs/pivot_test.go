@@ -0,0 +1,48 @@
+package legs
+
+import (
+	"github.com/adammck/hexapod"
+	"github.com/adammck/hexapod/math3d"
+	"testing"
+)
+
+func TestPivotAroundFoot(t *testing.T) {
+	h := hexapod.NewHexapod(nil)
+	l := New(h, nil)
+	h.Add(l)
+
+	if err := l.PivotAroundFoot(1); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// Turning (a little, so the legs can reach) should leave the foot where it
+	// was, relative to the body.
+	before := *l.feet[1]
+	for i := 0; i < 5; i++ {
+		if err := h.Turn(2); err != nil {
+			t.Fatalf("turn %d: unexpected error: %s", i, err)
+		}
+	}
+
+	if p := h.Pivot.MultiplyByMatrix44(h.World()); p.Distance(before) > 0.0001 {
+		t.Errorf("pivot moved from %s to %s", before, p)
+	}
+
+	if h.Rotation != 10 || h.Position.Zero() {
+		t.Errorf("expected to turn and move, got %s at %0.2f deg", h.Position, h.Rotation)
+	}
+
+	// Nor around a foot which is being stepped.
+	h.Pivot = math3d.Vector3{}
+	l.SetState(StateStepUp)
+	swinging := l.legSet()[l.sLegsIndex][0]
+	if err := l.PivotAroundFoot(swinging); err == nil || !h.Pivot.Zero() {
+		t.Errorf("expected error pivoting around a swinging foot")
+	}
+
+	l.SetState(StateStand)
+	l.manipulator = 1
+	if err := l.PivotAroundFoot(1); err == nil {
+		t.Errorf("expected error pivoting around a lifted foot")
+	}
+}
@@ -0,0 +1,56 @@
+package legs
+
+import (
+	"time"
+)
+
+// The default PositionMaxAge. This is a bit less than one tick of the main loop,
+// so everything which reads the positions during a tick shares one read.
+const defaultPositionMaxAge = 15 * time.Millisecond
+
+// Positions returns the present angle of every joint of every leg (in the terms
+// of the IK), reading the servos of any leg whose angles weren't read within the
+// given duration. Disabled legs are left as zero.
+func (l *Legs) Positions(maxAge time.Duration) ([6]JointAngles, error) {
+	res := [6]JointAngles{}
+	now := l.hexapod.Now()
+
+	for i := range l.Legs {
+		if l.disabled[i] {
+			continue
+		}
+
+		a, err := l.legAngles(i, now, maxAge)
+		if err != nil {
+			return res, err
+		}
+
+		res[i] = a
+	}
+
+	return res, nil
+}
+
+// legAngles returns the present angles of the given leg, reading them from the
+// servos only if the cached read was older than maxAge at the given time.
+func (l *Legs) legAngles(i int, now time.Time, maxAge time.Duration) (JointAngles, error) {
+	c := &l.positions[i]
+	if !c.time.IsZero() && now.Sub(c.time) <= maxAge {
+		return c.angles, nil
+	}
+
+	a, err := l.Legs[i].Angles()
+	if err != nil {
+		return JointAngles{}, err
+	}
+
+	c.angles = a
+	c.time = now
+	return a, nil
+}
+
+// cachedAngles is the last read of the present angles of a leg.
+type cachedAngles struct {
+	angles JointAngles
+	time   time.Time
+}
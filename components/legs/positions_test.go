@@ -0,0 +1,47 @@
+package legs
+
+import (
+	"github.com/adammck/hexapod"
+	"testing"
+	"time"
+)
+
+func TestPositions(t *testing.T) {
+	c := &hexapod.FakeClock{T: time.Unix(100, 0)}
+	h := hexapod.NewHexapod(nil)
+	h.Clock = c
+	l, _, m := mockLegs(h)
+
+	before, err := l.Positions(10 * time.Millisecond)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// Move a servo. The cached angles are still fresh, so it's not read again.
+	m[2][1].angle += 10
+	c.Advance(10 * time.Millisecond)
+	p, _ := l.Positions(10 * time.Millisecond)
+	if p != before {
+		t.Errorf("got %+v, expected cached %+v", p[2], before[2])
+	}
+
+	// A smaller max age forces a read.
+	p, _ = l.Positions(5 * time.Millisecond)
+	if p[2].Femur == before[2].Femur {
+		t.Errorf("expected femur of leg 2 to be read again, but got %0.2f", p[2].Femur)
+	}
+
+	if p[2].Coxa != before[2].Coxa || p[0] != before[0] {
+		t.Errorf("expected other joints to be unchanged, got %+v", p)
+	}
+}
+
+func TestPositionsError(t *testing.T) {
+	h := hexapod.NewHexapod(nil)
+	l, _, m := mockLegs(h)
+
+	m[3][2].absent = true
+	if _, err := l.Positions(0); err == nil {
+		t.Errorf("expected error reading absent servo")
+	}
+}
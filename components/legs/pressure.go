@@ -0,0 +1,66 @@
+package legs
+
+import (
+	"errors"
+	"github.com/adammck/hexapod/math3d"
+	"math"
+)
+
+var (
+	errNoLoad = errors.New("no weight on any foot")
+)
+
+// CenterOfPressure estimates the point (in the WORLD space) through which the
+// weight of the hexapod is pushing down, by averaging the positions of the
+// planted feet weighted by the vertical force on each (see FootForce). If the
+// body is balanced, this is right under the center of mass; if it's drifting
+// towards the edge of the support polygon, the hexapod is about to tip.
+//
+// Only the downward force is counted, since a foot can't pull on the ground. The
+// force estimates are rough, and the servos hold some load even with no weight
+// on them (e.g. fighting each other), so don't expect much precision.
+func (l *Legs) CenterOfPressure() (math3d.Vector3, error) {
+	feet := []math3d.Vector3{}
+	weights := []float64{}
+	now := l.hexapod.Now()
+
+	for i, leg := range l.Legs {
+		if !l.planted(i) {
+			continue
+		}
+
+		a, err := l.legAngles(i, now, l.PositionMaxAge)
+		if err != nil {
+			return math3d.ZeroVector3, err
+		}
+
+		f, err := leg.footForce(a)
+		if err != nil {
+			return math3d.ZeroVector3, err
+		}
+
+		feet = append(feet, *l.feet[i])
+		weights = append(weights, -f.Y)
+	}
+
+	return centerOfPressure(feet, weights)
+}
+
+// centerOfPressure returns the average of the given points, weighted by the
+// given weights. Negative weights are ignored.
+func centerOfPressure(points []math3d.Vector3, weights []float64) (math3d.Vector3, error) {
+	sum := math3d.Vector3{}
+	total := 0.0
+
+	for i, p := range points {
+		w := math.Max(0, weights[i])
+		sum = *sum.Add(p.Scale(w))
+		total += w
+	}
+
+	if total == 0 {
+		return math3d.ZeroVector3, errNoLoad
+	}
+
+	return sum.Scale(1 / total), nil
+}
@@ -0,0 +1,60 @@
+package legs
+
+import (
+	"github.com/adammck/hexapod"
+	"github.com/adammck/hexapod/math3d"
+	"testing"
+)
+
+func TestCenterOfPressureMath(t *testing.T) {
+	points := []math3d.Vector3{
+		math3d.Vector3{100, 0, 0},
+		math3d.Vector3{-100, 0, 0},
+		math3d.Vector3{0, 0, 100},
+	}
+
+	data := []struct {
+		weights []float64
+		exp     math3d.Vector3
+	}{
+		{[]float64{1, 1, 1}, math3d.Vector3{0, 0, 33.333333}},
+		{[]float64{2, 1, 1}, math3d.Vector3{25, 0, 25}},
+		{[]float64{3, 1, 0}, math3d.Vector3{50, 0, 0}},
+		{[]float64{1, 1, -5}, math3d.Vector3{0, 0, 0}},
+	}
+
+	for i, d := range data {
+		p, err := centerOfPressure(points, d.weights)
+		if err != nil {
+			t.Errorf("example %d: unexpected error: %s", i+1, err)
+		} else if p.Distance(d.exp) > 0.0001 {
+			t.Errorf("example %d: got %s, expected %s", i+1, p, d.exp)
+		}
+	}
+
+	if _, err := centerOfPressure(points, []float64{0, 0, -1}); err != errNoLoad {
+		t.Errorf("expected errNoLoad, got %v", err)
+	}
+}
+
+func TestCenterOfPressure(t *testing.T) {
+	l, _, m := mockLegs(hexapod.NewHexapod(nil))
+
+	// Put every servo where it would be at home, and push down on legs 0 and 3
+	// (opposite corners) twice as hard as the rest.
+	for _, leg := range l.Legs {
+		leg.Initialized = true
+	}
+
+	loadFeet(l, m, [6]float64{10, 5, 5, 10, 5, 5})
+
+	p, err := l.CenterOfPressure()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// Legs 0 and 3 are opposite each other, so the center should stay put.
+	if p.Distance(math3d.Vector3{}) > 2 {
+		t.Errorf("got center of pressure %s, expected near the origin", p)
+	}
+}
@@ -0,0 +1,76 @@
+package legs
+
+import (
+	"errors"
+	"fmt"
+	"github.com/adammck/hexapod"
+)
+
+const (
+
+	// The temperature (in degrees C) above which a servo fails the self-test.
+	// This is a bit below the default temperature limit of an AX-12 (70C), at
+	// which point it shuts itself down.
+	maxSelfTestTemperature = 65
+)
+
+// thermometer is implemented by servos which can report their temperature. Not
+// every Servo can (e.g. the mocks), so it's checked separately.
+type thermometer interface {
+	Temperature() (int, error)
+}
+
+// SelfTest checks that the legs are fit to stand up: that every servo responds,
+// isn't browning out (see BrownoutVoltage) or overheating, and that every foot
+// can reach its stance and stride positions (see Validate). It returns an
+// error wrapping every problem, or nil if there are none. Nothing is moved.
+func (l *Legs) SelfTest() error {
+	problems := []error{}
+
+	// Don't bother reading from servos which aren't there.
+	res, err := l.Ping()
+	if err != nil {
+		problems = append(problems, err)
+	}
+
+	for i, leg := range l.Legs {
+		if l.disabled[i] {
+			continue
+		}
+
+		ids := leg.ServoIDs()
+		for j, servo := range leg.Servos() {
+			if !res[ids[j]] {
+				continue
+			}
+
+			if l.BrownoutVoltage > 0 {
+				v, err := servo.Voltage()
+				if err != nil {
+					problems = append(problems, &hexapod.ServoError{ids[j], fmt.Errorf("error reading voltage: %w", err)})
+				} else if v < l.BrownoutVoltage {
+					problems = append(problems, &hexapod.ServoError{ids[j], fmt.Errorf("%w: %.2fv", hexapod.ErrLowVoltage, v)})
+				}
+			}
+
+			if th, ok := servo.(thermometer); ok {
+				t, err := th.Temperature()
+				if err != nil {
+					problems = append(problems, &hexapod.ServoError{ids[j], fmt.Errorf("error reading temperature: %w", err)})
+				} else if t > maxSelfTestTemperature {
+					problems = append(problems, &hexapod.ServoError{ids[j], fmt.Errorf("%w: %dC", hexapod.ErrOverheat, t)})
+				}
+			}
+		}
+	}
+
+	if err := l.Validate(); err != nil {
+		problems = append(problems, err)
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("self-test failed: %w", errors.Join(problems...))
+	}
+
+	return nil
+}
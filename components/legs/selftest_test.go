@@ -0,0 +1,81 @@
+package legs
+
+import (
+	"errors"
+	"github.com/adammck/hexapod"
+	"strings"
+	"testing"
+	"time"
+)
+
+// hotServo is a mock servo which can report its temperature.
+type hotServo struct {
+	*mockServo
+	temp int
+}
+
+func (s *hotServo) Temperature() (int, error) {
+	return s.temp, s.err()
+}
+
+func TestSelfTest(t *testing.T) {
+	l, _, m := mockLegs(hexapod.NewHexapod(nil))
+	if err := l.SelfTest(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// Each problem should be listed.
+	m[1][2].absent = true
+	l.BrownoutVoltage = 11
+	m[2][0].voltage = 10.5
+	l.Legs[3].Tarsus = &hotServo{m[3][3], 80}
+
+	err := l.SelfTest()
+	if err == nil {
+		t.Fatalf("expected an error")
+	}
+
+	for _, exp := range []string{
+		"servo not responding: 53 (FR tibia)",
+		"servo #61: low voltage: 10.50v",
+		"servo #14: overheating: 80C",
+	} {
+		if !strings.Contains(err.Error(), exp) {
+			t.Errorf("expected %q in error: %s", exp, err)
+		}
+	}
+
+	// And still be identifiable, rather than flattened into a string.
+	for _, exp := range []error{
+		hexapod.ErrServoMissing,
+		hexapod.ErrLowVoltage,
+		hexapod.ErrOverheat,
+	} {
+		if !errors.Is(err, exp) {
+			t.Errorf("expected error to match %q: %s", exp, err)
+		}
+	}
+
+	var se *hexapod.ServoError
+	if !errors.As(err, &se) || se.ID != 61 {
+		t.Errorf("expected to find ServoError for #61, got %v", se)
+	}
+}
+
+// A servo which doesn't respond should stop the main loop before the legs have
+// even started to initialize, let alone stand up.
+func TestSelfTestPreventsStanding(t *testing.T) {
+	h := hexapod.NewHexapod(nil)
+	l, _, m := mockLegs(h)
+	h.Add(l)
+	m[4][1].absent = true
+
+	err := h.MainLoop(time.Millisecond)
+	if err == nil || !strings.Contains(err.Error(), "servo not responding") {
+		t.Errorf("expected missing servo error, got %v", err)
+	}
+
+	if l.State != StateDefault {
+		t.Errorf("legs are in state %s, expected %s", l.State, StateDefault)
+	}
+}
@@ -0,0 +1,16 @@
+package legs
+
+// Servo is the subset of dynamixel.DynamixelServo which the legs use. It's an
+// interface so that tests (and simulators) can provide their own.
+type Servo interface {
+	Ping() error
+	SetStatusReturnLevel(value int) error
+	SetTorqueEnable(state bool) error
+	SetMovingSpeed(speed int) error
+	SetLed(state bool) error
+	MoveTo(angle float64) error
+	Angle() (float64, error)
+	Voltage() (float64, error)
+	ModelNumber() (int, error)
+	Load() (int, error)
+}
@@ -0,0 +1,28 @@
+package legs
+
+// sitDown lowers the body towards the sitting clearance, along SitDownEasing,
+// so it gets there SitDownDuration after the legs started sitting down. Returns
+// true once it's there.
+func (l *Legs) sitDown() bool {
+	if l.stateCounter <= 1 {
+		l.sitDownFrom = l.baseClearance
+	}
+
+	t := 1.0
+	if l.SitDownDuration > 0 {
+		t = l.StateDuration().Seconds() / l.SitDownDuration.Seconds()
+	}
+
+	if t >= 1 {
+		l.baseClearance = sitDownClearance
+		return true
+	}
+
+	e := l.SitDownEasing
+	if e == nil {
+		e = Linear
+	}
+
+	l.baseClearance = l.sitDownFrom + ((sitDownClearance - l.sitDownFrom) * e(t))
+	return false
+}
@@ -0,0 +1,55 @@
+package legs
+
+import (
+	"github.com/adammck/hexapod"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestSitDownEasing(t *testing.T) {
+	c := &hexapod.FakeClock{T: time.Unix(100, 0)}
+	h := hexapod.NewHexapod(nil)
+	h.Clock = c
+	l := New(h, nil)
+	l.SitDownDuration = time.Second
+	l.baseClearance = 40
+	l.SetState(StateSitDown)
+
+	heights := []float64{}
+	for i := 0; i < 20 && l.State == StateSitDown; i++ {
+		c.Advance(100 * time.Millisecond)
+		l.stateCounter += 1
+		l.tickState()
+		heights = append(heights, l.baseClearance)
+	}
+
+	if len(heights) != 10 || l.State != StateHalt {
+		t.Fatalf("expected to sit down in ten ticks, took %d: %v", len(heights), heights)
+	}
+
+	// Eased in and out, so slow at both ends, and halfway at the midpoint.
+	if math.Abs(heights[4]-20) > 0.0001 {
+		t.Errorf("clearance is %0.2f halfway through, expected 20", heights[4])
+	}
+
+	if 40-heights[0] >= heights[4]-heights[5] || heights[8] >= heights[4]-heights[5] {
+		t.Errorf("expected to move slowest at the start and the end: %v", heights)
+	}
+
+	if heights[9] != sitDownClearance {
+		t.Errorf("finished at %0.2f, expected %0.2f", heights[9], sitDownClearance)
+	}
+}
+
+func TestShutdownSitsDown(t *testing.T) {
+	h := hexapod.NewHexapod(nil)
+	l, _, _ := mockLegs(h)
+	l.SetState(StateStand)
+
+	h.Shutdown = true
+	l.Tick(h.Now())
+	if l.State != StateSitDown {
+		t.Errorf("expected to sit down after shutdown, but in %s", l.State)
+	}
+}
@@ -0,0 +1,85 @@
+package legs
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+
+	// The default distance (in mm) which a planted foot must move before it's
+	// considered to have slipped.
+	defaultSlipThreshold = 10.0
+
+	// The time between slip checks. Each check reads every servo of every planted
+	// foot, so this can't be too frequent.
+	slipCheckInterval = 250 * time.Millisecond
+)
+
+// checkSlip measures the position of each planted foot, and calls OnSlip if any
+// of them have moved (in the world space) since they were planted. Feet which
+// are in the air are forgotten, and remeasured when they land.
+func (l *Legs) checkSlip(now time.Time) {
+	if l.OnSlip == nil || now.Sub(l.slipTime) < slipCheckInterval {
+		return
+	}
+
+	l.slipTime = now
+	world := l.hexapod.World()
+
+	for i, leg := range l.Legs {
+		if !leg.Initialized || !l.planted(i) {
+			l.slipRef[i] = nil
+			continue
+		}
+
+		a, err := l.legAngles(i, now, l.PositionMaxAge)
+		if err != nil {
+			fmt.Printf("leg %s: error measuring foot: %s\n", leg.Name, err)
+			continue
+		}
+
+		w := leg.ForwardKinematics(a).MultiplyByMatrix44(world)
+		if l.slipRef[i] == nil {
+			l.slipRef[i] = &w
+			continue
+		}
+
+		if d := l.slipRef[i].Distance(w); d > l.SlipThreshold {
+			l.OnSlip(i, d)
+
+			// Start again from here, so a single slip is only reported once.
+			l.slipRef[i] = &w
+		}
+	}
+}
+
+// planted returns true if the foot of the given leg is supposed to be on the
+// ground and staying put, i.e. it's down and isn't about to be moved.
+func (l *Legs) planted(i int) bool {
+	return l.feet[i].Y <= l.footDownAt(*l.feet[i]) && l.supporting(i)
+}
+
+// supporting returns true if the given leg is meant to be holding the body up,
+// i.e. it's not being stepped, used as a manipulator, or disabled.
+func (l *Legs) supporting(i int) bool {
+	return !l.swinging(i) && l.inGait(i)
+}
+
+// swinging returns true if the given leg is being stepped. Once the foot has been
+// put down, it's not swinging any more, even if the step isn't finished.
+func (l *Legs) swinging(i int) bool {
+	if l.State == StateStepDown && l.stateCounter >= stepDownCount {
+		return false
+	}
+
+	if l.stepping() {
+		for _, ii := range l.legSet()[l.sLegsIndex] {
+			if ii == i {
+				return true
+			}
+		}
+	}
+
+	return false
+}
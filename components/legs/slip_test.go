@@ -0,0 +1,87 @@
+package legs
+
+import (
+	"github.com/adammck/hexapod"
+	"testing"
+	"time"
+)
+
+func TestCheckSlip(t *testing.T) {
+	h := hexapod.NewHexapod(nil)
+	l, _, m := mockLegs(h)
+	l.SetState(StateStand)
+
+	slips := map[int]float64{}
+	l.OnSlip = func(leg int, d float64) {
+		slips[leg] = d
+	}
+
+	// Plant every foot where it's supposed to be.
+	for _, leg := range l.Legs {
+		leg.Initialized = true
+	}
+
+	l.updateFeet()
+	now := time.Unix(0, 0)
+	l.checkSlip(now)
+
+	// Nothing has moved yet.
+	now = now.Add(slipCheckInterval)
+	l.checkSlip(now)
+	if len(slips) != 0 {
+		t.Errorf("unexpected slips: %v", slips)
+	}
+
+	// Knock the coxa of leg 4 around a bit.
+	before, _ := l.Legs[4].FootPosition()
+	m[4][0].angle += 10
+	after, _ := l.Legs[4].FootPosition()
+	exp := before.Distance(after)
+
+	// Too soon to check again.
+	l.checkSlip(now.Add(slipCheckInterval / 2))
+	if len(slips) != 0 {
+		t.Errorf("checked too soon: %v", slips)
+	}
+
+	now = now.Add(slipCheckInterval)
+	l.checkSlip(now)
+	if len(slips) != 1 || slips[4] < exp-0.0001 || slips[4] > exp+0.0001 {
+		t.Errorf("got slips %v, expected leg 4 to slip %0.2f", slips, exp)
+	}
+
+	// Only reported once.
+	delete(slips, 4)
+	l.checkSlip(now.Add(slipCheckInterval))
+	if len(slips) != 0 {
+		t.Errorf("slip reported twice: %v", slips)
+	}
+}
+
+func TestCheckSlipIgnoresLiftedFeet(t *testing.T) {
+	h := hexapod.NewHexapod(nil)
+	l, _, m := mockLegs(h)
+	l.SetState(StateStand)
+
+	slips := 0
+	l.OnSlip = func(leg int, d float64) {
+		slips += 1
+	}
+
+	for _, leg := range l.Legs {
+		leg.Initialized = true
+	}
+
+	l.updateFeet()
+	now := time.Unix(0, 0)
+	l.checkSlip(now)
+
+	// Lift leg 1 and move it. That's a step, not a slip.
+	l.feet[1].Y = baseFootUp
+	m[1][0].angle += 10
+
+	l.checkSlip(now.Add(slipCheckInterval))
+	if slips != 0 {
+		t.Errorf("lifted foot reported as slip")
+	}
+}
@@ -0,0 +1,103 @@
+package legs
+
+import (
+	"fmt"
+	"github.com/adammck/hexapod/math3d"
+	"time"
+)
+
+// Snapshot is the working state of the legs, as returned by Legs.Snapshot. The
+// positions are in the world space, like the pose of the hexapod which they're
+// saved along with.
+type Snapshot struct {
+	State         State
+	StateCounter  int
+	StateDuration time.Duration
+
+	Feet     [6]math3d.Vector3
+	NextFeet [6]*math3d.Vector3
+
+	// The index of the leg set being stepped, and the gaits.
+	LegSet   int
+	Gait     Gait
+	NextGait Gait
+	CatchUp  bool
+
+	BaseClearance float64
+	SitDownFrom   float64
+	InitCounter   int
+	InitBatches   int
+	Initialized   [6]bool
+	LiftStart     [6]int
+}
+
+// Snapshot returns the current working state of the legs. See hexapod.Snapshot.
+func (l *Legs) Snapshot() interface{} {
+	s := &Snapshot{
+		State:         l.State,
+		StateCounter:  l.stateCounter,
+		StateDuration: l.StateDuration(),
+		LegSet:        l.sLegsIndex,
+		Gait:          l.gait,
+		NextGait:      l.nextGait,
+		CatchUp:       l.catchUp,
+		BaseClearance: l.baseClearance,
+		SitDownFrom:   l.sitDownFrom,
+		InitCounter:   l.initCounter,
+		InitBatches:   l.initBatches,
+		LiftStart:     l.liftStart,
+	}
+
+	for i, leg := range l.Legs {
+		s.Feet[i] = *l.feet[i]
+		s.Initialized[i] = leg.Initialized
+
+		if l.nextFeet[i] != nil {
+			v := *l.nextFeet[i]
+			s.NextFeet[i] = &v
+		}
+	}
+
+	return s
+}
+
+// Restore returns the legs to a state returned by Snapshot. Legs which were
+// initialized are assumed to still be holding their position (which is true
+// when restoring a paused hexapod, or one whose process was restarted without
+// relaxing the servos), so every goal is sent again on the next tick, without
+// standing up first.
+func (l *Legs) Restore(v interface{}) error {
+	s, ok := v.(*Snapshot)
+	if !ok {
+		return fmt.Errorf("not a legs snapshot: %T", v)
+	}
+
+	l.State = s.State
+	l.stateCounter = s.StateCounter
+	l.stateTime = l.hexapod.Now().Add(-s.StateDuration)
+	l.sLegsIndex = s.LegSet
+	l.gait = s.Gait
+	l.nextGait = s.NextGait
+	l.catchUp = s.CatchUp
+	l.baseClearance = s.BaseClearance
+	l.sitDownFrom = s.SitDownFrom
+	l.initCounter = s.InitCounter
+	l.initBatches = s.InitBatches
+	l.liftStart = s.LiftStart
+
+	for i, leg := range l.Legs {
+		f := s.Feet[i]
+		l.feet[i] = &f
+		l.nextFeet[i] = nil
+
+		if s.NextFeet[i] != nil {
+			n := *s.NextFeet[i]
+			l.nextFeet[i] = &n
+		}
+
+		leg.Initialized = s.Initialized[i]
+		leg.ForgetGoals()
+	}
+
+	return nil
+}
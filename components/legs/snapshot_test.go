@@ -0,0 +1,82 @@
+package legs
+
+import (
+	"github.com/adammck/hexapod"
+	"testing"
+	"time"
+)
+
+// A hexapod restored from a snapshot taken mid-step should carry on exactly as
+// the original would have.
+func TestSnapshotRestore(t *testing.T) {
+	newHex := func() (*hexapod.Hexapod, *Legs, *hexapod.FakeClock) {
+		c := &hexapod.FakeClock{T: time.Unix(1000, 0)}
+		h := hexapod.NewHexapod(nil)
+		h.Clock = c
+		l, _, _ := mockLegs(h)
+		h.Add(l)
+		return h, l, c
+	}
+
+	step := func(h *hexapod.Hexapod, c *hexapod.FakeClock) {
+		c.Advance(time.Second / 60)
+		h.Position.Z += 1
+		h.Step(hexapod.InputState{})
+	}
+
+	a, al, ac := newHex()
+	for i := 0; i < 300; i++ {
+		step(a, ac)
+	}
+
+	// Restore into a fresh hexapod, which hasn't even stood up.
+	s := a.Snapshot()
+	b, bl, bc := newHex()
+	bc.T = ac.T
+	if err := b.Restore(s); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	for i := 0; i < 60; i++ {
+		step(a, ac)
+		step(b, bc)
+
+		if bl.State != al.State {
+			t.Fatalf("tick %d: state is %s, expected %s", i+1, bl.State, al.State)
+		}
+
+		for ii := range al.Legs {
+			if g, exp := bl.footGoal(ii), al.footGoal(ii); g != exp {
+				t.Fatalf("tick %d: leg %d goal is %s, expected %s", i+1, ii, g, exp)
+			}
+
+			if !bl.Legs[ii].Initialized {
+				t.Fatalf("tick %d: leg %d isn't initialized", i+1, ii)
+			}
+		}
+	}
+
+	if err := bl.Restore("nope"); err == nil {
+		t.Errorf("expected error restoring something else")
+	}
+
+	// Restoring part way through sitting down carries on lowering the body from
+	// where it was, rather than jumping.
+	a.Shutdown = true
+	for al.State != StateSitDown || al.stateCounter < 5 {
+		step(a, ac)
+	}
+
+	b, bl, bc = newHex()
+	b.Shutdown = true
+	bc.T = ac.T
+	if err := b.Restore(a.Snapshot()); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	step(a, ac)
+	step(b, bc)
+	if bl.baseClearance != al.baseClearance {
+		t.Errorf("clearance is %0.2f, expected %0.2f", bl.baseClearance, al.baseClearance)
+	}
+}
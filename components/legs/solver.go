@@ -0,0 +1,157 @@
+package legs
+
+import (
+	"github.com/adammck/hexapod/math3d"
+	"github.com/adammck/hexapod/utils"
+	"math"
+)
+
+const (
+
+	// The most iterations which the iterative solver will run before giving up,
+	// and how close (in mm) the foot must be to the target to stop early.
+	maxSolverIterations = 200
+	solverTolerance     = 0.001
+
+	// The damping (in mm) of the iterative solver. Higher is slower to converge,
+	// but more stable near singularities, e.g. when the leg is fully extended.
+	solverDamping = 5.0
+)
+
+// Solver selects how the IK of a leg is solved.
+type Solver int
+
+const (
+
+	// SolverClosedForm solves the IK with a bunch of triangles, which is fast,
+	// but assumes that the coxa is the only joint which rotates around the Y
+	// axis, and that the leg is built exactly like the original ones.
+	SolverClosedForm Solver = iota
+
+	// SolverFallback tries the closed form, then the iterative solver if that
+	// fails, e.g. because the target is near the edge of what the closed form
+	// can reach. Legs which the closed form can't solve at all (see Geometry)
+	// always use the iterative solver.
+	SolverFallback
+
+	// SolverIterative always uses the iterative solver, which works for legs of
+	// any Geometry, but is much slower.
+	SolverIterative
+)
+
+// solveIterative returns the angles which place the foot at the given point,
+// with the tarsus pointed along the given vector (see SolveIKWithUp). They're
+// found by damped least squares, i.e. by repeatedly moving the coxa, femur, and
+// tibia in whichever direction the Jacobian says will bring the foot closest to
+// the target. The tarsus just follows the other joints to hold its elevation.
+// Returns ErrUnreachable if the foot doesn't get close enough. If the leg is
+// twisted (see Geometry), so is the elevation of the tarsus.
+func (leg *Leg) solveIterative(p math3d.Vector3, u math3d.Vector3) (JointAngles, error) {
+	out := math3d.Vector3{p.X - leg.Origin.X, 0, p.Z - leg.Origin.Z}
+	if out.Length() < leg.geometry().minReach() {
+		return JointAngles{}, ErrUnreachable
+	}
+
+	// The elevation of the tarsus (from the tibia towards the foot), in degrees
+	// above horizontal. Straight down is -90.
+	out = out.Unit()
+	uo := (u.X * out.X) + (u.Z * out.Z)
+	es := utils.Deg(math.Atan2(-u.Y, -uo))
+
+	fk := func(a JointAngles) math3d.Vector3 {
+		a.Tarsus = (0 - a.Femur - a.Tibia) - es
+		return leg.ForwardKinematics(a)
+	}
+
+	// Start from the coxa pointed at the target, and the tibia vertical, which is
+	// roughly where the legs spend most of their time.
+	a := JointAngles{
+		Coxa:  utils.Deg(math.Atan2(-out.Z, out.X)) - leg.Angle,
+		Tibia: 90,
+	}
+
+	for i := 0; i < maxSolverIterations; i++ {
+		f := fk(a)
+		e := p.Subtract(f)
+		if e.Length() < solverTolerance {
+			a.Tarsus = (0 - a.Femur - a.Tibia) - es
+			return a, nil
+		}
+
+		// The Jacobian, with the tarsus following along.
+		s := 1 / utils.Rad(jacobianDelta)
+		ac := a
+		ac.Coxa += jacobianDelta
+		af := a
+		af.Femur += jacobianDelta
+		at := a
+		at.Tibia += jacobianDelta
+		j := Jacobian{
+			fk(ac).Subtract(f).Scale(s),
+			fk(af).Subtract(f).Scale(s),
+			fk(at).Subtract(f).Scale(s),
+		}
+
+		d := j.dampedInverse(*e, solverDamping)
+		a.Coxa += utils.Deg(d[0])
+		a.Femur += utils.Deg(d[1])
+		a.Tibia += utils.Deg(d[2])
+	}
+
+	return JointAngles{}, ErrUnreachable
+}
+
+// dampedInverse returns the change in joint angles (in radians) which moves the
+// foot by (roughly) the given vector, i.e. J^T (J J^T + λ²I)^-1 v.
+func (j Jacobian) dampedInverse(v math3d.Vector3, damping float64) [3]float64 {
+	col := func(i int) [3]float64 {
+		return [3]float64{j[i].X, j[i].Y, j[i].Z}
+	}
+
+	// J J^T is the sum of the outer product of each column with itself.
+	m := [3][3]float64{}
+	for i := range j {
+		c := col(i)
+		for r := 0; r < 3; r++ {
+			for k := 0; k < 3; k++ {
+				m[r][k] += c[r] * c[k]
+			}
+		}
+	}
+
+	for r := 0; r < 3; r++ {
+		m[r][r] += damping * damping
+	}
+
+	y := solve3(m, [3]float64{v.X, v.Y, v.Z})
+	res := [3]float64{}
+	for i := range j {
+		c := col(i)
+		res[i] = (c[0] * y[0]) + (c[1] * y[1]) + (c[2] * y[2])
+	}
+
+	return res
+}
+
+// solve3 solves m x = b for x, by Cramer's rule. The matrix must not be singular,
+// which the damping guarantees.
+func solve3(m [3][3]float64, b [3]float64) [3]float64 {
+	det := func(m [3][3]float64) float64 {
+		return (m[0][0] * ((m[1][1] * m[2][2]) - (m[1][2] * m[2][1]))) -
+			(m[0][1] * ((m[1][0] * m[2][2]) - (m[1][2] * m[2][0]))) +
+			(m[0][2] * ((m[1][0] * m[2][1]) - (m[1][1] * m[2][0])))
+	}
+
+	d := det(m)
+	x := [3]float64{}
+	for i := range x {
+		mm := m
+		for r := 0; r < 3; r++ {
+			mm[r][i] = b[r]
+		}
+
+		x[i] = det(mm) / d
+	}
+
+	return x
+}
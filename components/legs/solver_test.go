@@ -0,0 +1,130 @@
+package legs
+
+import (
+	"github.com/adammck/hexapod/math3d"
+	"math"
+	"testing"
+)
+
+func TestSolveIterative(t *testing.T) {
+	leg := Leg{
+		Origin: &math3d.Vector3{61.167, 24, 98},
+		Angle:  -120,
+		Solver: SolverIterative,
+	}
+
+	data := []math3d.Vector3{
+		math3d.Vector3{180, -80, 0},
+		math3d.Vector3{150, -80, -60},
+		math3d.Vector3{200, -40, 30},
+	}
+
+	for i, d := range data {
+		target := *leg.Origin.Add(d.RotateY(leg.Angle))
+		coxa, femur, tibia, tarsus, err := leg.SolveIK(target)
+		if err != nil {
+			t.Errorf("Example #%d: unexpected error: %s", i+1, err)
+			continue
+		}
+
+		a := JointAngles{coxa, femur, tibia, tarsus}
+		if p := leg.ForwardKinematics(a); p.Distance(target) > 0.01 {
+			t.Errorf("Example #%d: foot would be at %s, expected %s", i+1, p, target)
+		}
+
+		// Should find the same pose as the closed form.
+		c, f, tb, ts, _ := leg.solveClosedForm(target, up)
+		exp := JointAngles{c, f, tb, ts}
+		for j, v := range [4]float64{a.Coxa - exp.Coxa, a.Femur - exp.Femur, a.Tibia - exp.Tibia, a.Tarsus - exp.Tarsus} {
+			if math.Abs(v) > 0.01 {
+				t.Errorf("Example #%d: got %s=%0.4f, expected %+v", i+1, jointNames[j], v, exp)
+			}
+		}
+	}
+}
+
+// A leg whose femur servo is mounted to the side of the coxa, and twisted, so
+// the closed form can't solve it.
+func TestSolveIterativeGeometry(t *testing.T) {
+	g := OriginalGeometry
+	g.CoxaLength = 45
+	g.TibiaLength = 95
+	g.CoxaOffset = 20
+	g.Twist = 15
+
+	leg := Leg{
+		Origin:   &math3d.Vector3{61.167, 24, 98},
+		Angle:    -120,
+		Solver:   SolverIterative,
+		Geometry: &g,
+	}
+
+	data := []math3d.Vector3{
+		math3d.Vector3{180, -80, 0},
+		math3d.Vector3{150, -80, -60},
+		math3d.Vector3{200, -40, 30},
+	}
+
+	for i, d := range data {
+		target := *leg.Origin.Add(d.RotateY(leg.Angle))
+		coxa, femur, tibia, tarsus, err := leg.SolveIK(target)
+		if err != nil {
+			t.Errorf("Example #%d: unexpected error: %s", i+1, err)
+			continue
+		}
+
+		if p := leg.ForwardKinematics(JointAngles{coxa, femur, tibia, tarsus}); p.Distance(target) > 0.01 {
+			t.Errorf("Example #%d: foot would be at %s, expected %s", i+1, p, target)
+		}
+
+		// The closed form misses, if it finds anything at all.
+		c, f, tb, ts, err := leg.solveClosedForm(target, up)
+		if p := leg.ForwardKinematics(JointAngles{c, f, tb, ts}); err == nil && p.Distance(target) < 1 {
+			t.Errorf("Example #%d: expected the closed form to miss, but it's at %s", i+1, p)
+		}
+
+		// The fallback doesn't bother with it.
+		leg.Solver = SolverFallback
+		c, f, tb, ts, err = leg.SolveIK(target)
+		leg.Solver = SolverIterative
+		if p := leg.ForwardKinematics(JointAngles{c, f, tb, ts}); err != nil || p.Distance(target) > 0.01 {
+			t.Errorf("Example #%d: fallback put foot at %s (err=%v), expected %s", i+1, p, err, target)
+		}
+	}
+}
+
+func TestSolveIterativeUnreachable(t *testing.T) {
+	leg := Leg{
+		Origin: &math3d.Vector3{0, 0, 0},
+		Solver: SolverIterative,
+	}
+
+	for i, target := range []math3d.Vector3{{1000, 0, 0}, {10, -80, 10}} {
+		if _, _, _, _, err := leg.SolveIK(target); err != ErrUnreachable {
+			t.Errorf("Example #%d: got %v, expected ErrUnreachable", i+1, err)
+		}
+	}
+}
+
+func TestSolverFallback(t *testing.T) {
+	leg := Leg{
+		Origin: &math3d.Vector3{0, 0, 0},
+		Solver: SolverFallback,
+	}
+
+	target := math3d.Vector3{150, -80, 0}
+	_, femur, tibia, tarsus, err := leg.SolveIKWithUp(target, math3d.Vector3{-1, 1, 0})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// The tarsus should lean out, by 45 degrees.
+	if es := 0 - femur - tibia - tarsus; math.Abs(es+45) > 0.01 {
+		t.Errorf("tarsus is at %0.4f deg, expected -45", es)
+	}
+
+	// Neither solver can reach this.
+	if _, _, _, _, err := leg.SolveIK(math3d.Vector3{1000, 0, 0}); err != ErrUnreachable {
+		t.Errorf("got %v, expected ErrUnreachable", err)
+	}
+}
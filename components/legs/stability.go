@@ -0,0 +1,132 @@
+package legs
+
+import (
+	"github.com/adammck/hexapod/math3d"
+	"math"
+	"sort"
+)
+
+// StabilityMargin returns the distance (in mm, on the X/Z plane) between the
+// center of mass and the nearest edge of the support polygon formed by the feet
+// which are currently on the ground. This is positive while the center of mass
+// is inside the polygon, and negative when it's outside (i.e. tipping over).
+//
+// The center of mass is assumed to be at the origin of the hexapod. That isn't
+// quite true, but the battery is in the middle and the legs are light-ish.
+func (l *Legs) StabilityMargin() float64 {
+	return supportMargin(l.hexapod.Position, l.plantedFeet(noManipulator))
+}
+
+// plantedFeet returns the positions of the planted feet, in the WORLD space,
+// except the given leg.
+func (l *Legs) plantedFeet(except int) []math3d.Vector3 {
+	planted := []math3d.Vector3{}
+	for i, foot := range l.feet {
+		if i != except && l.planted(i) {
+			planted = append(planted, *foot)
+		}
+	}
+
+	return planted
+}
+
+// IsStable returns true if the stability margin is at least MinStabilityMargin,
+// i.e. the hexapod is in no danger of tipping over. The margin is returned too.
+func (l *Legs) IsStable() (bool, float64) {
+	m := l.StabilityMargin()
+	return (m >= l.MinStabilityMargin), m
+}
+
+// supportMargin returns the signed distance (on the X/Z plane) from the given
+// point to the edge of the convex hull of the given feet. Fewer than three feet
+// can't support anything, so the margin is negative infinity.
+func supportMargin(com math3d.Vector3, feet []math3d.Vector3) float64 {
+	hull := convexHull(feet)
+	if len(hull) < 3 {
+		return math.Inf(-1)
+	}
+
+	inside := true
+	dist := math.Inf(1)
+
+	for i := range hull {
+		a := hull[i]
+		b := hull[(i+1)%len(hull)]
+
+		// The hull is counter-clockwise on X/Z, so the point is inside while it's
+		// on the left of every edge.
+		if cross(a, b, com) < 0 {
+			inside = false
+		}
+
+		dist = math.Min(dist, segmentDistance(a, b, com))
+	}
+
+	if !inside {
+		return -dist
+	}
+
+	return dist
+}
+
+// convexHull returns the points (projected onto the X/Z plane) which form the
+// convex hull of the given points, in counter-clockwise order. This is Andrew's
+// monotone chain algorithm.
+func convexHull(points []math3d.Vector3) []math3d.Vector3 {
+	if len(points) < 3 {
+		return points
+	}
+
+	p := make([]math3d.Vector3, len(points))
+	copy(p, points)
+	sort.Slice(p, func(i, j int) bool {
+		if p[i].X == p[j].X {
+			return p[i].Z < p[j].Z
+		}
+
+		return p[i].X < p[j].X
+	})
+
+	hull := make([]math3d.Vector3, 0, len(p)*2)
+
+	// Lower hull
+	for _, v := range p {
+		for len(hull) >= 2 && cross(hull[len(hull)-2], hull[len(hull)-1], v) <= 0 {
+			hull = hull[:len(hull)-1]
+		}
+
+		hull = append(hull, v)
+	}
+
+	// Upper hull
+	for i, t := len(p)-2, len(hull)+1; i >= 0; i-- {
+		for len(hull) >= t && cross(hull[len(hull)-2], hull[len(hull)-1], p[i]) <= 0 {
+			hull = hull[:len(hull)-1]
+		}
+
+		hull = append(hull, p[i])
+	}
+
+	return hull[:len(hull)-1]
+}
+
+// cross returns the Z component of the cross product of OA and OB, projected
+// onto the X/Z plane. It's positive if O->A->B is a counter-clockwise turn.
+func cross(o, a, b math3d.Vector3) float64 {
+	return ((a.X - o.X) * (b.Z - o.Z)) - ((a.Z - o.Z) * (b.X - o.X))
+}
+
+// segmentDistance returns the distance (on the X/Z plane) between the point p
+// and the line segment from a to b.
+func segmentDistance(a, b, p math3d.Vector3) float64 {
+	dx := b.X - a.X
+	dz := b.Z - a.Z
+	l := (dx * dx) + (dz * dz)
+
+	t := 0.0
+	if l > 0 {
+		t = math.Max(0, math.Min(1, (((p.X-a.X)*dx)+((p.Z-a.Z)*dz))/l))
+	}
+
+	return math.Hypot(p.X-(a.X+(t*dx)), p.Z-(a.Z+(t*dz)))
+}
@@ -0,0 +1,83 @@
+package legs
+
+import (
+	"github.com/adammck/hexapod"
+	"github.com/adammck/hexapod/math3d"
+	"math"
+	"testing"
+)
+
+func TestStabilityMarginHome(t *testing.T) {
+	l := New(hexapod.NewHexapod(nil), nil)
+
+	// With all six feet at home, the support polygon is (roughly) a regular
+	// hexagon, so the margin is its apothem.
+	exp := stepRadius * math.Cos(math.Pi/6)
+	if m := l.StabilityMargin(); math.Abs(m-exp) > 2 {
+		t.Errorf("got %0.2f, expected about %0.2f", m, exp)
+	}
+
+	if ok, _ := l.IsStable(); !ok {
+		t.Errorf("expected to be stable at home")
+	}
+}
+
+func TestStabilityMarginLifted(t *testing.T) {
+	h := hexapod.NewHexapod(nil)
+	l := New(h, nil)
+
+	// Lifting a tripod leaves a triangle, which is still stable.
+	for _, i := range []int{0, 2, 4} {
+		l.feet[i].Y = baseFootUp
+	}
+
+	if ok, m := l.IsStable(); !ok {
+		t.Errorf("expected to be stable on a tripod, margin=%0.2f", m)
+	}
+
+	// But shifting the body well forward isn't.
+	h.Position = math3d.Vector3{0, 0, 150}
+	if ok, m := l.IsStable(); ok || m >= 0 {
+		t.Errorf("expected to be unstable, margin=%0.2f", m)
+	}
+}
+
+func TestStabilityMarginTooFewFeet(t *testing.T) {
+	l := New(hexapod.NewHexapod(nil), nil)
+
+	for i := 0; i < 4; i++ {
+		l.feet[i].Y = baseFootUp
+	}
+
+	if ok, m := l.IsStable(); ok || !math.IsInf(m, -1) {
+		t.Errorf("expected to be unstable with two feet, margin=%0.2f", m)
+	}
+}
+
+func TestSupportMargin(t *testing.T) {
+	square := []math3d.Vector3{
+		math3d.Vector3{-10, 0, -10},
+		math3d.Vector3{10, 0, -10},
+		math3d.Vector3{10, 0, 10},
+		math3d.Vector3{-10, 0, 10},
+		math3d.Vector3{0, 0, 0}, // inside, so not part of the hull
+	}
+
+	type example struct {
+		com math3d.Vector3
+		exp float64
+	}
+
+	data := []example{
+		example{math3d.Vector3{0, 0, 0}, 10},
+		example{math3d.Vector3{5, 99, 0}, 5},
+		example{math3d.Vector3{0, 0, 15}, -5},
+		example{math3d.Vector3{13, 0, 14}, -5},
+	}
+
+	for i, eg := range data {
+		if m := supportMargin(eg.com, square); math.Abs(m-eg.exp) > 0.000001 {
+			t.Errorf("Example #%d: got %0.4f, expected %0.4f", i+1, m, eg.exp)
+		}
+	}
+}
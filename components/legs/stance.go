@@ -0,0 +1,82 @@
+package legs
+
+import (
+	"fmt"
+)
+
+// Stance is a bundle of gait parameters which work well together. Changing them
+// one at a time is fiddly, and it's easy to end up somewhere unstable, or where
+// the legs can't reach.
+type Stance struct {
+	Clearance    float64
+	StepHeight   float64
+	StanceRadius float64
+	StrideRadius float64
+}
+
+// Stances are the named stances which can be selected with SetStance. The low
+// stance is wide and stable, for rough ground. The tall stance lifts the body
+// and feet higher, to clear obstacles, but has to keep the feet closer in.
+var Stances = map[string]Stance{
+	"normal": Stance{standUpClearance, baseFootUp, stepRadius, stepRadius},
+	"low":    Stance{20, 30, 240, 240},
+	"tall":   Stance{70, 60, 190, 190},
+}
+
+// SetStance applies the named stance from Stances. If the legs are standing,
+// the body is raised or lowered gradually, and the feet step to the new radius
+// as usual.
+func (l *Legs) SetStance(name string) error {
+	s, ok := Stances[name]
+	if !ok {
+		return fmt.Errorf("unknown stance: %s", name)
+	}
+
+	l.setStance(s)
+	return nil
+}
+
+// stance returns the current stance, which might not be one of Stances.
+func (l *Legs) stance() Stance {
+	return Stance{l.StandClearance, l.StepHeight, l.StanceRadius, l.StrideRadius}
+}
+
+// setStance applies the given stance.
+func (l *Legs) setStance(s Stance) {
+	l.StandClearance = s.Clearance
+	l.StepHeight = s.StepHeight
+	l.StanceRadius = s.StanceRadius
+	l.StrideRadius = s.StrideRadius
+}
+
+// adjustClearance moves the clearance one step towards StandClearance, or (more
+// slowly) towards CrouchClearance while crouched.
+func (l *Legs) adjustClearance() {
+	target, step := l.StandClearance, clearanceStep
+	if l.crouched && l.CrouchClearance < target {
+		target, step = l.CrouchClearance, crouchStep
+	}
+
+	d := target - l.baseClearance
+
+	switch {
+	case d > step:
+		l.baseClearance += step
+	case d < -step:
+		l.baseClearance -= step
+	default:
+		l.baseClearance = target
+	}
+}
+
+// SetCrouched lowers the body to CrouchClearance while standing, to rest the
+// servos, or raises it back to StandClearance. The body is lowered slowly, but
+// raised at the usual speed, to respond quickly to input.
+func (l *Legs) SetCrouched(crouched bool) {
+	l.crouched = crouched
+}
+
+// Crouched returns true if the body has been told to crouch. See SetCrouched.
+func (l *Legs) Crouched() bool {
+	return l.crouched
+}
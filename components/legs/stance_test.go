@@ -0,0 +1,113 @@
+package legs
+
+import (
+	"github.com/adammck/hexapod"
+	"github.com/adammck/hexapod/math3d"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestSetStance(t *testing.T) {
+	l := New(hexapod.NewHexapod(nil), nil)
+
+	if err := l.SetStance("tall"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	exp := Stances["tall"]
+	if l.StandClearance != exp.Clearance || l.StepHeight != exp.StepHeight || l.StanceRadius != exp.StanceRadius || l.StrideRadius != exp.StrideRadius {
+		t.Errorf("tall stance not applied: %+v", l)
+	}
+
+	if err := l.SetStance("wat"); err == nil {
+		t.Errorf("expected error for unknown stance")
+	}
+
+	if l.StandClearance != exp.Clearance {
+		t.Errorf("unknown stance changed clearance to %0.1f", l.StandClearance)
+	}
+}
+
+func TestStancesReachable(t *testing.T) {
+	for name, s := range Stances {
+		l := New(hexapod.NewHexapod(nil), nil)
+		l.SetStance(name)
+
+		for _, leg := range l.Legs {
+			for _, y := range []float64{0, s.StepHeight} {
+				for _, p := range []*math3d.Vector3{l.homeFootPosition(leg), l.footfallPosition(leg)} {
+					p.Y = y - s.Clearance
+					if !leg.CanReach(*p) {
+						t.Errorf("stance %s: leg %s can't reach %s", name, leg.Name, p)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestAdjustClearance(t *testing.T) {
+	l := New(hexapod.NewHexapod(nil), nil)
+	l.baseClearance = standUpClearance
+	l.SetStance("low")
+
+	for i := 0; i < 100; i++ {
+		l.adjustClearance()
+	}
+
+	if c := l.Clearance(); c != Stances["low"].Clearance {
+		t.Errorf("clearance is %0.1f, expected %0.1f", c, Stances["low"].Clearance)
+	}
+}
+
+func TestSitDownIgnoresStance(t *testing.T) {
+	c := &hexapod.FakeClock{T: time.Unix(100, 0)}
+	h := hexapod.NewHexapod(nil)
+	h.Clock = c
+	l, _, _ := mockLegs(h)
+	l.baseClearance = l.StandClearance
+	l.SetState(StateSitDown)
+
+	for i := 0; i < 1000 && l.State == StateSitDown; i++ {
+		c.Advance(time.Second / 60)
+
+		// Fiddle with everything which the operator can change at runtime.
+		l.SetStance([]string{"tall", "low", "normal"}[i%3])
+		l.StepHeight = 1000
+
+		l.stateCounter += 1
+		l.tickState()
+	}
+
+	if l.State != StateHalt {
+		t.Errorf("never sat down: state=%s, clearance=%0.1f", l.State, l.Clearance())
+	}
+}
+
+func TestCrouch(t *testing.T) {
+	l, _, _ := mockLegs(hexapod.NewHexapod(nil))
+	l.baseClearance = l.StandClearance
+	l.SetState(StateStand)
+	l.SetCrouched(true)
+
+	// Should take a while to get down.
+	ticks := 0
+	for ; ticks < 1000 && l.Clearance() > l.CrouchClearance; ticks++ {
+		l.adjustClearance()
+	}
+
+	if exp := int((l.StandClearance - l.CrouchClearance) / crouchStep); ticks < exp-1 || ticks > exp+1 {
+		t.Errorf("crouched in %d ticks, expected %d", ticks, exp)
+	}
+
+	// And much less time to get back up.
+	l.SetCrouched(false)
+	for ticks = 0; ticks < 1000 && l.Clearance() < l.StandClearance; ticks++ {
+		l.adjustClearance()
+	}
+
+	if exp := int(math.Ceil((l.StandClearance - l.CrouchClearance) / clearanceStep)); ticks != exp {
+		t.Errorf("stood up in %d ticks, expected %d", ticks, exp)
+	}
+}
@@ -0,0 +1,107 @@
+package legs
+
+import (
+	"math"
+)
+
+// State is the state which the legs are in. See Legs.Tick.
+type State string
+
+const (
+	StateDefault  State = ""
+	StateInit     State = "init"
+	StateHalt     State = "halt"
+	StateStandUp  State = "standUp"
+	StateSitDown  State = "sitDown"
+	StateStand    State = "stand"
+	StateStepUp   State = "stepUp"
+	StateStepOver State = "stepOver"
+	StateStepDown State = "stepDown"
+)
+
+// HaltBehavior is what the legs do with their servos once they've halted.
+type HaltBehavior int
+
+const (
+
+	// Disable the torque of every servo, so the legs go limp. This is the
+	// default, since it saves the battery and the servos.
+	HaltRelax HaltBehavior = iota
+
+	// Keep the torque enabled, so the body stays rigid in the sitting pose. This
+	// is useful on slopes, where relaxing makes it slump.
+	HaltHold
+)
+
+// AllStates returns every valid state, in roughly the order that they're
+// passed through.
+func AllStates() []State {
+	return []State{
+		StateDefault,
+		StateInit,
+		StateStandUp,
+		StateStand,
+		StateStepUp,
+		StateStepOver,
+		StateStepDown,
+		StateSitDown,
+		StateHalt,
+	}
+}
+
+// IsValid returns true if the state is one of AllStates.
+func (s State) IsValid() bool {
+	for _, ss := range AllStates() {
+		if s == ss {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s State) String() string {
+	if s == StateDefault {
+		return "default"
+	}
+
+	return string(s)
+}
+
+// StateProgress returns how far (from zero to one) the legs are through the
+// current state, for showing progress. Steady states (e.g. standing) which only
+// end when something else happens return -1.
+func (l *Legs) StateProgress() float64 {
+	var p float64
+
+	switch l.State {
+	case StateInit:
+		p = float64(l.initCounter) / float64(len(l.Legs))
+
+	case StateStandUp:
+		p = (l.baseClearance - sitDownClearance) / (l.StandClearance - sitDownClearance)
+
+	case StateSitDown:
+		p = 1 - ((l.baseClearance - sitDownClearance) / (l.StandClearance - sitDownClearance))
+
+	case StateStepUp:
+		p = float64(l.stateCounter) / stepUpCount
+
+	case StateStepOver:
+		p = float64(l.stateCounter) / stepOverCount
+
+	case StateStepDown:
+		p = float64(l.stateCounter) / float64(stepDownCount+l.stancePause())
+
+	default:
+		return -1
+	}
+
+	return math.Max(0, math.Min(1, p))
+}
+
+// Active returns true if the legs are moving the body around (i.e. stepping,
+// standing up, or sitting down), which draws the most current.
+func (l *Legs) Active() bool {
+	return l.stepping() || l.State == StateStandUp || l.State == StateSitDown
+}
@@ -0,0 +1,143 @@
+package legs
+
+import (
+	"github.com/adammck/hexapod"
+	"testing"
+	"time"
+)
+
+func TestStateString(t *testing.T) {
+	seen := map[string]bool{}
+	for _, s := range AllStates() {
+		if !s.IsValid() {
+			t.Errorf("state %s isn't valid", s)
+		}
+
+		str := s.String()
+		if str == "" || seen[str] {
+			t.Errorf("state %#v has a blank or duplicate name: %q", s, str)
+		}
+
+		seen[str] = true
+	}
+
+	if State("wat").IsValid() {
+		t.Errorf("unknown state is valid")
+	}
+}
+
+func TestStateCallbacks(t *testing.T) {
+	h := hexapod.NewHexapod(nil)
+	l, _, _ := mockLegs(h)
+
+	enter := map[State]int{}
+	exit := map[State]int{}
+	ticks := 0
+	l.OnEnterState = func(s State) { enter[s] += 1 }
+	l.OnExitState = func(s State) { exit[s] += 1 }
+	l.OnState = map[State]func(){
+		StateStand: func() {
+			ticks += 1
+
+			// Not allowed.
+			l.SetState(StateHalt)
+		},
+	}
+
+	l.SetState(StateStand)
+	l.SetState(StateStand)
+	for i := 0; i < 3; i++ {
+		l.Tick(time.Now())
+	}
+
+	if enter[StateStand] != 1 || exit[StateDefault] != 1 {
+		t.Errorf("expected one transition from default to stand, got enter=%v exit=%v", enter, exit)
+	}
+
+	if ticks != 3 {
+		t.Errorf("expected stand callback to run 3 times, got %d", ticks)
+	}
+
+	if l.State != StateStand {
+		t.Errorf("callback changed state to %s", l.State)
+	}
+}
+
+func TestHaltBehavior(t *testing.T) {
+	for _, b := range []HaltBehavior{HaltRelax, HaltHold} {
+		l, _, m := mockLegs(hexapod.NewHexapod(nil))
+		l.HaltBehavior = b
+		for _, leg := range m {
+			for _, s := range leg {
+				s.torque = true
+				s.led = true
+			}
+		}
+
+		l.SetState(StateHalt)
+		if err := l.tickState(); err != hexapod.ErrHalted {
+			t.Errorf("behavior %d: expected ErrHalted, got %v", b, err)
+		}
+
+		exp := (b == HaltHold)
+		for i, leg := range m {
+			for j, s := range leg {
+				if s.torque != exp || s.led {
+					t.Errorf("behavior %d: leg %d servo %d: torque=%v led=%v, expected torque=%v led=false", b, i, j, s.torque, s.led, exp)
+				}
+			}
+		}
+	}
+}
+
+func TestStateProgress(t *testing.T) {
+	l := New(hexapod.NewHexapod(nil), nil)
+
+	for _, s := range []State{StateDefault, StateStand, StateHalt} {
+		l.SetState(s)
+		if p := l.StateProgress(); p != -1 {
+			t.Errorf("%s: got progress %0.2f, expected -1", s, p)
+		}
+	}
+
+	l.SetState(StateStandUp)
+	last := -1.0
+	for l.State == StateStandUp {
+		p := l.StateProgress()
+		if p < last || p < 0 || p > 1 {
+			t.Fatalf("standing up: progress went from %0.2f to %0.2f", last, p)
+		}
+
+		last = p
+		l.stateCounter += 1
+		l.tickState()
+	}
+
+	if last < 0.9 {
+		t.Errorf("standing up: expected progress to approach 1, got %0.2f", last)
+	}
+
+	l.SetState(StateStepUp)
+	l.stateCounter = stepUpCount / 2
+	if p := l.StateProgress(); p != 0.5 {
+		t.Errorf("stepping up: got %0.2f, expected 0.5", p)
+	}
+}
+
+func TestActive(t *testing.T) {
+	l := New(hexapod.NewHexapod(nil), nil)
+	exp := map[State]bool{
+		StateStandUp:  true,
+		StateSitDown:  true,
+		StateStepUp:   true,
+		StateStepOver: true,
+		StateStepDown: true,
+	}
+
+	for _, s := range AllStates() {
+		l.SetState(s)
+		if a := l.Active(); a != exp[s] {
+			t.Errorf("%s: got %v, expected %v", s, a, exp[s])
+		}
+	}
+}
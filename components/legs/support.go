@@ -0,0 +1,142 @@
+package legs
+
+import (
+	"fmt"
+	"github.com/adammck/hexapod/math3d"
+)
+
+// SupportGait is implemented by gaits which need more feet to stay on the
+// ground than the legs would otherwise insist on. See MinSupport.
+type SupportGait interface {
+	Gait
+	MinSupport() int
+}
+
+// trotGait is a legSetGait which requires a minimum number of feet planted.
+type trotGait struct {
+	legSetGait
+	minSupport int
+}
+
+func (g *trotGait) MinSupport() int {
+	return g.minSupport
+}
+
+// NewTrotGait returns a trot, in which each pair of diagonal legs steps along
+// with the middle leg on the side of its back foot. It's as fast as the tripod,
+// but the body is balanced on the edge of the feet which stay down while each
+// set is in the air, so it relies on its momentum to carry it over. The legs
+// will refuse to lift a set if it would leave fewer than the given number of
+// feet on the ground, e.g. because a leg has been disabled, and step one leg at
+// a time instead (see supported).
+func NewTrotGait(minSupport int) Gait {
+	return &trotGait{
+		legSetGait{"trot", [][]int{
+			[]int{0, 3, 5},
+			[]int{1, 4, 2},
+		}},
+		minSupport,
+	}
+}
+
+// TrotGait is a trot which keeps at least three feet on the ground.
+var TrotGait = NewTrotGait(3)
+
+// minSupport returns the fewest feet which must be left on the ground when a
+// leg set is lifted. That's the most of MinSupport and the gait's own minimum,
+// but never less than one, since the hexapod can't recover from leaving the
+// ground entirely.
+func (l *Legs) minSupport() int {
+	n := 1
+	if l.MinSupport > n {
+		n = l.MinSupport
+	}
+
+	if g, ok := l.gait.(SupportGait); ok && g.MinSupport() > n {
+		n = g.MinSupport()
+	}
+
+	return n
+}
+
+// supported returns true if lifting the current leg set would leave at least
+// minSupport feet on the ground. If not, but lifting one leg at a time would,
+// the legs start catching up that way, from the first leg of the gait, until
+// the end of the cycle. Otherwise they're stranded, and the body is held back
+// (see holdBack) until it's safe to step again. The operator is warned (once).
+func (l *Legs) supported() bool {
+	down := 0
+	for i := range l.Legs {
+		if l.inGait(i) {
+			down += 1
+		}
+	}
+
+	n, min := down, l.minSupport()
+	for _, ii := range l.legSet()[l.sLegsIndex] {
+		if l.inGait(ii) {
+			n -= 1
+		}
+	}
+
+	if n >= min {
+		if !l.catchUp {
+			l.supportWarned = false
+		}
+
+		l.stranded = false
+		return true
+	}
+
+	if !l.catchUp && down-1 >= min {
+		l.warnSupport(fmt.Sprintf("lifting leg set %d would leave %d feet down (min %d); stepping one leg at a time", l.sLegsIndex, n, min))
+		l.catchUp = true
+		l.sLegsIndex = 0
+		l.stranded = false
+		return true
+	}
+
+	l.warnSupport(fmt.Sprintf("lifting leg set %d would leave %d feet down (min %d); refusing to step", l.sLegsIndex, n, min))
+	l.stranded = true
+	return false
+}
+
+// holdBack returns an error if the legs are stranded (see supported), and the
+// body moving to the given position would leave it less stable than it is now,
+// and below MinStabilityMargin, since the feet can't step to follow it. Moving
+// back over the feet is always allowed.
+func (l *Legs) holdBack(position math3d.Vector3) error {
+	if !l.stranded {
+		return nil
+	}
+
+	feet := l.plantedFeet(noManipulator)
+	m := supportMargin(position, feet)
+	if m < l.MinStabilityMargin && m < supportMargin(l.hexapod.Position, feet) {
+		return fmt.Errorf("legs can't step; holding the body back (margin %0.1f mm)", m)
+	}
+
+	return nil
+}
+
+// catchUpSets returns each leg in the gait on its own, in the order that the
+// gait would step them. See supported.
+func (l *Legs) catchUpSets() [][]int {
+	res := [][]int{}
+	for _, set := range l.gait.LegSets() {
+		for _, ii := range set {
+			if l.inGait(ii) {
+				res = append(res, []int{ii})
+			}
+		}
+	}
+
+	return res
+}
+
+func (l *Legs) warnSupport(msg string) {
+	if !l.supportWarned {
+		fmt.Printf("WARNING: %s\n", msg)
+		l.supportWarned = true
+	}
+}
@@ -0,0 +1,170 @@
+package legs
+
+import (
+	"github.com/adammck/hexapod"
+	"testing"
+	"time"
+)
+
+// walk moves the body forwards at the given speed (in mm per tick) for n ticks
+// of 10ms, and returns the fewest feet which were on the ground at once.
+func walk(t *testing.T, l *Legs, c *hexapod.FakeClock, speed float64, n int) int {
+	fewest := len(l.Legs)
+	for i := 0; i < n; i++ {
+		l.hexapod.Position.Z += speed
+		l.stateCounter += 1
+		if err := l.tickState(); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		down := 0
+		for ii, f := range l.feet {
+			if l.inGait(ii) && f.Y <= l.stepDownPosition() {
+				down += 1
+			}
+		}
+
+		if down < fewest {
+			fewest = down
+		}
+
+		c.Advance(10 * time.Millisecond)
+	}
+
+	return fewest
+}
+
+func TestTrotMinSupport(t *testing.T) {
+	c := &hexapod.FakeClock{T: time.Unix(100, 0)}
+	h := hexapod.NewHexapod(nil)
+	h.Clock = c
+	l := New(h, nil)
+	l.SetState(StateStand)
+	l.SetGait(TrotGait)
+
+	// As fast as the legs can go: no pause between sets.
+	l.DutyFactor = l.MinDutyFactor()
+	l.SettleDelay = 0
+
+	if n := walk(t, l, c, 2, 500); n < 3 {
+		t.Errorf("only %d feet down while trotting, expected at least 3", n)
+	}
+}
+
+// farthestFoot returns the horizontal distance (in mm) of the foot which is
+// farthest from its home position.
+func farthestFoot(l *Legs) float64 {
+	d := 0.0
+	for i, leg := range l.Legs {
+		if !l.inGait(i) {
+			continue
+		}
+
+		h := l.homeFootPosition(leg)
+		h.Y = l.feet[i].Y
+		if dd := l.feet[i].Distance(*h); dd > d {
+			d = dd
+		}
+	}
+
+	return d
+}
+
+func TestTrotSets(t *testing.T) {
+	sets := TrotGait.LegSets()
+	if len(sets) != 2 {
+		t.Fatalf("trot has %d sets, expected 2 (like the tripod)", len(sets))
+	}
+
+	// Each set lifts a diagonal pair, and a middle leg.
+	diag := [][]int{{0, 3}, {1, 4}}
+	for i, set := range sets {
+		if len(set) != 3 || set[0] != diag[i][0] || set[1] != diag[i][1] {
+			t.Errorf("set %d is %v, expected diagonal pair %v and a middle leg", i, set, diag[i])
+		}
+	}
+}
+
+func TestTrotRecovers(t *testing.T) {
+	c := &hexapod.FakeClock{T: time.Unix(100, 0)}
+	h := hexapod.NewHexapod(nil)
+	h.Clock = c
+	l := New(h, nil)
+	l.SetState(StateStand)
+	l.SetGait(TrotGait)
+
+	// Faster than the feet can keep up with, and then stop. The feet must catch
+	// up with the body, rather than refusing to step.
+	walk(t, l, c, 8, 100)
+	walk(t, l, c, 0, 500)
+	if d := farthestFoot(l); d > minStepDistance {
+		t.Errorf("foot %0.1f mm from home after stopping", d)
+	}
+}
+
+func TestMinSupportCatchesUp(t *testing.T) {
+	c := &hexapod.FakeClock{T: time.Unix(100, 0)}
+	h := hexapod.NewHexapod(nil)
+	h.Clock = c
+	l := New(h, nil)
+	l.SetState(StateStand)
+	l.SetGait(TrotGait)
+
+	// Each set of the trot lifts three legs, so can't leave five down. The legs
+	// step one at a time instead.
+	l.MinSupport = 5
+	if n := walk(t, l, c, 1, 300); n < 5 {
+		t.Errorf("%d feet down, expected at least 5", n)
+	}
+
+	walk(t, l, c, 0, 500)
+	if d := farthestFoot(l); d > minStepDistance {
+		t.Errorf("foot %0.1f mm from home after stopping", d)
+	}
+
+	// Once the legs are allowed to, they trot again.
+	l.MinSupport = 0
+	if n := walk(t, l, c, 1, 100); n != 3 {
+		t.Errorf("%d feet down, expected to trot", n)
+	}
+}
+
+func TestMinSupportRefusesToStep(t *testing.T) {
+	c := &hexapod.FakeClock{T: time.Unix(100, 0)}
+	h := hexapod.NewHexapod(nil)
+	h.Clock = c
+	l := New(h, nil)
+	h.Add(l)
+	l.SetState(StateStand)
+	l.SetGait(TrotGait)
+
+	// Not even a single leg can be lifted.
+	l.MinSupport = 6
+	if n := walk(t, l, c, 1, 100); n != 6 {
+		t.Errorf("%d feet down, expected every foot to stay down", n)
+	}
+
+	if l.State != StateStand {
+		t.Errorf("in state %s, expected %s", l.State, StateStand)
+	}
+
+	// The feet can't follow the body any further, so it's held back before it
+	// gets too close to the edge of them. It can still move back, though.
+	l.MinStabilityMargin = 80
+	p := h.Position
+	for i := 0; i < 100; i++ {
+		p.Z += 1
+		if err := h.SetPose(p, 0); err != nil {
+			break
+		}
+	}
+
+	if m := l.StabilityMargin(); m < l.MinStabilityMargin {
+		t.Errorf("body was allowed to a margin of %0.1f mm", m)
+	}
+
+	p.Z = 0
+	if err := h.SetPose(p, 0); err != nil {
+		t.Errorf("unexpected error moving back: %s", err)
+	}
+}
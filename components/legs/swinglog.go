@@ -0,0 +1,83 @@
+package legs
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// SwingLog writes the path of every swinging foot as CSV, one row per foot per
+// tick, for working out why a foot caught on something. Each row has the goal
+// of the foot and (by forward kinematics from the present angles of its servos)
+// where it actually was, both in the hexapod space. Rows are keyed by the leg,
+// and the number of steps which that leg has taken, so each swing can be
+// plotted separately. Planted feet aren't logged.
+type SwingLog struct {
+	w      *csv.Writer
+	header bool
+}
+
+func NewSwingLog(w io.Writer) *SwingLog {
+	return &SwingLog{
+		w: csv.NewWriter(w),
+	}
+}
+
+// write appends a row for each foot which is swinging at the given time,
+// preceded by the header row if this is the first.
+func (s *SwingLog) write(l *Legs, now time.Time) error {
+	if !s.header {
+		s.w.Write([]string{"time", "leg", "step", "goal_x", "goal_y", "goal_z", "actual_x", "actual_y", "actual_z"})
+		s.header = true
+	}
+
+	if !l.stepping() {
+		return nil
+	}
+
+	for _, i := range l.legSet()[l.sLegsIndex] {
+		leg := l.Legs[i]
+		if !leg.Initialized || !l.inGait(i) {
+			continue
+		}
+
+		g := l.footGoal(i)
+		row := []string{
+			fmt.Sprintf("%.3f", float64(now.UnixNano())/1e9),
+			leg.Name,
+			strconv.Itoa(l.steps[i]),
+			ftoa(g.X), ftoa(g.Y), ftoa(g.Z),
+		}
+
+		// If the servos can't be read, leave the actual position blank rather
+		// than losing the whole row.
+		a, err := l.legAngles(i, now, l.PositionMaxAge)
+		if err != nil {
+			row = append(row, "", "", "")
+		} else {
+			p := leg.ForwardKinematics(a)
+			row = append(row, ftoa(p.X), ftoa(p.Y), ftoa(p.Z))
+		}
+
+		s.w.Write(row)
+	}
+
+	s.w.Flush()
+	return s.w.Error()
+}
+
+// logSwing writes the swinging feet to the SwingLog, if there is one. If that
+// fails, it's turned off, like the AngleLog.
+func (l *Legs) logSwing(now time.Time) {
+	if l.SwingLog == nil {
+		return
+	}
+
+	err := l.SwingLog.write(l, now)
+	if err != nil {
+		fmt.Printf("error logging swing (turning it off): %s\n", err)
+		l.SwingLog = nil
+	}
+}
@@ -0,0 +1,69 @@
+package legs
+
+import (
+	"bytes"
+	"encoding/csv"
+	"github.com/adammck/hexapod"
+	"math"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestSwingLog(t *testing.T) {
+	c := &hexapod.FakeClock{T: time.Unix(100, 0)}
+	h := hexapod.NewHexapod(nil)
+	l, _, m := mockLegs(h)
+	runInit(l, func(int) float64 { return 12 }, m)
+	h.Clock = c
+	l.SetState(StateStand)
+	l.SetGait(TripodGait)
+
+	buf := &bytes.Buffer{}
+	l.SwingLog = NewSwingLog(buf)
+	for i := 0; i < 100; i++ {
+		h.Position.Z += 1
+		c.Advance(20 * time.Millisecond)
+		l.Tick(c.Now())
+	}
+
+	rows, err := csv.NewReader(buf).ReadAll()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if len(rows) < 2 || rows[0][1] != "leg" || rows[0][6] != "actual_x" {
+		t.Fatalf("expected header and rows, got %v", rows)
+	}
+
+	// Each leg should have taken a few steps, numbered from one, and the mock
+	// servos go exactly where they're told, so the actual path should follow
+	// the goal. It's not exact, since tiny moves aren't sent (see GoalEpsilon).
+	steps := map[string]int{}
+	for _, row := range rows[1:] {
+		n, err := strconv.Atoi(row[2])
+		if err != nil || n < steps[row[1]] || n > steps[row[1]]+1 {
+			t.Fatalf("leg %s: step %s after %d", row[1], row[2], steps[row[1]])
+		}
+
+		steps[row[1]] = n
+		for j := 3; j < 6; j++ {
+			g, _ := strconv.ParseFloat(row[j], 64)
+			a, _ := strconv.ParseFloat(row[j+3], 64)
+			if math.Abs(g-a) > 0.5 {
+				t.Errorf("leg %s: goal %s, but actually %s", row[1], row[3:6], row[6:9])
+				break
+			}
+		}
+	}
+
+	if len(steps) != 6 {
+		t.Errorf("expected every leg to swing, got %v", steps)
+	}
+
+	for name, n := range steps {
+		if n < 2 {
+			t.Errorf("leg %s only took %d steps", name, n)
+		}
+	}
+}
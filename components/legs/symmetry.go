@@ -0,0 +1,78 @@
+package legs
+
+import (
+	"fmt"
+	"github.com/adammck/hexapod/math3d"
+	"github.com/adammck/hexapod/utils"
+	"math"
+	"strings"
+)
+
+// The names of each pair of legs which are mirror images of each other, left
+// then right.
+var mirrorPairs = [3][2]string{
+	{"FL", "FR"},
+	{"ML", "MR"},
+	{"BL", "BR"},
+}
+
+// CheckSymmetry solves mirror-image foot positions (in the standing pose) for
+// each pair of left and right legs, and returns an error listing each joint at
+// which the servo angles (including reversal and calibration) aren't mirror
+// images of each other by more than the given tolerance (in degrees). The coxae
+// should turn opposite ways, and the other joints the same way. No servos are
+// moved.
+func (l *Legs) CheckSymmetry(tolerance float64) error {
+	problems := []string{}
+
+	for _, pair := range mirrorPairs {
+		left, lok := l.LegByName(pair[0])
+		right, rok := l.LegByName(pair[1])
+		if !lok || !rok {
+			return fmt.Errorf("missing leg: %s or %s", pair[0], pair[1])
+		}
+
+		pr := math3d.Vector3{l.StanceRadius, -l.StandClearance, 0}.RotateY(right.Angle)
+		pl := math3d.Vector3{-pr.X, pr.Y, pr.Z}
+
+		sr, err := symmetryAngles(right, pr)
+		if err != nil {
+			return err
+		}
+
+		sl, err := symmetryAngles(left, pl)
+		if err != nil {
+			return err
+		}
+
+		diff := [4]float64{
+			utils.NormalizeDeg(sl.Coxa + sr.Coxa),
+			sl.Femur - sr.Femur,
+			sl.Tibia - sr.Tibia,
+			sl.Tarsus - sr.Tarsus,
+		}
+
+		for i, d := range diff {
+			if math.Abs(d) > tolerance {
+				problems = append(problems, fmt.Sprintf("%s/%s %s off by %0.2f deg", left.Name, right.Name, jointNames[i], d))
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("asymmetric: %s", strings.Join(problems, ", "))
+	}
+
+	return nil
+}
+
+// symmetryAngles returns the servo angles which would place the foot of the
+// given leg at the given position.
+func symmetryAngles(leg *Leg, p math3d.Vector3) (JointAngles, error) {
+	c, f, tb, ts, err := leg.SolveIK(p)
+	if err != nil {
+		return JointAngles{}, fmt.Errorf("leg %s: %s", leg.Name, err)
+	}
+
+	return leg.servoAngles(JointAngles{c, f, tb, ts}), nil
+}
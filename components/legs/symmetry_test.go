@@ -0,0 +1,39 @@
+package legs
+
+import (
+	"github.com/adammck/hexapod"
+	"strings"
+	"testing"
+)
+
+func TestCheckSymmetry(t *testing.T) {
+	l := New(hexapod.NewHexapod(nil), nil)
+
+	// The heading of MR is a degree off of the mirror image of ML, so that pair
+	// is a little asymmetric to begin with.
+	if err := l.CheckSymmetry(1.5); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	if err := l.CheckSymmetry(0.5); err == nil || !strings.Contains(err.Error(), "ML/MR coxa") {
+		t.Errorf("expected ML/MR coxa to be flagged, got %v", err)
+	}
+
+	// Mirrored calibration is still symmetric.
+	l.Legs[1].CalibrationOffsets = JointAngles{3, -2, 1, 4}
+	l.MirrorCalibration("FR", "FL")
+	if err := l.CheckSymmetry(1.5); err != nil {
+		t.Errorf("unexpected error after mirroring calibration: %s", err)
+	}
+
+	// But a femur which was calibrated on only one side isn't.
+	l.Legs[3].CalibrationOffsets.Femur = 5
+	err := l.CheckSymmetry(1.5)
+	if err == nil || !strings.Contains(err.Error(), "BL/BR femur") {
+		t.Errorf("expected BL/BR femur to be flagged, got %v", err)
+	}
+
+	if strings.Contains(err.Error(), "FL/FR") {
+		t.Errorf("expected FL/FR not to be flagged, got %v", err)
+	}
+}
@@ -0,0 +1,104 @@
+package legs
+
+import (
+	"fmt"
+	"github.com/adammck/hexapod/math3d"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Validate checks the configuration of the legs (and the gait) for mistakes
+// which would otherwise only show up once the hexapod is walking, e.g. a stride
+// which the legs can't reach. It returns an error listing every problem, or nil
+// if there are none. The feet are checked from the current pose of the body.
+func (l *Legs) Validate() error {
+	problems := []string{}
+	add := func(format string, a ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, a...))
+	}
+
+	for name, d := range map[string]time.Duration{
+		"SitDownDuration": l.SitDownDuration,
+		"LiftStagger":     l.LiftStagger,
+		"SettleDelay":     l.SettleDelay,
+		"AnkleCushion":    l.AnkleCushion,
+		"PositionMaxAge":  l.PositionMaxAge,
+	} {
+		if d < 0 {
+			add("%s is negative (%s)", name, d)
+		}
+	}
+
+	if l.StepHeight <= 0 {
+		add("StepHeight must be positive (%0.1f)", l.StepHeight)
+	}
+
+	if l.StandClearance <= sitDownClearance {
+		add("StandClearance must be above %0.1f (%0.1f)", sitDownClearance, l.StandClearance)
+	}
+
+	if d := l.DutyFactor; d != 0 && (d < l.MinDutyFactor() || d > maxDutyFactor) {
+		add("DutyFactor %0.2f out of range (%0.2f to %0.2f)", d, l.MinDutyFactor(), maxDutyFactor)
+	}
+
+	for _, g := range []Gait{l.gait, l.nextGait} {
+		if g != nil {
+			problems = append(problems, l.validateGait(g)...)
+		}
+	}
+
+	// Every foot must be reachable at both ends of its step, both down and up.
+	m := l.hexapod.Local()
+	for i, leg := range l.Legs {
+		if l.disabled[i] {
+			continue
+		}
+
+		for name, r := range map[string]float64{"stance": l.StanceRadius, "stride": l.StrideRadius} {
+			down := *l.radialFootPosition(leg, r)
+			up := down
+			up.Y += l.StepHeight
+
+			for _, p := range []math3d.Vector3{down, up} {
+				if !leg.CanReach(p.MultiplyByMatrix44(m)) {
+					add("leg %s can't reach its %s position %s", leg.Name, name, p)
+					break
+				}
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return fmt.Errorf("invalid config: %s", strings.Join(problems, "; "))
+	}
+
+	return nil
+}
+
+// validateGait returns a problem for each leg which doesn't appear exactly once
+// in the leg sets of the given gait.
+func (l *Legs) validateGait(g Gait) []string {
+	problems := []string{}
+	seen := [6]int{}
+
+	for _, set := range g.LegSets() {
+		for _, i := range set {
+			if i < 0 || i >= len(l.Legs) {
+				problems = append(problems, fmt.Sprintf("gait %s steps invalid leg %d", g, i))
+				continue
+			}
+
+			seen[i] += 1
+		}
+	}
+
+	for i, n := range seen {
+		if n != 1 {
+			problems = append(problems, fmt.Sprintf("gait %s steps leg %s %d times", g, l.Legs[i].Name, n))
+		}
+	}
+
+	return problems
+}
@@ -0,0 +1,44 @@
+package legs
+
+import (
+	"github.com/adammck/hexapod"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidate(t *testing.T) {
+	l := New(hexapod.NewHexapod(nil), nil)
+	if err := l.Validate(); err != nil {
+		t.Fatalf("default config: unexpected error: %s", err)
+	}
+
+	l.SettleDelay = -time.Second
+	l.StrideRadius = 1000
+	l.SetGait(&legSetGait{"broken", [][]int{
+		[]int{0, 1},
+		[]int{1, 2, 3, 9},
+	}})
+
+	err := l.Validate()
+	if err == nil {
+		t.Fatalf("expected an error")
+	}
+
+	// Every problem should be listed, not just the first.
+	for _, exp := range []string{
+		"SettleDelay is negative",
+		"gait broken steps invalid leg 9",
+		"gait broken steps leg FR 2 times",
+		"gait broken steps leg BL 0 times",
+		"leg ML can't reach its stride position",
+	} {
+		if !strings.Contains(err.Error(), exp) {
+			t.Errorf("expected %q in error: %s", exp, err)
+		}
+	}
+
+	if strings.Contains(err.Error(), "stance position") {
+		t.Errorf("stance position should be reachable: %s", err)
+	}
+}
@@ -0,0 +1,56 @@
+package legs
+
+import (
+	"github.com/adammck/hexapod/math3d"
+	"math"
+	"strings"
+)
+
+const (
+
+	// The size (in characters) of the TopView, and the distance (in mm) which
+	// each column covers. Rows cover twice as far, since characters in a
+	// terminal are about twice as tall as they are wide.
+	viewCols  = 41
+	viewRows  = 21
+	viewScale = 15.0
+)
+
+// TopView returns a rough top-down picture of the body and feet (in the hexapod
+// space, so forwards is up), for debugging over SSH. The center of the body is
+// drawn as +, and the origin of each leg as o. Feet on the ground are drawn as
+// the index of their leg, and lifted feet as a letter (a for 0, b for 1, etc).
+// Anything outside of the view is left out.
+func (l *Legs) TopView() string {
+	grid := make([][]byte, viewRows)
+	for r := range grid {
+		grid[r] = []byte(strings.Repeat(".", viewCols))
+	}
+
+	plot := func(v math3d.Vector3, c byte) {
+		col := int(math.Floor((v.X / viewScale) + 0.5 + (viewCols / 2)))
+		row := int(math.Floor((-v.Z / (viewScale * 2)) + 0.5 + (viewRows / 2)))
+		if col >= 0 && col < viewCols && row >= 0 && row < viewRows {
+			grid[row][col] = c
+		}
+	}
+
+	plot(math3d.ZeroVector3, '+')
+	for i, leg := range l.Legs {
+		plot(*leg.Origin, 'o')
+
+		c := byte('0' + i)
+		if l.feet[i].Y > l.footDownAt(*l.feet[i]) {
+			c = byte('a' + i)
+		}
+
+		plot(l.footGoal(i), c)
+	}
+
+	lines := make([]string, viewRows)
+	for r := range grid {
+		lines[r] = string(grid[r])
+	}
+
+	return strings.Join(lines, "\n") + "\n"
+}
@@ -0,0 +1,38 @@
+package legs
+
+import (
+	"github.com/adammck/hexapod"
+	"strings"
+	"testing"
+)
+
+func TestTopView(t *testing.T) {
+	l := New(hexapod.NewHexapod(nil), nil)
+	l.feet[2].Y = 20
+
+	v := l.TopView()
+	rows := strings.Split(strings.TrimSuffix(v, "\n"), "\n")
+	if len(rows) != viewRows || len(rows[0]) != viewCols {
+		t.Fatalf("expected %dx%d view, got %dx%d", viewCols, viewRows, len(rows[0]), len(rows))
+	}
+
+	if rows[viewRows/2][viewCols/2] != '+' {
+		t.Errorf("expected body in the middle:\n%s", v)
+	}
+
+	// MR is lifted, so it's drawn as a letter.
+	for _, c := range []string{"0", "1", "c", "3", "4", "5"} {
+		if !strings.Contains(v, c) {
+			t.Errorf("expected %s in view:\n%s", c, v)
+		}
+	}
+
+	if strings.Contains(v, "2") {
+		t.Errorf("expected lifted leg not to be drawn as planted:\n%s", v)
+	}
+
+	// The front legs are above the back legs.
+	if strings.Index(v, "0") > strings.Index(v, "4") {
+		t.Errorf("expected front legs at the top:\n%s", v)
+	}
+}
@@ -0,0 +1,82 @@
+package legs
+
+import (
+	"github.com/adammck/hexapod/math3d"
+)
+
+// The range of each joint (in degrees either side of zero) which is swept by
+// WorkspaceSamples when the leg has no limits. This is the full range of the
+// AX-12, which can't turn all the way around.
+const servoRange = 150.0
+
+// SampleWorkspace returns every point on a grid (with the given spacing in mm,
+// in the hexapod coordinate space) which the foot of the leg can reach. The grid
+// covers a cube around the origin of the leg, big enough to hold every point
+// which it could possibly reach. This is for plotting, not for walking; it
+// solves the IK for a lot of points, so a fine grid is slow.
+func (leg *Leg) SampleWorkspace(resolution float64) []math3d.Vector3 {
+	if resolution <= 0 {
+		return nil
+	}
+
+	r := leg.geometry().maxReach()
+	o := *leg.Origin
+	res := []math3d.Vector3{}
+
+	for x := -r; x <= r; x += resolution {
+		for y := -r; y <= r; y += resolution {
+			for z := -r; z <= r; z += resolution {
+				p := math3d.Vector3{o.X + x, o.Y + y, o.Z + z}
+				if leg.CanReach(p) {
+					res = append(res, p)
+				}
+			}
+		}
+	}
+
+	return res
+}
+
+// WorkspaceSamples returns the position of the foot (in the hexapod coordinate
+// space) at every combination of joint angles, with each joint swept through
+// the given number of evenly spaced angles within its limits (or the full range
+// of the servo, if the leg has none). Unlike SampleWorkspace, this includes the
+// poses which the IK never picks, e.g. with the tarsus not vertical, so it shows
+// everywhere that the foot could possibly go. The number of points is the
+// resolution to the fourth power, so keep it small.
+func (leg *Leg) WorkspaceSamples(resolution int) []math3d.Vector3 {
+	if resolution < 2 {
+		return nil
+	}
+
+	lim := JointLimits{
+		Min: JointAngles{-servoRange, -servoRange, -servoRange, -servoRange},
+		Max: JointAngles{servoRange, servoRange, servoRange, servoRange},
+	}
+
+	if leg.Limits != nil {
+		lim = *leg.Limits
+	}
+
+	sweep := func(min, max float64) []float64 {
+		res := make([]float64, resolution)
+		for i := range res {
+			res[i] = min + ((max - min) * float64(i) / float64(resolution-1))
+		}
+
+		return res
+	}
+
+	res := make([]math3d.Vector3, 0, resolution*resolution*resolution*resolution)
+	for _, c := range sweep(lim.Min.Coxa, lim.Max.Coxa) {
+		for _, f := range sweep(lim.Min.Femur, lim.Max.Femur) {
+			for _, tb := range sweep(lim.Min.Tibia, lim.Max.Tibia) {
+				for _, ts := range sweep(lim.Min.Tarsus, lim.Max.Tarsus) {
+					res = append(res, leg.ForwardKinematics(JointAngles{c, f, tb, ts}))
+				}
+			}
+		}
+	}
+
+	return res
+}
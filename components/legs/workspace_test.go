@@ -0,0 +1,87 @@
+package legs
+
+import (
+	"github.com/adammck/hexapod/math3d"
+	"math"
+	"testing"
+)
+
+func TestSampleWorkspace(t *testing.T) {
+	leg := &Leg{Origin: &math3d.Vector3{0, 0, 0}, Angle: 0}
+	pts := leg.SampleWorkspace(20)
+	if len(pts) == 0 {
+		t.Fatalf("expected some reachable points")
+	}
+
+	has := map[math3d.Vector3]bool{}
+	for _, p := range pts {
+		has[p] = true
+		if !leg.CanReach(p) {
+			t.Errorf("point %s isn't reachable", p)
+		}
+	}
+
+	// The grid is centered on the origin of the leg, so these are on it.
+	ok := []math3d.Vector3{
+		{140, -80, 0},
+		{100, -60, 100},
+	}
+
+	for _, p := range ok {
+		if !has[p] {
+			t.Errorf("expected %s to be included", p)
+		}
+	}
+
+	// Inside the coxa, and way too high.
+	bad := []math3d.Vector3{
+		{0, -80, 0},
+		{140, 200, 0},
+	}
+
+	for _, p := range bad {
+		if has[p] {
+			t.Errorf("expected %s to be excluded", p)
+		}
+	}
+
+	if leg.SampleWorkspace(0) != nil {
+		t.Errorf("expected nil for zero resolution")
+	}
+}
+
+func TestWorkspaceSamples(t *testing.T) {
+	leg := &Leg{Origin: &math3d.Vector3{0, 0, 0}, Angle: 0}
+	leg.Limits = &JointLimits{
+		Min: JointAngles{-45, -90, 0, -90},
+		Max: JointAngles{45, 90, 135, 90},
+	}
+
+	pts := leg.WorkspaceSamples(5)
+	if len(pts) != 625 {
+		t.Fatalf("got %d points, expected 625", len(pts))
+	}
+
+	// The first sample has every joint at its minimum, and the last at its max.
+	if exp := leg.ForwardKinematics(leg.Limits.Min); pts[0] != exp {
+		t.Errorf("got first point %s, expected %s", pts[0], exp)
+	}
+
+	if exp := leg.ForwardKinematics(leg.Limits.Max); pts[len(pts)-1] != exp {
+		t.Errorf("got last point %s, expected %s", pts[len(pts)-1], exp)
+	}
+
+	// The coxa is limited to 45 degrees either side of straight out, so every
+	// point is within 45 degrees of the X axis (either way, since the leg can
+	// fold back over the top).
+	for _, p := range pts {
+		if math.Abs(p.Z) > math.Abs(p.X)+0.0001 {
+			t.Errorf("point %s is outside the range of the coxa", p)
+			break
+		}
+	}
+
+	if leg.WorkspaceSamples(1) != nil {
+		t.Errorf("expected nil for a single sample")
+	}
+}
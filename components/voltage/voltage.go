@@ -2,33 +2,113 @@ package voltage
 
 import (
 	"fmt"
+	"github.com/adammck/hexapod"
+	"github.com/adammck/hexapod/utils"
+	"math"
 	"time"
 )
 
 const (
 
-	// The number of seconds between voltage checks. These are pretty quick, but
-	// not instant. Running at low voltage for too long will damage the battery,
-	// so it should be checked pretty regularly.
-	interval = 5
+	// The default time between voltage checks. These are pretty quick, but not
+	// instant. Running at low voltage for too long will damage the battery, so
+	// it should be checked pretty regularly.
+	defaultInterval = 5 * time.Second
+
+	// The default time between voltage checks while the hexapod is busy, when
+	// the voltage sags the most.
+	defaultActiveInterval = 1 * time.Second
 
 	// The voltage at which the hexapod should shut down.
 	minimum = 9.6
+
+	// The default speed (in mm per second) to walk home at. See ReturnVoltage.
+	defaultReturnSpeed = 50.0
+
+	// The slowest that the hexapod will limp, as a fraction of full speed, just
+	// above the minimum voltage. See WarningVoltage.
+	minLimp = 0.25
 )
 
 type HasVoltage interface {
 	Voltage() (float64, error)
 }
 
+// Homer is something (i.e. the hexapod) which can walk back to where it started,
+// a bit at a time, if it knows where that is. See hexapod.WalkHome.
+type Homer interface {
+	HasHome() bool
+	WalkHome(speed float64, dt float64) (bool, error)
+}
+
+// Activity is implemented by components (i.e. the legs) which know whether the
+// hexapod is doing something which draws a lot of current, like stepping.
+type Activity interface {
+	Active() bool
+}
+
+// Limper is implemented by components which can go easier (e.g. walk slower, or
+// lift the feet less) to draw less current. The fraction is of full effort, so
+// one is back to normal.
+type Limper interface {
+	Limp(f float64)
+}
+
 type VoltageCheck struct {
 	t time.Time
 	HasVoltage
+
+	// The number of times to retry a voltage read which fails, before giving up
+	// and returning an error.
+	Retries int
+
+	// The source of the current time, for checks made outside of Tick, which
+	// uses the time it's given. This should be the hexapod's clock.
+	Clock hexapod.Clock
+
+	// The time between voltage checks. Zero disables them, which is handy on a
+	// bench supply.
+	Interval time.Duration
+
+	// What to ask whether the hexapod is busy, and the (shorter) time between
+	// checks while it is. ActiveInterval is ignored when Activity is nil.
+	Activity       Activity
+	ActiveInterval time.Duration
+
+	// The voltage below which to walk home (at ReturnSpeed), while there's still
+	// enough charge to get there before the hexapod is shut down. Zero, a nil
+	// Home, or a Home which doesn't know where home is, disables it.
+	ReturnVoltage float64
+	ReturnSpeed   float64
+	Home          Homer
+
+	// The voltage below which to keep going, but limp (see Limper), rather than
+	// shutting down at once. The further below it that the voltage is, the more
+	// the hexapod slows down, until it's shut down at the minimum. This is for
+	// getting out of the way before that happens. Zero disables it.
+	WarningVoltage float64
+	Limpers        []Limper
+
+	// The current fraction of full effort. See WarningVoltage.
+	limp float64
+
+	// The last voltage which was read, whether we're walking home (or already
+	// have), and when we last took a step towards it.
+	voltage    float64
+	returning  bool
+	returned   bool
+	returnTime time.Time
 }
 
 func New(servo HasVoltage) *VoltageCheck {
 	return &VoltageCheck{
-		time.Time{},
-		servo,
+		t:              time.Time{},
+		HasVoltage:     servo,
+		Clock:          hexapod.RealClock{},
+		Interval:       defaultInterval,
+		ActiveInterval: defaultActiveInterval,
+		ReturnSpeed:    defaultReturnSpeed,
+		limp:           1,
 	}
 }
 
@@ -37,33 +117,130 @@ func (vc *VoltageCheck) Boot() error {
 }
 
 func (vc *VoltageCheck) Tick(now time.Time) error {
-	if vc.NeedsVoltageCheck() {
-		return vc.CheckVoltage()
+	if vc.needsCheck(now) {
+		err := vc.check(now)
+		if err != nil {
+			return err
+		}
+
+		vc.updateLimp()
+
+		if !vc.returning && vc.shouldReturn() {
+			fmt.Printf("voltage below %.2fv, returning home\n", vc.ReturnVoltage)
+			vc.returning = true
+			vc.returnTime = now
+		}
+	}
+
+	if vc.returning {
+		return vc.walkHome(now)
+	}
+
+	return nil
+}
+
+// shouldReturn returns true if the last voltage read is low enough that the
+// hexapod should head home, and there's a home to head to.
+func (vc *VoltageCheck) shouldReturn() bool {
+	return vc.Home != nil && vc.Home.HasHome() && vc.ReturnVoltage > 0 && vc.voltage < vc.ReturnVoltage && !vc.returned
+}
+
+// walkHome takes a step towards home, and stops returning once it's there. The
+// hexapod only returns once; it stays there until the voltage drops low enough
+// to shut it down, or someone walks it somewhere else.
+func (vc *VoltageCheck) walkHome(now time.Time) error {
+	dt := now.Sub(vc.returnTime).Seconds()
+	vc.returnTime = now
+
+	home, err := vc.Home.WalkHome(vc.ReturnSpeed*vc.limp, dt)
+	if err != nil {
+		vc.returning = false
+		return err
+	}
+
+	if home {
+		fmt.Println("returned home")
+		vc.returning = false
+		vc.returned = true
 	}
 
 	return nil
 }
 
+// updateLimp works out how hard the hexapod should be working, given the last
+// voltage read, and tells each Limper if it has changed. Above WarningVoltage,
+// that's full effort. Below it, the effort falls (linearly) to minLimp at the
+// minimum voltage.
+func (vc *VoltageCheck) updateLimp() {
+	f := 1.0
+	if vc.WarningVoltage > minimum && vc.voltage < vc.WarningVoltage {
+		f = (vc.voltage - minimum) / (vc.WarningVoltage - minimum)
+		f = math.Max(minLimp, math.Min(1, f))
+	}
+
+	if f == vc.limp {
+		return
+	}
+
+	if f < 1 {
+		fmt.Printf("voltage below %.2fv, limping at %.0f%%\n", vc.WarningVoltage, f*100)
+	}
+
+	vc.limp = f
+	for _, l := range vc.Limpers {
+		l.Limp(f)
+	}
+}
+
 // NeedsVoltageCheck returns true if it's been a while since we checked the
-// voltage level. The timeout is pretty arbitrary.
+// voltage level, unless checks are disabled. The interval is pretty arbitrary.
 func (vc *VoltageCheck) NeedsVoltageCheck() bool {
-	return time.Since(vc.t) > (interval * time.Second)
+	return vc.needsCheck(vc.Clock.Now())
+}
+
+func (vc *VoltageCheck) needsCheck(now time.Time) bool {
+	if vc.Interval <= 0 {
+		return false
+	}
+
+	return now.Sub(vc.t) > vc.effectiveInterval()
+}
+
+// effectiveInterval returns the time between voltage checks right now, which is
+// shorter while the hexapod is busy.
+func (vc *VoltageCheck) effectiveInterval() time.Duration {
+	if vc.Activity != nil && vc.ActiveInterval > 0 && vc.ActiveInterval < vc.Interval && vc.Activity.Active() {
+		return vc.ActiveInterval
+	}
+
+	return vc.Interval
 }
 
 // CheckVoltage fetches the voltage level of an arbitrary servo, and returns an
 // error if it's too low. In this case, the program should be terminated as soon
 // as possible to preserve the battery.
 func (vc *VoltageCheck) CheckVoltage() error {
-	val, err := vc.Voltage()
-	vc.t = time.Now()
+	return vc.check(vc.Clock.Now())
+}
+
+func (vc *VoltageCheck) check(now time.Time) error {
+	var val float64
+	err := utils.Retry(vc.Retries, func() error {
+		var err error
+		val, err = vc.Voltage()
+		return err
+	})
+
+	vc.t = now
 	if err != nil {
 		return err
 	}
 
+	vc.voltage = val
 	fmt.Printf("voltage: %.2fv\n", val)
 
 	if val < minimum {
-		return fmt.Errorf("low voltage: %.2fv", val)
+		return fmt.Errorf("%w: %.2fv", hexapod.ErrLowVoltage, val)
 	}
 
 	return nil
@@ -0,0 +1,309 @@
+package voltage
+
+import (
+	"errors"
+	"fmt"
+	"github.com/adammck/hexapod"
+	"math"
+	"testing"
+	"time"
+)
+
+// flakyServo returns an error for the first n voltage reads, then succeeds.
+type flakyServo struct {
+	n     int
+	reads int
+	v     float64
+}
+
+func (s *flakyServo) Voltage() (float64, error) {
+	s.reads += 1
+	if s.reads <= s.n {
+		return 0, fmt.Errorf("timeout")
+	}
+
+	return s.v, nil
+}
+
+func TestCheckVoltageRetries(t *testing.T) {
+	s := &flakyServo{n: 2, v: 11.1}
+	vc := New(s)
+	vc.Retries = 2
+
+	if err := vc.CheckVoltage(); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	if s.reads != 3 {
+		t.Errorf("read %d times, expected 3", s.reads)
+	}
+}
+
+func TestCheckVoltageGivesUp(t *testing.T) {
+	s := &flakyServo{n: 5, v: 11.1}
+	vc := New(s)
+	vc.Retries = 2
+
+	if err := vc.CheckVoltage(); err == nil {
+		t.Errorf("expected error after retries exhausted")
+	}
+
+	if s.reads != 3 {
+		t.Errorf("read %d times, expected 3", s.reads)
+	}
+}
+
+func TestCheckVoltageLow(t *testing.T) {
+	vc := New(&flakyServo{v: 9.1})
+
+	err := vc.CheckVoltage()
+	if !errors.Is(err, hexapod.ErrLowVoltage) {
+		t.Errorf("expected ErrLowVoltage, got %v", err)
+	}
+}
+
+func TestNeedsVoltageCheck(t *testing.T) {
+	c := &hexapod.FakeClock{T: time.Unix(100, 0)}
+	s := &flakyServo{v: 11.1}
+	vc := New(s)
+	vc.Clock = c
+
+	if !vc.NeedsVoltageCheck() {
+		t.Errorf("expected check before the first")
+	}
+
+	vc.Tick(c.Now())
+	c.Advance(defaultInterval - 1)
+	if vc.NeedsVoltageCheck() {
+		t.Errorf("expected no check before the interval has passed")
+	}
+
+	c.Advance(2)
+	if !vc.NeedsVoltageCheck() {
+		t.Errorf("expected check once the interval has passed")
+	}
+
+	vc.Tick(c.Now())
+	if s.reads != 2 {
+		t.Errorf("read %d times, expected 2", s.reads)
+	}
+}
+
+func TestTickUsesItsTime(t *testing.T) {
+	s := &flakyServo{v: 11.1}
+	vc := New(s)
+
+	// The clock is real, so is nowhere near these times. Only the times which
+	// Tick is given should matter.
+	start := time.Unix(100, 0)
+	vc.Tick(start)
+	vc.Tick(start.Add(defaultInterval - 1))
+	if s.reads != 1 {
+		t.Errorf("read %d times before the interval passed, expected 1", s.reads)
+	}
+
+	vc.Tick(start.Add(defaultInterval + 1))
+	if s.reads != 2 {
+		t.Errorf("read %d times after the interval passed, expected 2", s.reads)
+	}
+}
+
+func TestNeedsVoltageCheckDisabled(t *testing.T) {
+	s := &flakyServo{v: 11.1}
+	vc := New(s)
+	vc.Interval = 0
+
+	if vc.NeedsVoltageCheck() {
+		t.Errorf("expected no check when disabled")
+	}
+
+	if err := vc.Tick(vc.Clock.Now()); err != nil || s.reads != 0 {
+		t.Errorf("expected no reads when disabled, got %d (err=%v)", s.reads, err)
+	}
+}
+
+type busy bool
+
+func (b *busy) Active() bool {
+	return bool(*b)
+}
+
+func TestActiveInterval(t *testing.T) {
+	c := &hexapod.FakeClock{T: time.Unix(100, 0)}
+	b := busy(false)
+	vc := New(&flakyServo{v: 11.1})
+	vc.Clock = c
+	vc.Activity = &b
+
+	if i := vc.effectiveInterval(); i != defaultInterval {
+		t.Errorf("idle: got interval %s, expected %s", i, defaultInterval)
+	}
+
+	vc.Tick(c.Now())
+	c.Advance(defaultActiveInterval + 1)
+	if vc.NeedsVoltageCheck() {
+		t.Errorf("idle: expected no check after %s", defaultActiveInterval)
+	}
+
+	b = true
+	if i := vc.effectiveInterval(); i != defaultActiveInterval {
+		t.Errorf("active: got interval %s, expected %s", i, defaultActiveInterval)
+	}
+
+	if !vc.NeedsVoltageCheck() {
+		t.Errorf("active: expected check after %s", defaultActiveInterval)
+	}
+}
+
+// homer counts the steps taken towards home, and arrives after n. Unless lost is
+// set, it knows where home is.
+type homer struct {
+	n     int
+	steps int
+	lost  bool
+}
+
+func (h *homer) HasHome() bool {
+	return !h.lost
+}
+
+func (h *homer) WalkHome(speed float64, dt float64) (bool, error) {
+	h.steps += 1
+	return h.steps >= h.n, nil
+}
+
+func TestReturnHome(t *testing.T) {
+	c := &hexapod.FakeClock{T: time.Unix(100, 0)}
+	s := &flakyServo{v: 11.1}
+	h := &homer{n: 3}
+	vc := New(s)
+	vc.Clock = c
+	vc.Interval = time.Second
+	vc.ReturnVoltage = 10.5
+	vc.Home = h
+
+	tick := func() {
+		c.Advance(time.Second)
+		if err := vc.Tick(c.Now()); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+
+	// Plenty of charge.
+	tick()
+	if h.steps != 0 {
+		t.Errorf("walked home at %.2fv", s.v)
+	}
+
+	// Just above the threshold.
+	s.v = 10.5
+	tick()
+	if h.steps != 0 {
+		t.Errorf("walked home at %.2fv", s.v)
+	}
+
+	// Below it, so head home until we get there.
+	s.v = 10.4
+	for i := 0; i < 5; i++ {
+		tick()
+	}
+
+	if h.steps != 3 {
+		t.Errorf("took %d steps home, expected 3", h.steps)
+	}
+}
+
+func TestReturnHomeDisabled(t *testing.T) {
+	c := &hexapod.FakeClock{T: time.Unix(100, 0)}
+	h := &homer{n: 1}
+	vc := New(&flakyServo{v: 10})
+	vc.Clock = c
+	vc.Home = h
+
+	c.Advance(time.Minute)
+	vc.Tick(c.Now())
+	if h.steps != 0 {
+		t.Errorf("walked home without a return voltage")
+	}
+}
+
+func TestReturnHomeWithoutHome(t *testing.T) {
+	c := &hexapod.FakeClock{T: time.Unix(100, 0)}
+	h := &homer{n: 1, lost: true}
+	vc := New(&flakyServo{v: 10})
+	vc.Clock = c
+	vc.ReturnVoltage = 10.5
+	vc.Home = h
+
+	// Nowhere to go, so carry on as if returning were disabled.
+	for i := 0; i < 3; i++ {
+		c.Advance(time.Minute)
+		if err := vc.Tick(c.Now()); err != nil {
+			t.Errorf("unexpected error: %s", err)
+		}
+	}
+
+	if h.steps != 0 {
+		t.Errorf("walked home without a home")
+	}
+}
+
+// limper records the effort which it was last told to limp at.
+type limper struct {
+	f     float64
+	calls int
+}
+
+func (l *limper) Limp(f float64) {
+	l.f = f
+	l.calls += 1
+}
+
+func TestLimp(t *testing.T) {
+	c := &hexapod.FakeClock{T: time.Unix(100, 0)}
+	s := &flakyServo{v: 11.1}
+	l := &limper{}
+	vc := New(s)
+	vc.Clock = c
+	vc.Interval = time.Second
+	vc.WarningVoltage = 10.4
+	vc.Limpers = []Limper{l}
+
+	tick := func(v float64) error {
+		s.v = v
+		c.Advance(2 * time.Second)
+		return vc.Tick(c.Now())
+	}
+
+	// Plenty of charge, so nothing to say.
+	tick(11.1)
+	if l.calls != 0 {
+		t.Errorf("limped at %0.2f at %.2fv", l.f, s.v)
+	}
+
+	// Half way between the warning and the minimum.
+	tick(10.0)
+	if math.Abs(l.f-0.5) > 0.0001 {
+		t.Errorf("got %0.2f at %.2fv, expected 0.5", l.f, s.v)
+	}
+
+	// Just above the minimum, it's still moving, just barely.
+	if err := tick(9.61); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	if l.f != minLimp {
+		t.Errorf("got %0.2f at %.2fv, expected %0.2f", l.f, s.v, minLimp)
+	}
+
+	// Back to normal once the voltage recovers, e.g. on a bench supply.
+	tick(11.1)
+	if l.f != 1 {
+		t.Errorf("got %0.2f at %.2fv, expected 1", l.f, s.v)
+	}
+
+	// Below the minimum, it's time to stop.
+	if err := tick(9.5); !errors.Is(err, hexapod.ErrLowVoltage) {
+		t.Errorf("expected ErrLowVoltage, got %v", err)
+	}
+}
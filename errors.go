@@ -0,0 +1,44 @@
+package hexapod
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Errors which callers might want to handle specially. These are usually
+// wrapped with more detail, so compare them with errors.Is.
+var (
+
+	// The foot can't be placed where it was asked to be.
+	ErrUnreachable = errors.New("target unreachable")
+
+	// The leg can't be moved until it's been initialized.
+	ErrNotInitialized = errors.New("leg not initialized")
+
+	// The servos aren't getting enough voltage. The battery is probably nearly
+	// flat, so the hexapod should shut down soon.
+	ErrLowVoltage = errors.New("low voltage")
+
+	// A servo is too hot, and should be given a rest.
+	ErrOverheat = errors.New("overheating")
+
+	// A servo didn't respond. It's probably unplugged.
+	ErrServoMissing = errors.New("servo not responding")
+
+	// The legs have been halted.
+	ErrHalted = errors.New("halted")
+)
+
+// ServoError is an error which relates to a specific servo.
+type ServoError struct {
+	ID  uint8
+	Err error
+}
+
+func (e *ServoError) Error() string {
+	return fmt.Sprintf("servo #%d: %s", e.ID, e.Err)
+}
+
+func (e *ServoError) Unwrap() error {
+	return e.Err
+}
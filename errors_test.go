@@ -0,0 +1,29 @@
+package hexapod
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestServoError(t *testing.T) {
+	var err error = &ServoError{12, ErrOverheat}
+	err = fmt.Errorf("leg FL: %w", err)
+
+	if err.Error() != "leg FL: servo #12: overheating" {
+		t.Errorf("unexpected message: %s", err)
+	}
+
+	if !errors.Is(err, ErrOverheat) {
+		t.Errorf("expected error to match ErrOverheat")
+	}
+
+	if errors.Is(err, ErrLowVoltage) {
+		t.Errorf("expected error not to match ErrLowVoltage")
+	}
+
+	var se *ServoError
+	if !errors.As(err, &se) || se.ID != 12 {
+		t.Errorf("expected to find ServoError for #12, got %v", se)
+	}
+}
@@ -3,9 +3,19 @@ package hexapod
 import (
 	"github.com/adammck/dynamixel"
 	"github.com/adammck/hexapod/math3d"
+	"github.com/adammck/hexapod/utils"
+	"math"
+	"math/rand"
 	"time"
 )
 
+const (
+
+	// The time to keep looping after Shutdown is set, to give everything time
+	// to shut down gracefully.
+	shutdownGrace = 3 * time.Second
+)
+
 type Hexapod struct {
 	Network    *dynamixel.DynamixelNetwork
 	Components []Component
@@ -17,9 +27,45 @@ type Hexapod struct {
 	Position math3d.Vector3
 	Rotation float64
 
+	// The attitude (in degrees) of the body. The feet stay put in the world
+	// space when these change, so the body leans. Positive pitch lowers the
+	// front, and positive roll raises the right side.
+	Pitch float64
+	Roll  float64
+
+	// The point (in the hexapod coordinate space) which the hexapod turns around.
+	// This is the origin (i.e. the center of the body) by default, but can be
+	// moved e.g. to a foot, to pivot around it. Only turning is affected; the
+	// position is still that of the origin. See Turn.
+	Pivot math3d.Vector3
+
+	// The heading (in degrees) which the hexapod should turn to face, or nil if
+	// there's nowhere in particular to face. Rotation isn't changed immediately;
+	// the controller turns towards this gradually, then clears it.
+	TargetRotation *float64
+
+	// The state of the operator's controls during the current Step, for
+	// components (e.g. the controller) to act upon.
+	Input InputState
+
+	// Whether to skip the self-test before the main loop starts. See SelfTest.
+	SkipSelfTest bool
+
 	// Components can set this to true to indicate that the hex should shut down.
 	// TODO: Is this the same as returning an error from Tick()?
 	Shutdown bool
+
+	// The source of the current time. See Now.
+	Clock Clock
+
+	// The source of randomness, which every component should use so the hexapod
+	// can be made to behave the same way every time. See Seed.
+	Rand *rand.Rand
+
+	// The position and rotation (in the world space) to return to, or nil if
+	// none has been recorded. See SetHome.
+	Home         *math3d.Vector3
+	HomeRotation float64
 }
 
 type Component interface {
@@ -27,6 +73,18 @@ type Component interface {
 	Tick(time.Time) error
 }
 
+// PoseValidator is implemented by components which care where the body is moved
+// to, e.g. because the legs can't reach their feet from some positions.
+type PoseValidator interface {
+	ValidatePose(position math3d.Vector3, rotation float64) error
+}
+
+// OriginShifter is implemented by components which store positions in the world
+// space (e.g. the feet), so they can be moved along with the origin.
+type OriginShifter interface {
+	ShiftOrigin(offset math3d.Vector3)
+}
+
 // NewHexapod creates a new Hexapod object on the given Dynamixel network.
 func NewHexapod(network *dynamixel.DynamixelNetwork) *Hexapod {
 	return &Hexapod{
@@ -34,9 +92,21 @@ func NewHexapod(network *dynamixel.DynamixelNetwork) *Hexapod {
 		Components: []Component{},
 		Position:   math3d.Vector3{0, 0, 0},
 		Rotation:   0.0,
+		Clock:      RealClock{},
+		Rand:       newRand(),
 	}
 }
 
+// Now returns the current time, according to the clock. If no clock has been
+// set, it's the actual time.
+func (h *Hexapod) Now() time.Time {
+	if h.Clock == nil {
+		return time.Now()
+	}
+
+	return h.Clock.Now()
+}
+
 // Add registers a component to receive ticks every frame.
 func (h *Hexapod) Add(c Component) {
 	h.Components = append(h.Components, c)
@@ -54,17 +124,145 @@ func (h *Hexapod) Boot() error {
 	return nil
 }
 
-// Tick calls Tick on each component.
-func (h *Hexapod) Tick(now time.Time) {
+// Step runs a single iteration of the main loop with the given input, by calling
+// Tick on each component with the time of the input (or the current time, if
+// that's zero). It doesn't wait, so tests can step through frames as fast as
+// they like. Every component is ticked, even if some return errors. The first
+// error (if any) is returned.
+func (h *Hexapod) Step(input InputState) error {
+	if input.Time.IsZero() {
+		input.Time = h.Now()
+	}
+
+	h.Input = input
+	var first error
+
+	for _, c := range h.Components {
+		err := c.Tick(input.Time)
+		if err != nil && first == nil {
+			first = err
+		}
+	}
+
+	return first
+}
+
+// MainLoop calls Step at the given interval until Shutdown is set, then keeps
+// looping for a few seconds to give every component time to shut down (e.g. sit
+// down) gracefully, then returns. The interval is measured by the wall clock,
+// but each Step is at the time of the hexapod's own clock (see Now), so every
+// component sees the same time. Unless SkipSelfTest is set, the self-test is
+// run first, and if it fails, the error is returned without stepping at all.
+func (h *Hexapod) MainLoop(interval time.Duration) error {
+	if !h.SkipSelfTest {
+		err := h.SelfTest()
+		if err != nil {
+			return err
+		}
+	}
+
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	var deadline time.Time
+
+	for tick := range t.C {
+
+		// Errors are ignored here. Components which want the hexapod to stop
+		// should set Shutdown.
+		h.Step(h.readInput(h.Now()))
+
+		if h.Shutdown {
+			if deadline.IsZero() {
+				deadline = tick.Add(shutdownGrace)
+
+			} else if tick.After(deadline) {
+				return nil
+			}
+		}
+	}
+
+	return nil
+}
+
+// SetPose moves the hexapod to the given position and rotation in the world
+// space, unless any component objects. In that case, the pose is left alone and
+// the error is returned.
+func (h *Hexapod) SetPose(position math3d.Vector3, rotation float64) error {
 	for _, c := range h.Components {
-		c.Tick(now)
+		if v, ok := c.(PoseValidator); ok {
+			err := v.ValidatePose(position, rotation)
+			if err != nil {
+				return err
+			}
+		}
 	}
+
+	h.Position = position
+	h.Rotation = rotation
+	return nil
+}
+
+// SetOrigin moves the origin of the world space to the current position of the
+// hexapod, so the position becomes zero. Nothing physically moves; components
+// which store world positions are told to shift them by the same amount, as is
+// the home (see SetHome). The rotation is left alone.
+func (h *Hexapod) SetOrigin() {
+	offset := h.Position
+	for _, c := range h.Components {
+		if s, ok := c.(OriginShifter); ok {
+			s.ShiftOrigin(offset)
+		}
+	}
+
+	if h.Home != nil {
+		h.Home = h.Home.Subtract(offset)
+	}
+
+	h.Position = math3d.ZeroVector3
+}
+
+// FacePoint sets the target rotation to the heading from the current position
+// to the given point in the world space. The feet are stepped around as the
+// hexapod turns, so it may take a while to get there.
+func (h *Hexapod) FacePoint(p math3d.Vector3) {
+	dx := p.X - h.Position.X
+	dz := p.Z - h.Position.Z
+
+	// Already there, so any heading is as good as any other.
+	if dx == 0 && dz == 0 {
+		return
+	}
+
+	r := utils.NormalizeDeg(utils.Deg(math.Atan2(dx, dz)))
+	h.TargetRotation = &r
 }
 
 // World returns a matrix to transform a vector in the hexapod coordinate space
 // into the world space.
 func (h *Hexapod) World() math3d.Matrix44 {
-	return *math3d.MakeMatrix44(h.Position, *math3d.MakeSingularEulerAngle(math3d.RotationHeading, h.Rotation))
+	ea := math3d.EulerAngles{
+		Heading: utils.Rad(h.Rotation),
+		Pitch:   utils.Rad(h.Pitch),
+		Bank:    utils.Rad(h.Roll),
+	}
+
+	return *math3d.MakeMatrix44(h.Position, ea)
+}
+
+// Turn rotates the hexapod by the given number of degrees around its Pivot, by
+// moving it so the pivot stays put in the world space. Like SetPose, if any
+// component objects, it doesn't move at all, and the error is returned.
+func (h *Hexapod) Turn(deg float64) error {
+	if h.Pivot.Zero() {
+		return h.SetPose(h.Position, h.Rotation+deg)
+	}
+
+	hh := *h
+	hh.Rotation += deg
+	before := h.Pivot.MultiplyByMatrix44(h.World())
+	after := h.Pivot.MultiplyByMatrix44(hh.World())
+	return h.SetPose(*h.Position.Add(*before.Subtract(after)), hh.Rotation)
 }
 
 // Local returns a matrix to transform a vector in the world coordinate space
@@ -0,0 +1,177 @@
+package hexapod
+
+import (
+	"fmt"
+	"github.com/adammck/hexapod/math3d"
+	"math"
+	"testing"
+	"time"
+)
+
+type eg struct {
+	pos math3d.Vector3 // position
+	rot float64        // rotation (heading)
+	vec math3d.Vector3 // input
+	exp math3d.Vector3 // expected result
+}
+
+func TestWorld(t *testing.T) {
+	data := []eg{
+		eg{math3d.Vector3{00, 00, 00}, 0.0, math3d.Vector3{0, 0, 0}, math3d.Vector3{0, 0, 00}},
+		eg{math3d.Vector3{00, 00, 10}, 0.0, math3d.Vector3{0, 0, 0}, math3d.Vector3{0, 0, 10}},
+		eg{math3d.Vector3{00, 00, 20}, 0.0, math3d.Vector3{0, 0, 0}, math3d.Vector3{0, 0, 20}},
+		eg{math3d.Vector3{00, 00, 30}, 0.0, math3d.Vector3{0, 0, 0}, math3d.Vector3{0, 0, 30}},
+	}
+
+	for i, eg := range data {
+		h := Hexapod{
+			Position: eg.pos,
+			Rotation: eg.rot,
+		}
+
+		actual := eg.vec.MultiplyByMatrix44(h.World())
+		if actual.Distance(eg.exp) > 0.000001 {
+			t.Errorf("Example #%d: got %s, expected: %s", i+1, actual, eg.exp)
+		}
+	}
+}
+
+func TestLocal(t *testing.T) {
+
+	data := []eg{
+		eg{math3d.Vector3{00, 00, 00}, 0.0, math3d.Vector3{10, 20, 30}, math3d.Vector3{10, 20, 30}},
+		eg{math3d.Vector3{00, 00, 10}, 0.0, math3d.Vector3{10, 20, 30}, math3d.Vector3{10, 20, 20}},
+		eg{math3d.Vector3{00, 00, 20}, 0.0, math3d.Vector3{10, 20, 30}, math3d.Vector3{10, 20, 10}},
+		eg{math3d.Vector3{00, 00, 30}, 0.0, math3d.Vector3{10, 20, 30}, math3d.Vector3{10, 20, 00}},
+	}
+
+	for i, eg := range data {
+		h := Hexapod{
+			Position: eg.pos,
+			Rotation: eg.rot,
+		}
+
+		actual := eg.vec.MultiplyByMatrix44(h.Local())
+		if actual.Distance(eg.exp) > 0.000001 {
+			t.Errorf("Example #%d: got %s, expected: %s", i+1, actual, eg.exp)
+		}
+	}
+}
+
+func TestFacePoint(t *testing.T) {
+	type example struct {
+		pos math3d.Vector3 // position
+		rot float64        // rotation before facing
+		p   math3d.Vector3 // point to face
+		exp float64        // expected target rotation
+	}
+
+	data := []example{
+		example{math3d.Vector3{0, 0, 0}, 0, math3d.Vector3{0, 0, 10}, 0},
+		example{math3d.Vector3{0, 0, 0}, 0, math3d.Vector3{10, 0, 10}, 45},
+		example{math3d.Vector3{0, 0, 0}, 0, math3d.Vector3{10, 0, -10}, 135},
+		example{math3d.Vector3{0, 0, 0}, 0, math3d.Vector3{-10, 0, -10}, -135},
+		example{math3d.Vector3{0, 0, 0}, 0, math3d.Vector3{-10, 0, 10}, -45},
+		example{math3d.Vector3{0, 0, 0}, 0, math3d.Vector3{0, 0, -10}, 180},
+		example{math3d.Vector3{100, 0, 100}, 720, math3d.Vector3{90, 50, 90}, -135},
+	}
+
+	for i, eg := range data {
+		h := Hexapod{
+			Position: eg.pos,
+			Rotation: eg.rot,
+		}
+
+		h.FacePoint(eg.p)
+		if h.TargetRotation == nil {
+			t.Errorf("Example #%d: target rotation was not set", i+1)
+			continue
+		}
+
+		if math.Abs(*h.TargetRotation-eg.exp) > 0.000001 {
+			t.Errorf("Example #%d: got %0.4f, expected %0.4f", i+1, *h.TargetRotation, eg.exp)
+		}
+
+		// Check that, once rotated, straight ahead really is towards the point.
+		h.Rotation = *h.TargetRotation
+		ahead := math3d.Vector3{0, eg.p.Y - eg.pos.Y, math.Hypot(eg.p.X-eg.pos.X, eg.p.Z-eg.pos.Z)}
+		if actual := ahead.MultiplyByMatrix44(h.World()); actual.Distance(eg.p) > 0.0001 {
+			t.Errorf("Example #%d: facing %s, expected %s", i+1, actual, eg.p)
+		}
+	}
+}
+
+func TestTurn(t *testing.T) {
+	h := NewHexapod(nil)
+	h.Position = math3d.Vector3{10, 0, 20}
+
+	// Around the center by default.
+	if err := h.Turn(90); err != nil || h.Position != (math3d.Vector3{10, 0, 20}) || h.Rotation != 90 {
+		t.Errorf("got %s at %0.2f deg (err=%v), expected to turn on the spot", h.Position, h.Rotation, err)
+	}
+
+	// Around a point in front of the center, which should stay put.
+	h.Pivot = math3d.Vector3{0, 0, 100}
+	pivot := h.Pivot.MultiplyByMatrix44(h.World())
+	h.Turn(45)
+
+	if h.Rotation != 135 {
+		t.Errorf("got rotation %0.2f, expected 135", h.Rotation)
+	}
+
+	if p := h.Pivot.MultiplyByMatrix44(h.World()); p.Distance(pivot) > 0.000001 {
+		t.Errorf("pivot moved from %s to %s", pivot, p)
+	}
+
+	if d := h.Position.Distance(pivot); math.Abs(d-100) > 0.000001 {
+		t.Errorf("center is %0.4f from the pivot, expected 100", d)
+	}
+}
+
+// counter is a component which counts its ticks, and returns an error from each
+// tick after the nth.
+type counter struct {
+	ticks int
+	n     int
+	last  time.Time
+}
+
+func (c *counter) Boot() error {
+	return nil
+}
+
+func (c *counter) Tick(now time.Time) error {
+	c.ticks += 1
+	c.last = now
+	if c.ticks > c.n {
+		return fmt.Errorf("tick %d", c.ticks)
+	}
+
+	return nil
+}
+
+func TestStep(t *testing.T) {
+	h := NewHexapod(nil)
+	a := &counter{n: 1}
+	b := &counter{n: 2}
+	h.Add(a)
+	h.Add(b)
+
+	now := time.Unix(100, 0)
+	if err := h.Step(InputState{Time: now}); err != nil {
+		t.Errorf("step 1: unexpected error: %s", err)
+	}
+
+	if a.ticks != 1 || b.ticks != 1 || !a.last.Equal(now) {
+		t.Errorf("step 1: expected each component to tick once at %s", now)
+	}
+
+	// The first component fails, but the second should still tick.
+	if err := h.Step(InputState{Time: now}); err == nil || err.Error() != "tick 2" {
+		t.Errorf("step 2: got %v, expected error from first component", err)
+	}
+
+	if b.ticks != 2 {
+		t.Errorf("step 2: second component ticked %d times, expected 2", b.ticks)
+	}
+}
@@ -0,0 +1,53 @@
+package hexapod
+
+import (
+	"time"
+)
+
+// Stick is the position of an analog stick, from -127 to 127 on each axis. Like
+// a sixaxis, positive X is right, and positive Y is down (towards the operator).
+type Stick struct {
+	X int
+	Y int
+}
+
+// InputState is the state of the operator's controls for a single frame, i.e.
+// one call to Step. The buttons are the pressure (from 0 to 255) on each, or
+// zero if not pressed. The zero value is no input at all.
+type InputState struct {
+
+	// The time of the frame. If zero, Step uses the current time. See Now.
+	Time time.Time
+
+	LeftStick  Stick
+	RightStick Stick
+
+	// The dpad.
+	Up    int
+	Down  int
+	Left  int
+	Right int
+
+	Triangle int
+	Start    bool
+}
+
+// InputSource is implemented by components which read the operator's controls
+// (e.g. the controller, from a sixaxis), so MainLoop can pass them to Step.
+type InputSource interface {
+	Input() InputState
+}
+
+// readInput returns the state of the controls from the first component which
+// reads them, at the given time. If none do, there's no input.
+func (h *Hexapod) readInput(now time.Time) InputState {
+	for _, c := range h.Components {
+		if s, ok := c.(InputSource); ok {
+			in := s.Input()
+			in.Time = now
+			return in
+		}
+	}
+
+	return InputState{Time: now}
+}
@@ -0,0 +1,59 @@
+package hexapod
+
+import (
+	"testing"
+	"time"
+)
+
+// joystick is a component which reads a fixed input.
+type joystick struct {
+	counter
+	in InputState
+}
+
+func (j *joystick) Input() InputState {
+	return j.in
+}
+
+func TestStepInput(t *testing.T) {
+	c := &FakeClock{T: time.Unix(100, 0)}
+	h := NewHexapod(nil)
+	h.Clock = c
+	a := &counter{n: 10}
+	h.Add(a)
+
+	// Without a time, the clock is used.
+	in := InputState{LeftStick: Stick{0, -127}, Start: true}
+	if err := h.Step(in); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if !a.last.Equal(c.T) {
+		t.Errorf("ticked at %s, expected %s", a.last, c.T)
+	}
+
+	if h.Input.LeftStick.Y != -127 || !h.Input.Start {
+		t.Errorf("got input %+v, expected %+v", h.Input, in)
+	}
+
+	// Each step replaces the input of the last.
+	h.Step(InputState{})
+	if h.Input.LeftStick.Y != 0 || h.Input.Start {
+		t.Errorf("input %+v left over from the last step", h.Input)
+	}
+}
+
+func TestReadInput(t *testing.T) {
+	h := NewHexapod(nil)
+	h.Add(&counter{})
+	now := time.Unix(100, 0)
+
+	if in := h.readInput(now); in != (InputState{Time: now}) {
+		t.Errorf("got %+v without a source, expected no input", in)
+	}
+
+	h.Add(&joystick{in: InputState{Triangle: 100}})
+	if in := h.readInput(now); in.Triangle != 100 || !in.Time.Equal(now) {
+		t.Errorf("got %+v, expected triangle at %s", in, now)
+	}
+}
@@ -5,10 +5,12 @@ import (
 	"fmt"
 	"github.com/adammck/dynamixel"
 	"github.com/adammck/hexapod"
+	"github.com/adammck/hexapod/cli"
 	"github.com/adammck/hexapod/components/controller"
+	"github.com/adammck/hexapod/components/idle"
 	"github.com/adammck/hexapod/components/legs"
+	"github.com/adammck/hexapod/components/voltage"
 	"github.com/jacobsa/go-serial/serial"
-	//"github.com/adammck/hexapod/components/voltage"
 	"os"
 	"os/signal"
 	"syscall"
@@ -16,8 +18,26 @@ import (
 )
 
 var (
-	portName = flag.String("port", "/dev/ttyACM0", "the serial port path")
-	debug    = flag.Bool("debug", false, "show serial traffic")
+	portName   = flag.String("port", "/dev/ttyACM0", "the serial port path")
+	debug      = flag.Bool("debug", false, "show serial traffic")
+	timeout    = flag.Uint("timeout", 100, "the serial inter-character timeout (ms)")
+	retries    = flag.Int("retries", 0, "the number of times to retry failed reads")
+	idling     = flag.Bool("idle", false, "fidget while standing still")
+	repl       = flag.Bool("cli", false, "read commands from stdin instead of walking")
+	calib      = flag.String("calibration", "", "the path to the calibration offsets")
+	brownout   = flag.Float64("brownout", 0, "the voltage floor when initializing legs in parallel (0 for one at a time)")
+	reconnects = flag.Int("reconnects", 5, "the number of times to try reopening the serial port if it fails (0 to disable)")
+	backoff    = flag.Duration("reconnect-backoff", 500*time.Millisecond, "the time to wait before first reopening the serial port")
+	angleLog   = flag.String("angle-log", "", "the path to write every foot and servo goal to as CSV")
+	swingLog   = flag.String("swing-log", "", "the path to write the goal and actual path of every swinging foot to as CSV")
+	footDown   = flag.Float64("foot-down", 0, "the height (mm) at which the feet touch the ground; lower for soft surfaces")
+	seed       = flag.Int64("seed", 0, "the seed of anything random, e.g. the idle animation (0 for the current time)")
+	crouch     = flag.Duration("crouch-after", 0, "how long to wait without input before crouching to rest (0 to never crouch)")
+	initRamp   = flag.Float64("init-ramp", 3, "the most (in degrees per tick) to move each servo from where it's resting when the legs start (0 to snap)")
+	selfTest   = flag.Bool("self-test", true, "check the servos and the reach of the legs before standing up")
+	checkVolts = flag.Bool("voltage", false, "check the battery voltage regularly, and shut down when it's too low")
+	homeVolts  = flag.Float64("return-voltage", 0, "the voltage below which to walk back to where the hexapod started (0 to never return)")
+	warnVolts  = flag.Float64("warning-voltage", 0, "the voltage below which to slow down, rather than carrying on until it's time to shut down (0 to never slow down)")
 )
 
 func main() {
@@ -29,16 +49,40 @@ func main() {
 		DataBits:              8,
 		StopBits:              1,
 		MinimumReadSize:       0,
-		InterCharacterTimeout: 100,
+		InterCharacterTimeout: *timeout,
 	}
 
 	fmt.Println("Opening serial port...")
-	serial, err := serial.Open(sOpts)
+	p, err := openPort(sOpts, *reconnects, *backoff)
 	if err != nil {
 		fmt.Printf("error opening serial port: %s\n", err)
 		os.Exit(1)
 	}
 
+	network := dynamixel.NewNetwork(p)
+	network.Debug = *debug
+	h := hexapod.NewHexapod(network)
+	h.SkipSelfTest = !*selfTest
+	if *seed != 0 {
+		h.Seed(*seed)
+	}
+
+	// In CLI mode, skip the controller and the main loop entirely. Just ping the
+	// servos and start reading commands.
+	if *repl {
+		l := legs.New(h, network)
+		l.Retries = *retries
+		loadCalibration(l)
+		err = l.Boot()
+		if err != nil {
+			fmt.Printf("error booting legs: %s\n", err)
+			os.Exit(1)
+		}
+
+		cli.Run(l, os.Stdin, os.Stdout)
+		return
+	}
+
 	fmt.Println("Opening controller...")
 	f, err := os.Open("/dev/input/event0")
 	if err != nil {
@@ -46,20 +90,79 @@ func main() {
 		os.Exit(1)
 	}
 
-	network := dynamixel.NewNetwork(serial)
-	network.Debug = *debug
-	h := hexapod.NewHexapod(network)
-
 	fmt.Println("Creating components...")
-	h.Add(legs.New(h, network))
-	//h.Add(voltage.New())
-	h.Add(controller.New(h, f))
+	l := legs.New(h, network)
+	l.Retries = *retries
+	l.BrownoutVoltage = *brownout
+	l.SetFootDown(*footDown)
+	l.InitJointDelta = *initRamp
+	p.onReconnect = l.RequestRestart
+	p.onLost = func() { h.Shutdown = true }
+
+	if *angleLog != "" {
+		f, err := os.Create(*angleLog)
+		if err != nil {
+			fmt.Printf("error creating angle log: %s\n", err)
+			os.Exit(1)
+		}
+
+		defer f.Close()
+		l.AngleLog = legs.NewAngleLog(f)
+	}
+
+	if *swingLog != "" {
+		f, err := os.Create(*swingLog)
+		if err != nil {
+			fmt.Printf("error creating swing log: %s\n", err)
+			os.Exit(1)
+		}
+
+		defer f.Close()
+		l.SwingLog = legs.NewSwingLog(f)
+	}
+	loadCalibration(l)
+	err = l.Validate()
+	if err != nil {
+		fmt.Printf("error: %s\n", err)
+		os.Exit(1)
+	}
+
+	h.Add(l)
+
+	ctrl := controller.New(h, f)
+	ctrl.Stances = l
+	ctrl.Loads = l
+	ctrl.Marcher = l
+
+	if *checkVolts {
+		vc := voltage.New(l.Legs[0].Coxa)
+		vc.Retries = *retries
+		vc.Clock = h.Clock
+		vc.Activity = l
+		vc.ReturnVoltage = *homeVolts
+		vc.Home = h
+		vc.WarningVoltage = *warnVolts
+		vc.Limpers = []voltage.Limper{ctrl, l}
+		h.Add(vc)
+	}
+
+	h.Add(ctrl)
+
+	// The idle animation must come after the controller, so it can spot input
+	// on the same tick. It's also what notices that it's time to crouch.
+	if *idling || *crouch > 0 {
+		i := idle.New(h, l)
+		i.Enabled = *idling
+		i.Crouch = l
+		i.CrouchDelay = *crouch
+		h.Add(i)
+	}
 
 	fmt.Println("Booting components...")
 	h.Boot()
 
-	fmt.Println("Initializing loop...")
-	t := time.NewTicker(1 * time.Second / 60)
+	// Remember where we started, to walk back to when the battery runs low.
+	h.SetHome()
 
 	// Catch both SIGINT (ctrl+c) and SIGTERM (kill/systemd), to allow the hexapod
 	// to power down its servos before exiting.
@@ -72,23 +175,41 @@ func main() {
 		}
 	}()
 
-	// Wait until h.Shutdown is true, then keep looping for three seconds, to give
-	// everything time to shut down gracefully. Then quit.
-	go func() {
-		for {
-			if h.Shutdown {
-				time.Sleep(3 * time.Second)
-				t.Stop()
-				os.Exit(2)	
-			}
-
-			time.Sleep(500 * time.Millisecond)
-		}
-	}()
-
-	// Run until START (bounce service) or SELECT+START (poweroff).
+	// Run until START (bounce service) or SELECT+START (poweroff). The loop
+	// carries on for a few seconds after h.Shutdown is set, to give everything
+	// time to shut down gracefully. Then quit.
 	fmt.Println("Starting loop...")
-	for now := range t.C {
-		h.Tick(now)
+	err = h.MainLoop(1 * time.Second / 60)
+	if err == nil {
+		err = p.lost
+	}
+
+	if err != nil {
+		fmt.Printf("error: %s\n", err)
+		os.Exit(1)
+	}
+
+	os.Exit(2)
+}
+
+// loadCalibration loads the calibration offsets from the file given by the
+// -calibration flag, if any. Carrying on without them is dangerous (the legs
+// may crash into each other), so quit if they can't be loaded.
+func loadCalibration(l *legs.Legs) {
+	if *calib == "" {
+		return
+	}
+
+	f, err := os.Open(*calib)
+	if err != nil {
+		fmt.Printf("error opening calibration: %s\n", err)
+		os.Exit(1)
+	}
+
+	defer f.Close()
+	err = l.LoadCalibration(f)
+	if err != nil {
+		fmt.Printf("error loading calibration: %s\n", err)
+		os.Exit(1)
 	}
 }
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jacobsa/go-serial/serial"
+	"io"
+	"time"
+)
+
+const (
+
+	// The most reads to discard when flushing a reopened port. The port times out
+	// (returning nothing) once it's empty, so this is only reached if something
+	// is still chattering.
+	maxFlushReads = 64
+)
+
+// Returned by every read and write once the port has been lost for good.
+var errPortLost = errors.New("serial port lost")
+
+// port is a serial port which reopens itself when a read or write fails, e.g.
+// because the USB cable was bumped. The failed read or write still returns its
+// error, since whatever was being sent is lost, but the next one should work.
+// If the port can't be reopened, every read and write fails immediately from
+// then on.
+type port struct {
+	open func() (io.ReadWriteCloser, error)
+	rwc  io.ReadWriteCloser
+
+	// The number of times to try reopening the port, and the time to wait before
+	// the first try. The wait is doubled after each failure.
+	retries int
+	backoff time.Duration
+
+	// Called after the port has been reopened. This is called from the middle of
+	// a read or write, so shouldn't do any I/O itself.
+	onReconnect func()
+
+	// Called once, after giving up on reopening the port.
+	onLost func()
+
+	// Set once the port has been given up on.
+	lost error
+
+	// Replaced by tests, to avoid waiting.
+	sleep func(time.Duration)
+}
+
+func openPort(opts serial.OpenOptions, retries int, backoff time.Duration) (*port, error) {
+	return newPort(func() (io.ReadWriteCloser, error) {
+		return serial.Open(opts)
+	}, retries, backoff)
+}
+
+// newPort opens a port with the given function, which is also used to reopen it.
+func newPort(open func() (io.ReadWriteCloser, error), retries int, backoff time.Duration) (*port, error) {
+	rwc, err := open()
+	if err != nil {
+		return nil, err
+	}
+
+	return &port{
+		open:    open,
+		rwc:     rwc,
+		retries: retries,
+		backoff: backoff,
+		sleep:   time.Sleep,
+	}, nil
+}
+
+func (p *port) Read(b []byte) (int, error) {
+	if p.lost != nil {
+		return 0, p.lost
+	}
+
+	n, err := p.rwc.Read(b)
+	if err != nil && err != io.EOF {
+		p.reconnect(err)
+	}
+
+	return n, err
+}
+
+func (p *port) Write(b []byte) (int, error) {
+	if p.lost != nil {
+		return 0, p.lost
+	}
+
+	n, err := p.rwc.Write(b)
+	if err != nil {
+		p.reconnect(err)
+	}
+
+	return n, err
+}
+
+func (p *port) Close() error {
+	if p.lost != nil {
+		return nil
+	}
+
+	return p.rwc.Close()
+}
+
+// reconnect closes the port, and tries to open it again until it works or it
+// runs out of retries. Anything left over from before the drop is flushed. This
+// blocks the main loop, but there's nothing useful to do without the port
+// anyway. If it can't be reopened, the port is marked as lost.
+func (p *port) reconnect(cause error) {
+	if p.retries <= 0 {
+		return
+	}
+
+	fmt.Printf("serial error: %s; reconnecting\n", cause)
+	p.rwc.Close()
+
+	wait := p.backoff
+	for i := 0; i < p.retries; i++ {
+		p.sleep(wait)
+		wait *= 2
+
+		rwc, err := p.open()
+		if err != nil {
+			fmt.Printf("error reopening serial port (attempt %d of %d): %s\n", i+1, p.retries, err)
+			continue
+		}
+
+		fmt.Println("Reconnected to serial port")
+		flush(rwc)
+		p.rwc = rwc
+		if p.onReconnect != nil {
+			p.onReconnect()
+		}
+
+		return
+	}
+
+	fmt.Println("giving up on serial port")
+	p.lost = fmt.Errorf("%w: %s", errPortLost, cause)
+	if p.onLost != nil {
+		p.onLost()
+	}
+}
+
+// flush discards anything waiting to be read from the given port, e.g. the rest
+// of a status packet which was cut off by the drop.
+func flush(r io.Reader) {
+	b := make([]byte, 256)
+	for i := 0; i < maxFlushReads; i++ {
+		n, err := r.Read(b)
+		if n == 0 || err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+// fakeSerial is a serial port which fails every read and write once it's been
+// unplugged, and has some stale bytes waiting to be read.
+type fakeSerial struct {
+	unplugged bool
+	stale     int
+	closed    bool
+}
+
+func (f *fakeSerial) Read(b []byte) (int, error) {
+	if f.unplugged {
+		return 0, errors.New("unplugged")
+	}
+
+	if f.stale > 0 {
+		n := f.stale
+		if n > len(b) {
+			n = len(b)
+		}
+
+		f.stale -= n
+		return n, nil
+	}
+
+	return 0, nil
+}
+
+func (f *fakeSerial) Write(b []byte) (int, error) {
+	if f.unplugged {
+		return 0, errors.New("unplugged")
+	}
+
+	return len(b), nil
+}
+
+func (f *fakeSerial) Close() error {
+	f.closed = true
+	return nil
+}
+
+// testPort returns a port which opens a new fakeSerial every time, as long as
+// plugged returns true, and a pointer to the list of them.
+func testPort(t *testing.T, plugged func() bool) (*port, *[]*fakeSerial) {
+	opened := []*fakeSerial{}
+	p, err := newPort(func() (io.ReadWriteCloser, error) {
+		if !plugged() {
+			return nil, errors.New("no such device")
+		}
+
+		f := &fakeSerial{stale: 300}
+		opened = append(opened, f)
+		return f, nil
+	}, 3, time.Second)
+
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	p.sleep = func(time.Duration) {}
+	return p, &opened
+}
+
+func TestPortReconnect(t *testing.T) {
+	p, opened := testPort(t, func() bool { return true })
+	reconnects := 0
+	p.onReconnect = func() { reconnects += 1 }
+
+	(*opened)[0].unplugged = true
+	if _, err := p.Write([]byte{1}); err == nil {
+		t.Errorf("expected the failed write to return its error")
+	}
+
+	if len(*opened) != 2 || !(*opened)[0].closed || reconnects != 1 {
+		t.Fatalf("expected to reconnect once, opened %d ports", len(*opened))
+	}
+
+	// Whatever was waiting on the new port was flushed.
+	if s := (*opened)[1].stale; s != 0 {
+		t.Errorf("%d stale bytes left", s)
+	}
+
+	if _, err := p.Write([]byte{1}); err != nil {
+		t.Errorf("unexpected error after reconnecting: %s", err)
+	}
+}
+
+func TestPortLost(t *testing.T) {
+	plugged := true
+	p, opened := testPort(t, func() bool { return plugged })
+	lost := 0
+	p.onLost = func() { lost += 1 }
+
+	var waited time.Duration
+	p.sleep = func(d time.Duration) { waited += d }
+
+	plugged = false
+	(*opened)[0].unplugged = true
+	p.Read(make([]byte, 1))
+
+	if exp := 7 * time.Second; waited != exp || lost != 1 {
+		t.Fatalf("waited %s (lost %d), expected %s and lost once", waited, lost, exp)
+	}
+
+	// From then on, everything fails straight away, without waiting again.
+	waited = 0
+	for i := 0; i < 3; i++ {
+		if _, err := p.Write([]byte{1}); !errors.Is(err, errPortLost) {
+			t.Errorf("got %v, expected %s", err, errPortLost)
+		}
+	}
+
+	if waited != 0 || lost != 1 {
+		t.Errorf("waited %s after giving up", waited)
+	}
+}
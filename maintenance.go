@@ -0,0 +1,24 @@
+package hexapod
+
+import (
+	"fmt"
+)
+
+// Maintainer is implemented by components which can detach a single leg for
+// maintenance, while the others hold the body up (e.g. the legs).
+type Maintainer interface {
+	MaintenanceMode(leg int) error
+}
+
+// MaintenanceMode detaches the given leg so it can be worked on, via the first
+// component which can. Returns an error if none can, or if it refuses (e.g.
+// because the body wouldn't be stable without the leg).
+func (h *Hexapod) MaintenanceMode(leg int) error {
+	for _, c := range h.Components {
+		if m, ok := c.(Maintainer); ok {
+			return m.MaintenanceMode(leg)
+		}
+	}
+
+	return fmt.Errorf("no component can detach leg %d", leg)
+}
@@ -0,0 +1,45 @@
+package hexapod
+
+import (
+	"fmt"
+	"testing"
+)
+
+// maintainer is a component which can detach any leg but the first.
+type maintainer struct {
+	counter
+	detached []int
+}
+
+func (m *maintainer) MaintenanceMode(leg int) error {
+	if leg == 0 {
+		return fmt.Errorf("unstable")
+	}
+
+	m.detached = append(m.detached, leg)
+	return nil
+}
+
+func TestMaintenanceMode(t *testing.T) {
+	h := NewHexapod(nil)
+	h.Add(&counter{})
+
+	if err := h.MaintenanceMode(1); err == nil {
+		t.Errorf("expected error without a maintainer")
+	}
+
+	m := &maintainer{}
+	h.Add(m)
+
+	if err := h.MaintenanceMode(0); err == nil {
+		t.Errorf("expected refusal to be passed on")
+	}
+
+	if err := h.MaintenanceMode(1); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	if len(m.detached) != 1 || m.detached[0] != 1 {
+		t.Errorf("got %v, expected [1]", m.detached)
+	}
+}
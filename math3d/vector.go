@@ -2,6 +2,7 @@ package math3d
 
 import (
 	"fmt"
+	"github.com/adammck/hexapod/utils"
 	"math"
 )
 
@@ -38,6 +39,57 @@ func (v Vector3) Add(vv Vector3) *Vector3 {
 	}
 }
 
+// Subtract subtracts another vector from this one, and returns a pointer to the
+// result.
+func (v Vector3) Subtract(vv Vector3) *Vector3 {
+	return &Vector3{
+		(v.X - vv.X),
+		(v.Y - vv.Y),
+		(v.Z - vv.Z),
+	}
+}
+
+// Scale returns a new Vector3, by multiplying each component of this vector by
+// the given factor.
+func (v Vector3) Scale(f float64) Vector3 {
+	return Vector3{
+		(v.X * f),
+		(v.Y * f),
+		(v.Z * f),
+	}
+}
+
+// Length returns the distance between this vector and the origin.
+func (v Vector3) Length() float64 {
+	return v.Distance(ZeroVector3)
+}
+
+// Unit returns a new Vector3 pointing in the same direction as this vector, but
+// with a length of one. The zero vector is returned unchanged.
+func (v Vector3) Unit() Vector3 {
+	l := v.Length()
+	if l == 0 {
+		return v
+	}
+
+	return v.Scale(1 / l)
+}
+
+// RotateY returns a new Vector3, by rotating this vector around the Y axis by
+// the given angle (in degrees). This is consistent with the heading of the
+// EulerAngles, so rotating 90 degrees turns +X into -Z, and +Z into +X.
+func (v Vector3) RotateY(deg float64) Vector3 {
+	r := utils.Rad(deg)
+	c := math.Cos(r)
+	s := math.Sin(r)
+
+	return Vector3{
+		(v.X * c) + (v.Z * s),
+		v.Y,
+		(v.Z * c) - (v.X * s),
+	}
+}
+
 // Distance calculates and returns the distance between this vector and another,
 // as a float64.
 func (v Vector3) Distance(vv Vector3) float64 {
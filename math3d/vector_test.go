@@ -0,0 +1,35 @@
+package math3d
+
+import (
+	"testing"
+)
+
+func TestRotateY(t *testing.T) {
+	type example struct {
+		vec Vector3
+		deg float64
+		exp Vector3
+	}
+
+	data := []example{
+		example{Vector3{1, 2, 3}, 0, Vector3{1, 2, 3}},
+		example{Vector3{1, 2, 0}, 90, Vector3{0, 2, -1}},
+		example{Vector3{0, 2, 1}, 90, Vector3{1, 2, 0}},
+		example{Vector3{1, 0, 0}, 180, Vector3{-1, 0, 0}},
+		example{Vector3{1, 0, 0}, -90, Vector3{0, 0, 1}},
+		example{Vector3{1, 0, 0}, 450, Vector3{0, 0, -1}},
+	}
+
+	for i, eg := range data {
+		actual := eg.vec.RotateY(eg.deg)
+		if actual.Distance(eg.exp) > 0.000001 {
+			t.Errorf("Example #%d: got %s, expected: %s", i+1, actual, eg.exp)
+		}
+
+		// Should be the same as multiplying by a heading-only matrix.
+		m := MakeMatrix44(ZeroVector3, *MakeSingularEulerAngle(RotationHeading, eg.deg))
+		if mm := eg.vec.MultiplyByMatrix44(*m); actual.Distance(mm) > 0.000001 {
+			t.Errorf("Example #%d: got %s, but matrix gave: %s", i+1, actual, mm)
+		}
+	}
+}
@@ -0,0 +1,21 @@
+package hexapod
+
+// GaitPhaser is implemented by components which walk in a cycle (e.g. the legs),
+// so other components can move in time with it. See GaitPhase.
+type GaitPhaser interface {
+	GaitPhase() float64
+	LegSet() int
+}
+
+// GaitPhase returns how far (from 0 to 1) the hexapod is through its step
+// cycle, and the index of the leg set which is stepping. If no component walks,
+// it's always zero.
+func (h *Hexapod) GaitPhase() (float64, int) {
+	for _, c := range h.Components {
+		if g, ok := c.(GaitPhaser); ok {
+			return g.GaitPhase(), g.LegSet()
+		}
+	}
+
+	return 0, 0
+}
@@ -0,0 +1,34 @@
+package hexapod
+
+import (
+	"testing"
+)
+
+// phaser is a component which is always at the same phase.
+type phaser struct {
+	counter
+	phase float64
+	set   int
+}
+
+func (p *phaser) GaitPhase() float64 {
+	return p.phase
+}
+
+func (p *phaser) LegSet() int {
+	return p.set
+}
+
+func TestGaitPhase(t *testing.T) {
+	h := NewHexapod(nil)
+	h.Add(&counter{})
+
+	if p, s := h.GaitPhase(); p != 0 || s != 0 {
+		t.Errorf("without a phaser: got %0.2f (set %d), expected 0", p, s)
+	}
+
+	h.Add(&phaser{phase: 0.75, set: 1})
+	if p, s := h.GaitPhase(); p != 0.75 || s != 1 {
+		t.Errorf("got %0.2f (set %d), expected 0.75 (set 1)", p, s)
+	}
+}
@@ -0,0 +1,19 @@
+package hexapod
+
+import (
+	"math/rand"
+	"time"
+)
+
+// newRand returns a source of randomness seeded with the current time, so each
+// run is different unless the hexapod is given a seed. See Seed.
+func newRand() *rand.Rand {
+	return rand.New(rand.NewSource(time.Now().UnixNano()))
+}
+
+// Seed replaces the source of randomness with one seeded with the given value.
+// Every component which needs randomness should use Rand, so anything random
+// (e.g. the idle animation) is reproducible given the same seed.
+func (h *Hexapod) Seed(seed int64) {
+	h.Rand = rand.New(rand.NewSource(seed))
+}
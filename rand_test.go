@@ -0,0 +1,18 @@
+package hexapod
+
+import (
+	"testing"
+)
+
+func TestSeed(t *testing.T) {
+	a := NewHexapod(nil)
+	b := NewHexapod(nil)
+	a.Seed(42)
+	b.Seed(42)
+
+	for i := 0; i < 10; i++ {
+		if x, y := a.Rand.Float64(), b.Rand.Float64(); x != y {
+			t.Fatalf("draw %d: got %f and %f from the same seed", i, x, y)
+		}
+	}
+}
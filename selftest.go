@@ -0,0 +1,30 @@
+package hexapod
+
+import "errors"
+
+// SelfTester is implemented by components which can check (without moving)
+// that they're fit to run, e.g. that their servos are responding.
+type SelfTester interface {
+	SelfTest() error
+}
+
+// SelfTest runs the self-test of every component which has one, and returns an
+// error wrapping every failure, or nil if they all passed. MainLoop calls this
+// before the first step, unless SkipSelfTest is set.
+func (h *Hexapod) SelfTest() error {
+	failures := []error{}
+
+	for _, c := range h.Components {
+		if t, ok := c.(SelfTester); ok {
+			if err := t.SelfTest(); err != nil {
+				failures = append(failures, err)
+			}
+		}
+	}
+
+	if len(failures) > 0 {
+		return errors.Join(failures...)
+	}
+
+	return nil
+}
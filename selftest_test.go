@@ -0,0 +1,51 @@
+package hexapod
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// tester is a component with a self-test which fails if err is set.
+type tester struct {
+	counter
+	err error
+}
+
+func (t *tester) SelfTest() error {
+	return t.err
+}
+
+func TestSelfTest(t *testing.T) {
+	h := NewHexapod(nil)
+	a := &tester{}
+	b := &tester{err: fmt.Errorf("b is broken")}
+	c := &tester{err: fmt.Errorf("c is broken: %w", ErrLowVoltage)}
+	h.Add(a)
+	h.Add(&counter{})
+
+	if err := h.SelfTest(); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	h.Add(b)
+	h.Add(c)
+	err := h.SelfTest()
+	if err == nil || err.Error() != "b is broken\nc is broken: low voltage" {
+		t.Errorf("got %v, expected both failures", err)
+	}
+
+	if !errors.Is(err, ErrLowVoltage) {
+		t.Errorf("expected error to match ErrLowVoltage: %v", err)
+	}
+
+	// The main loop shouldn't step at all.
+	if err := h.MainLoop(time.Millisecond); err == nil {
+		t.Errorf("expected main loop to fail")
+	}
+
+	if a.ticks != 0 {
+		t.Errorf("ticked %d times, expected 0", a.ticks)
+	}
+}
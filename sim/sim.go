@@ -0,0 +1,171 @@
+// Package sim runs the whole hexapod (the legs, controller, and idle animation)
+// against simulated servos, frame by frame, with scripted input. The same config
+// always produces exactly the same run, so it's useful for tests, and to check
+// changes to the gait without a robot.
+package sim
+
+import (
+	"fmt"
+	"github.com/adammck/hexapod"
+	"github.com/adammck/hexapod/components/controller"
+	"github.com/adammck/hexapod/components/idle"
+	"github.com/adammck/hexapod/components/legs"
+	"io"
+	"time"
+)
+
+// Trace is the goal of every servo (in the order of Legs and Servos) after each
+// frame of a run.
+type Trace [][24]float64
+
+// Config bundles everything needed to run the simulation.
+type Config struct {
+
+	// The clock which drives the run. It's advanced by Interval before each
+	// frame, so the start time is the only thing which matters.
+	Clock    *hexapod.FakeClock
+	Interval time.Duration
+	Frames   int
+
+	// The seed of the hexapod's randomness, e.g. for the idle animation.
+	Seed int64
+
+	// Returns the input to pass to the controller for each frame, as if it had
+	// been read from the sixaxis. Nothing is pressed when this is nil.
+	Input func(frame int) hexapod.InputState
+}
+
+// Run runs the simulation, and returns the trace of it.
+func (c Config) Run() (Trace, error) {
+	h := hexapod.NewHexapod(nil)
+	h.Clock = c.Clock
+	h.Seed(c.Seed)
+
+	l := legs.New(h, nil)
+	l.Network = &network{}
+	servos := [6][4]*Servo{}
+	for i, leg := range l.Legs {
+		servos[i] = attach(leg)
+	}
+
+	// The controller never reads anything from the sixaxis, since the input is
+	// passed to Step instead. The pipe just keeps it waiting.
+	r, _ := io.Pipe()
+	ctrl := controller.New(h, r)
+	ctrl.Stances = l
+	ctrl.Loads = l
+	ctrl.Marcher = l
+
+	// In the same order as main.
+	h.Add(l)
+	h.Add(ctrl)
+	h.Add(idle.New(h, l))
+
+	err := h.Boot()
+	if err != nil {
+		return nil, err
+	}
+
+	trace := make(Trace, c.Frames)
+	for f := 0; f < c.Frames; f++ {
+		c.Clock.Advance(c.Interval)
+
+		in := hexapod.InputState{}
+		if c.Input != nil {
+			in = c.Input(f)
+		}
+
+		err := h.Step(in)
+		if err != nil {
+			return nil, fmt.Errorf("frame %d: %s", f, err)
+		}
+
+		for i := range servos {
+			for j, s := range servos[i] {
+				trace[f][(i*4)+j] = s.Goal
+			}
+		}
+	}
+
+	return trace, nil
+}
+
+// Servo is a simulated servo, which moves to its goal instantly, and always has
+// a full battery.
+type Servo struct {
+	ID     uint8
+	Goal   float64
+	Torque bool
+	LED    bool
+	Speed  int
+}
+
+// attach replaces the servos of the given leg with simulated ones, and returns
+// them.
+func attach(leg *legs.Leg) [4]*Servo {
+	ids := leg.ServoIDs()
+	s := [4]*Servo{}
+	for i := range s {
+		s[i] = &Servo{ID: ids[i]}
+	}
+
+	leg.Coxa = s[0]
+	leg.Femur = s[1]
+	leg.Tibia = s[2]
+	leg.Tarsus = s[3]
+	return s
+}
+
+func (s *Servo) Ping() error {
+	return nil
+}
+
+func (s *Servo) SetStatusReturnLevel(value int) error {
+	return nil
+}
+
+func (s *Servo) SetTorqueEnable(state bool) error {
+	s.Torque = state
+	return nil
+}
+
+func (s *Servo) SetMovingSpeed(speed int) error {
+	s.Speed = speed
+	return nil
+}
+
+func (s *Servo) SetLed(state bool) error {
+	s.LED = state
+	return nil
+}
+
+func (s *Servo) MoveTo(angle float64) error {
+	s.Goal = angle
+	return nil
+}
+
+func (s *Servo) Angle() (float64, error) {
+	return s.Goal, nil
+}
+
+func (s *Servo) Voltage() (float64, error) {
+	return 12, nil
+}
+
+// ModelNumber returns the model number of an AX-12.
+func (s *Servo) ModelNumber() (int, error) {
+	return 12, nil
+}
+
+func (s *Servo) Load() (int, error) {
+	return 0, nil
+}
+
+// network is a simulated Dynamixel network, which does nothing.
+type network struct{}
+
+func (n *network) SetBuffered(buffered bool) {}
+
+func (n *network) Action() error {
+	return nil
+}
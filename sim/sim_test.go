@@ -0,0 +1,82 @@
+package sim
+
+import (
+	"fmt"
+	"github.com/adammck/hexapod"
+	"hash/fnv"
+	"testing"
+	"time"
+)
+
+// hash returns a hash of the given trace, rounded to a thousandth of a degree,
+// for comparison with a golden value.
+func hash(trace Trace) string {
+	h := fnv.New64a()
+	for _, frame := range trace {
+		for _, a := range frame {
+			fmt.Fprintf(h, "%.3f,", a)
+		}
+	}
+
+	return fmt.Sprintf("%016x", h.Sum64())
+}
+
+// walk is the scripted input for the golden trace. It stands still for a while
+// (to let the idle animation run), then walks forwards while turning right.
+func walk(f int) hexapod.InputState {
+	if f > 600 {
+		return hexapod.InputState{
+			LeftStick:  hexapod.Stick{0, -85},
+			RightStick: hexapod.Stick{32, 0},
+		}
+	}
+
+	return hexapod.InputState{}
+}
+
+func newConfig() Config {
+	return Config{
+		Clock:    &hexapod.FakeClock{T: time.Unix(1000, 0)},
+		Interval: time.Second / 60,
+		Frames:   1000,
+		Seed:     1,
+		Input:    walk,
+	}
+}
+
+func TestReproducible(t *testing.T) {
+	a, err := newConfig().Run()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	b, err := newConfig().Run()
+	if err != nil {
+		t.Fatalf("unexpected error on second run: %s", err)
+	}
+
+	for f := range a {
+		if a[f] != b[f] {
+			t.Fatalf("runs diverged at frame %d: %v != %v", f, a[f], b[f])
+		}
+	}
+
+	// Update this (deliberately!) when the gait changes.
+	exp := "733e1865b50b8252"
+	if h := hash(a); h != exp {
+		t.Errorf("got trace %s, expected %s", h, exp)
+	}
+}
+
+func TestWalks(t *testing.T) {
+	trace, err := newConfig().Run()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// The servos should be moving at the end, since it's still walking.
+	last := trace[len(trace)-1]
+	if last == trace[len(trace)-2] {
+		t.Errorf("servos stopped moving while walking")
+	}
+}
@@ -0,0 +1,87 @@
+package hexapod
+
+import (
+	"fmt"
+	"github.com/adammck/hexapod/math3d"
+)
+
+// Snapshotter is implemented by components which have working state (e.g. the
+// position of each foot) which must be saved along with the pose of the body
+// to pause and resume the hexapod. See Snapshot.
+type Snapshotter interface {
+	Snapshot() interface{}
+	Restore(s interface{}) error
+}
+
+// State is everything needed to resume the hexapod where it left off. Unlike
+// the pose, it includes the working state of the components, so restoring it
+// puts the feet back exactly where they were.
+type State struct {
+	Position       math3d.Vector3
+	Rotation       float64
+	Pitch          float64
+	Roll           float64
+	TargetRotation *float64
+
+	// The snapshot of each component, in the same order as Components, or nil
+	// for components which aren't Snapshotters.
+	Components []interface{}
+}
+
+// Snapshot returns the current state of the hexapod and its components.
+func (h *Hexapod) Snapshot() State {
+	s := State{
+		Position:   h.Position,
+		Rotation:   h.Rotation,
+		Pitch:      h.Pitch,
+		Roll:       h.Roll,
+		Components: make([]interface{}, len(h.Components)),
+	}
+
+	if h.TargetRotation != nil {
+		r := *h.TargetRotation
+		s.TargetRotation = &r
+	}
+
+	for i, c := range h.Components {
+		if ss, ok := c.(Snapshotter); ok {
+			s.Components[i] = ss.Snapshot()
+		}
+	}
+
+	return s
+}
+
+// Restore returns the hexapod and its components to the given state, which
+// should have come from Snapshot on a hexapod with the same components added
+// in the same order. Nothing moves until the next Step.
+func (h *Hexapod) Restore(s State) error {
+	if len(s.Components) != len(h.Components) {
+		return fmt.Errorf("snapshot has %d components, expected %d", len(s.Components), len(h.Components))
+	}
+
+	for i, c := range h.Components {
+		ss, ok := c.(Snapshotter)
+		if !ok || s.Components[i] == nil {
+			continue
+		}
+
+		err := ss.Restore(s.Components[i])
+		if err != nil {
+			return err
+		}
+	}
+
+	h.Position = s.Position
+	h.Rotation = s.Rotation
+	h.Pitch = s.Pitch
+	h.Roll = s.Roll
+	h.TargetRotation = nil
+
+	if s.TargetRotation != nil {
+		r := *s.TargetRotation
+		h.TargetRotation = &r
+	}
+
+	return nil
+}
@@ -0,0 +1,62 @@
+package hexapod
+
+import (
+	"github.com/adammck/hexapod/math3d"
+	"testing"
+)
+
+// stateful is a component which snapshots a single number.
+type stateful struct {
+	counter
+	n int
+}
+
+func (s *stateful) Snapshot() interface{} {
+	return s.n
+}
+
+func (s *stateful) Restore(v interface{}) error {
+	s.n = v.(int)
+	return nil
+}
+
+func TestSnapshot(t *testing.T) {
+	h := NewHexapod(nil)
+	c := &stateful{n: 7}
+	h.Add(&counter{})
+	h.Add(c)
+
+	r := 90.0
+	h.Position = math3d.Vector3{10, 20, 30}
+	h.Rotation = 45
+	h.TargetRotation = &r
+	s := h.Snapshot()
+
+	// Mess everything up, then put it back.
+	h.Position = math3d.ZeroVector3
+	h.Rotation = 0
+	h.TargetRotation = nil
+	c.n = 0
+
+	if err := h.Restore(s); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if h.Position != (math3d.Vector3{10, 20, 30}) || h.Rotation != 45 {
+		t.Errorf("got pose %s @ %0.2f, expected the snapshot", h.Position, h.Rotation)
+	}
+
+	if h.TargetRotation == nil || *h.TargetRotation != 90 {
+		t.Errorf("expected target rotation to be restored")
+	}
+
+	if c.n != 7 {
+		t.Errorf("got component state %d, expected 7", c.n)
+	}
+
+	// A hexapod with different components can't be restored.
+	h.Add(&counter{})
+	if err := h.Restore(s); err == nil {
+		t.Errorf("expected error restoring with an extra component")
+	}
+}
@@ -12,6 +12,37 @@ func Rad(degrees float64) float64 {
 	return (math.Pi / 180) * degrees
 }
 
+// Retry calls the given function until it returns nil, or until it has been
+// retried n times, and returns the last error. This is useful for reads, which
+// occasionally time out on flaky USB-serial adapters. Don't use it for writes,
+// unless they're idempotent.
+func Retry(n int, f func() error) error {
+	var err error
+
+	for i := 0; i <= n; i++ {
+		err = f()
+		if err == nil {
+			return nil
+		}
+	}
+
+	return err
+}
+
+// NormalizeDeg wraps the given angle (in degrees) into the range (-180, 180].
+func NormalizeDeg(degrees float64) float64 {
+	d := math.Mod(degrees, 360)
+
+	if d > 180 {
+		d -= 360
+
+	} else if d <= -180 {
+		d += 360
+	}
+
+	return d
+}
+
 func sign(n float64) float64 {
 	if n > 0 {
 		return 1.0
@@ -0,0 +1,131 @@
+package hexapod
+
+import (
+	"context"
+	"fmt"
+	"github.com/adammck/hexapod/math3d"
+	"github.com/adammck/hexapod/utils"
+	"math"
+	"time"
+)
+
+const (
+
+	// The interval at which WalkTo steps the hexapod.
+	walkInterval = time.Second / 60
+
+	// How close (in mm) to the target WalkTo must get before it's done.
+	walkTolerance = 2.0
+
+	// How close (in degrees) to the target heading WalkTo must get.
+	walkHeadingTolerance = 0.5
+
+	// The speed (in degrees per second) at which WalkTo turns.
+	walkTurnSpeed = 45.0
+)
+
+// WalkTo walks the hexapod to the given position (in the world space) at the
+// given speed (in mm per second), turning to face the given heading along the
+// way. It runs the main loop itself, so mustn't be called while MainLoop is
+// running. Returns nil once it gets there, or an error if the context is
+// cancelled, a component returns an error, or Shutdown is set.
+//
+// The position is where we think we are, from odometry, so the hexapod may not
+// end up exactly there if the feet slip. The speed is measured by the hexapod's
+// clock (see Now), which every component is ticked at.
+func (h *Hexapod) WalkTo(ctx context.Context, target math3d.Vector3, heading float64, speed float64) error {
+	if speed <= 0 {
+		return fmt.Errorf("invalid speed: %f", speed)
+	}
+
+	t := time.NewTicker(walkInterval)
+	defer t.Stop()
+
+	last := h.Now()
+
+	for {
+		if h.arrived(target, heading) {
+			return nil
+		}
+
+		if h.Shutdown {
+			return fmt.Errorf("shutting down")
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+
+		case <-t.C:
+			now := h.Now()
+			h.walkToward(target, heading, speed, now.Sub(last).Seconds())
+			last = now
+
+			err := h.Step(InputState{Time: now})
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// SetHome records the current position and rotation of the hexapod as home, to
+// return to later. See WalkHome.
+func (h *Hexapod) SetHome() {
+	p := h.Position
+	h.Home = &p
+	h.HomeRotation = h.Rotation
+}
+
+// HasHome returns true if a home has been recorded. See SetHome.
+func (h *Hexapod) HasHome() bool {
+	return h.Home != nil
+}
+
+// WalkHome moves the hexapod towards home, by as far as it can get in dt seconds
+// at the given speed (in mm per second), and returns true once it's there. This
+// is for components to call each tick, unlike WalkTo, which runs its own loop.
+// Returns an error if no home has been recorded.
+func (h *Hexapod) WalkHome(speed float64, dt float64) (bool, error) {
+	if h.Home == nil {
+		return false, fmt.Errorf("no home has been set")
+	}
+
+	if h.arrived(*h.Home, h.HomeRotation) {
+		return true, nil
+	}
+
+	h.walkToward(*h.Home, h.HomeRotation, speed, dt)
+	return h.arrived(*h.Home, h.HomeRotation), nil
+}
+
+// arrived returns true if the hexapod is at the given position and heading, as
+// far as WalkTo cares. Only the X/Z axis is considered.
+func (h *Hexapod) arrived(target math3d.Vector3, heading float64) bool {
+	d := math.Hypot(target.X-h.Position.X, target.Z-h.Position.Z)
+	return d <= walkTolerance && math.Abs(utils.NormalizeDeg(heading-h.Rotation)) <= walkHeadingTolerance
+}
+
+// walkToward moves the hexapod towards the given position and heading, by as
+// far as it can get in dt seconds at the given speed. It turns around its Pivot
+// (see Turn) first, then moves. If any component objects to either (e.g. the
+// legs need to step first), it stays put, and will try again next time.
+func (h *Hexapod) walkToward(target math3d.Vector3, heading float64, speed float64, dt float64) {
+	diff := utils.NormalizeDeg(heading - h.Rotation)
+	if turn := math.Min(math.Abs(diff), walkTurnSpeed*dt); turn > 0 {
+		h.Turn(math.Copysign(turn, diff))
+	}
+
+	pos := h.Position
+	dx := target.X - pos.X
+	dz := target.Z - pos.Z
+	if d := math.Hypot(dx, dz); d > 0 {
+		step := math.Min(d, speed*dt)
+		pos.X += (dx / d) * step
+		pos.Z += (dz / d) * step
+	}
+
+	if pos != h.Position {
+		h.SetPose(pos, h.Rotation)
+	}
+}
@@ -0,0 +1,176 @@
+package hexapod
+
+import (
+	"context"
+	"github.com/adammck/hexapod/math3d"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestWalkTo(t *testing.T) {
+	h := NewHexapod(nil)
+	c := &counter{n: 1000}
+	h.Add(c)
+
+	target := math3d.Vector3{30, 0, -40}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := h.WalkTo(ctx, target, 90, 500); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if d := math.Hypot(h.Position.X-target.X, h.Position.Z-target.Z); d > walkTolerance {
+		t.Errorf("stopped %0.2f mm from target", d)
+	}
+
+	if math.Abs(h.Rotation-90) > walkHeadingTolerance {
+		t.Errorf("stopped facing %0.2f, expected 90", h.Rotation)
+	}
+
+	if c.ticks == 0 {
+		t.Errorf("components weren't ticked")
+	}
+}
+
+// ticker is a component which advances a fake clock by a tenth of a second each
+// tick, and records whether it was ticked at the time of that clock.
+type ticker struct {
+	counter
+	clock  *FakeClock
+	missed int
+}
+
+func (t *ticker) Tick(now time.Time) error {
+	if !now.Equal(t.clock.Now()) {
+		t.missed += 1
+	}
+
+	t.clock.Advance(100 * time.Millisecond)
+	return t.counter.Tick(now)
+}
+
+func TestWalkToClock(t *testing.T) {
+	c := &FakeClock{T: time.Unix(100, 0)}
+	h := NewHexapod(nil)
+	h.Clock = c
+	tk := &ticker{counter: counter{n: 1000}, clock: c}
+	h.Add(tk)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// 10mm per tick, by the fake clock, however fast the real one is. The first
+	// tick doesn't move, since no time has passed yet.
+	if err := h.WalkTo(ctx, math3d.Vector3{0, 0, 30}, 0, 100); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if tk.ticks != 4 || tk.missed != 0 {
+		t.Errorf("ticked %d times (%d not at the clock's time), expected 4", tk.ticks, tk.missed)
+	}
+}
+
+func TestWalkTowardPivot(t *testing.T) {
+	h := NewHexapod(nil)
+	h.Pivot = math3d.Vector3{0, 0, 100}
+	pivot := h.Pivot.MultiplyByMatrix44(h.World())
+
+	// Standing still (no speed), so the only movement is the turn, which should
+	// swing the body around the pivot.
+	h.walkToward(h.Position, 90, 0, 0.1)
+
+	if h.Rotation == 0 || h.Position.Zero() {
+		t.Errorf("expected to turn and move, got %s at %0.2f deg", h.Position, h.Rotation)
+	}
+
+	if p := h.Pivot.MultiplyByMatrix44(h.World()); p.Distance(pivot) > 0.000001 {
+		t.Errorf("pivot moved from %s to %s", pivot, p)
+	}
+
+	// WalkTo still ends up at the target, wherever the turn swung it.
+	target := math3d.Vector3{30, 0, -40}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := h.WalkTo(ctx, target, 90, 500); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if !h.arrived(target, 90) {
+		t.Errorf("ended at %s, %0.2f deg", h.Position, h.Rotation)
+	}
+}
+
+func TestWalkToCancel(t *testing.T) {
+	h := NewHexapod(nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	// Far too far to get there in time.
+	err := h.WalkTo(ctx, math3d.Vector3{0, 0, 10000}, 0, 10)
+	if err != context.DeadlineExceeded {
+		t.Errorf("expected DeadlineExceeded, got %v", err)
+	}
+
+	if h.Position.Z <= 0 || h.Position.Z > 10 {
+		t.Errorf("expected to have moved a bit, got %s", h.Position)
+	}
+}
+
+func TestWalkHome(t *testing.T) {
+	h := NewHexapod(nil)
+	if _, err := h.WalkHome(100, 0.1); err == nil || h.HasHome() {
+		t.Errorf("expected error without a home")
+	}
+
+	h.Position = math3d.Vector3{10, 0, 20}
+	h.Rotation = 30
+	h.SetHome()
+	if !h.HasHome() {
+		t.Errorf("expected a home after SetHome")
+	}
+
+	h.Position = math3d.Vector3{110, 0, 20}
+	h.Rotation = 0
+
+	// 100mm away, at 100mm/s.
+	for i := 1; i <= 10; i++ {
+		home, err := h.WalkHome(100, 0.1)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		if home != (i == 10) {
+			t.Errorf("step %d: home=%v at %s", i, home, h.Position)
+		}
+	}
+
+	if h.Position.Distance(math3d.Vector3{10, 0, 20}) > walkTolerance || h.Rotation != 30 {
+		t.Errorf("ended at %s @ %0.2f, expected home", h.Position, h.Rotation)
+	}
+}
+
+func TestWalkHomeAfterSetOrigin(t *testing.T) {
+	h := NewHexapod(nil)
+	h.Position = math3d.Vector3{10, 0, 20}
+	h.SetHome()
+
+	// Moving the origin doesn't move home in the real world.
+	h.Position = math3d.Vector3{110, 0, 20}
+	h.SetOrigin()
+	if exp := (math3d.Vector3{-100, 0, 0}); *h.Home != exp {
+		t.Errorf("home is %s after moving the origin, expected %s", h.Home, exp)
+	}
+
+	for i := 0; i < 20; i++ {
+		if _, err := h.WalkHome(100, 0.1); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+
+	if h.Position.Distance(math3d.Vector3{-100, 0, 0}) > walkTolerance {
+		t.Errorf("ended at %s, expected home", h.Position)
+	}
+}